@@ -0,0 +1,56 @@
+// Command clientschema prints every client_type accepted in client distribution json files along with the
+// custom properties each accepts (their json map, default & bounds).
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	clientfactory "github.com/Shopify/goqueuesim/internal/client/impl"
+)
+
+func main() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Distribution specs (constant, uniform, normal, lognormal, exponential, empirical) accepted by every client_type:")
+	fmt.Fprintln(w, "  initial_delay_ms\tsampled per client\treplaces max_initial_delay_ms")
+	fmt.Fprintln(w, "  network_jitter_ms\tsampled per request\treplaces max_network_jitter_ms")
+	fmt.Fprintln(w, "  poll_interval_seconds\tsampled per poll\treplaces dflt_poll_interval_seconds")
+	fmt.Fprintln(w, "  property_distributions.<property>\tsampled per client\treplaces a numeric custom property")
+	fmt.Fprintln(w, "\nProperties accepted by every client_type:")
+	printProperties(w, clientfactory.CommonPropertySchemas())
+	for _, schema := range clientfactory.ClientTypeSchemas() {
+		fmt.Fprintf(w, "\n%s: %s\n", schema.ClientType, schema.Description)
+		printProperties(w, schema.Properties)
+	}
+	w.Flush()
+}
+
+func printProperties(w *tabwriter.Writer, properties []clientfactory.PropertySchema) {
+	if len(properties) == 0 {
+		fmt.Fprintln(w, "  (no properties of its own)")
+		return
+	}
+	for _, property := range properties {
+		name := property.Name
+		if property.IsPrefix {
+			name += "<number>"
+		}
+		dflt := "required"
+		switch {
+		case property.Default != nil:
+			dflt = fmt.Sprintf("default %v", property.Default)
+		case property.Optional:
+			dflt = "optional"
+		}
+		constraint := property.Range()
+		if len(property.OneOf) > 0 {
+			constraint = fmt.Sprintf("{%s}", strings.Join(property.OneOf, ", "))
+		}
+		fmt.Fprintf(
+			w, "  %s\tcustom_%s_properties\t%s\t%s\t%s\n",
+			name, property.Kind, dflt, constraint, property.Description,
+		)
+	}
+}
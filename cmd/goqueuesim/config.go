@@ -1,32 +1,14 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"math"
-	"math/rand"
-	"os"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/Shopify/goqueuesim/internal/client"
-	clientfactory "github.com/Shopify/goqueuesim/internal/client/impl"
-	"github.com/Shopify/goqueuesim/internal/common"
-	"github.com/Shopify/goqueuesim/internal/lua_config/lua_queue"
 	"github.com/Shopify/goqueuesim/internal/metrics"
-	"github.com/Shopify/goqueuesim/internal/network_mock"
-	"github.com/Shopify/goqueuesim/internal/simulator"
-	"github.com/Shopify/goqueuesim/internal/throttle"
-	"github.com/Shopify/goqueuesim/internal/throttle/queue"
-	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
-	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
-	trackerfactory "github.com/Shopify/goqueuesim/internal/throttle/tracker/impl"
-
-	"github.com/go-redis/redis/v7"
+	"github.com/Shopify/goqueuesim/simulation"
+
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 const (
@@ -34,18 +16,19 @@ const (
 
 	dashboardUrl = "https://datadoghq.com/dashboard/path/to/dashboard"
 
-	// Json file configuring distribution of Checkout clients for simulation.
+	// Json file configuring distribution of Checkout clients for simulation (default shop).
 	clientDistributionJsonPath = "config/simulation/client_distributions/plausible_best_case_scenario.json"
 
-	// Type of UserQueue strategy to execute.
+	// Type of UserQueue strategy to execute (options in queueOptions).
 	queueType = "capped_bins_queue"
 
-	// Dir with action scripts backing lua driven redis queue.
-	luaQueueDirPath = "redis-lua/bins-queue/noop"
-
-	// Type of RateTracker strategy to execute.
+	// Type of RateTracker strategy to execute (options in trackerOptions).
 	trackerType = "fixed_window"
 
+	// Policy admitting client priority tiers (each tier gets its own queue of type queueType).
+	// One of: {none, strict_priority, weighted_share, reserved_capacity}.
+	priorityTierPolicy = "none"
+
 	// Type of ClientRepo backing our simulator.
 	clientRepoType = "simple_client_repo"
 
@@ -58,55 +41,133 @@ const (
 	// Threshold >= to which we deem intolerable unfairness.
 	maxUnfairnessToleranceSeconds = 15.0
 
+	// Json file with fairness results of the strict FIFO baseline: written by runs with
+	// queueType = "strict_fifo_queue" and compared against by runs of any other queue type.
+	fairnessBaselinePath = "results/strict_fifo_baseline.json"
+
 	// The maximum number of queued requests or responses.
 	maxNetworkIOBacklogSize = 2000
 
-	// Number of Checkout clients generated.
+	// Network carrying requests & responses. One of: {instant, simulated, http, external}.
+	// Simulated networks delay messages by the latency of each client's link_profile, losing some.
+	// The http network sends real HTTP requests to the throttle served at httpListenAddr.
+	// The external network load tests the queue served at externalThrottleBaseURL instead of simulating one.
+	networkModelType = "instant"
+
+	// External queue (e.g. staging) speaking the same HTTP endpoints as servesThrottleOverHTTP (see README).
+	externalThrottleBaseURL = "http://127.0.0.1:8090"
+
+	// Serves a mock FIFO queue at externalThrottleBaseURL (admitting maxCheckoutsAllowedPerWindow per window).
+	startsMockExternalThrottle = true
+
+	// Serves the throttle over HTTP (always the case with the http network, e.g. to point external load tools at).
+	servesThrottleOverHTTP = false
+	httpListenAddr         = "127.0.0.1:8089"
+	httpRequestTimeout     = 30 * time.Second
+
+	// Link profile of clients not configuring one (simulated network only).
+	// One of: {ideal, broadband, mobile_4g, mobile_3g}.
+	defaultLinkProfile = "broadband"
+
+	// Number of Checkout clients generated (default shop).
 	targetNumClients = 2200
 
-	// Max available inventory (simulation terminates when depleted).
+	// Max available inventory (default shop => simulation terminates when all shops depleted).
 	inventoryStockTotal = 9200
 
 	// Flag to enforce random client order (even on special case files).
 	forceRandomClientOrder = false
 
-	// Number of server workers generated.
+	// Seed of the random client order (drawn from the clock if 0). Pin it to compare runs with the fairness baseline.
+	seed = 0
+
+	// Number of server nodes (app servers behind a load balancer) & of workers generated per node.
+	// Nodes keep in-memory queues & trackers of their own (each admitting its share of maxCheckoutsAllowedPerWindow)
+	// whereas Redis-backed queues are shared by every node.
+	numServerNodes   = 1
 	numServerWorkers = 2000
 
+	// Policy routing requests to server nodes. One of: {random, round_robin, sticky}.
+	loadBalancerPolicy = "round_robin"
+
+	// Median time (lognormally distributed with serviceTimeSigma) server workers spend on each request.
+	checkoutServiceTimeMedianMs = 0
+	pollServiceTimeMedianMs     = 0
+	serviceTimeSigma            = 0.5
+
+	// Requests waiting longer than this in the server's accept queue are shed unanswered (never if 0).
+	maxQueueingDelay = 0 * time.Second
+
+	// Cost model: notional server CPU ms per request served & per queue/tracker operation, plus per Redis call
+	// (Lua scripts cost luaScriptCpuMs on top of the call).
+	checkoutEndpointCpuMs = 2.0
+	pollEndpointCpuMs     = 0.5
+	queueOperationCpuMs   = 0.05
+	redisCallCpuMs        = 0.2
+	luaScriptCpuMs        = 0.3
+
+	// Number of simulations run one after another in the same process (each draining before the next starts).
+	numSimulationRuns = 1
+
+	// Time given to server & client workers to return once a simulation completes.
+	drainTimeout = 5 * time.Second
+
+	// Stops simulations after running for checkpointAfter (never if 0) & saves their state to checkpointPath.
+	checkpointAfter = 0 * time.Second
+	checkpointPath  = "results/checkpoint.json"
+
+	// Checkpoint simulations resume from (start afresh if empty). Resuming with other queue parameters than those
+	// checkpointed forks the checkpointed simulation into a what-if continuation.
+	resumeFromPath = ""
+
 	// Redis called to back user queue.
 	redisAddr = "localhost:6379"
 
-	// Specific Model parameters follow:
+	// Whether shop queues sign the state they store in throttle cookies (rejecting tampered cookies).
+	signsThrottleCookies = false
 
-	// Threshold pct <= to which we deem low CPM utilization.
-	lowCheckoutUtilMaxThresholdPct = 0.25
+	// Signed throttle cookie params (cookies not re-signed for longer than max age are deemed replayed):
+	throttleCookieSecret = "goqueuesim-throttle-cookie-secret"
+	throttleCookieMaxAge = 2 * time.Minute
+)
 
-	// Number of observed low util windows skipped before notifying queue.
-	maxSkpdLowCheckoutUtilCount = 15
+// ShopConfig describes one of the concurrently simulated shops.
+type ShopConfig struct {
+	// Json file configuring distribution of Checkout clients for this shop.
+	ClientDistributionJsonPath string
 
-	// Prefix used only to demo shop-scoped namespacing where relevant to API.
-	shopScopePrefixDemo = "shop_id:1"
+	// Number of Checkout clients generated for this shop.
+	NumClients int
 
-	// PollDrivenCappedBinsQueue params:
-	polldrivenMaxTargetPollingUtil     = 2.5
-	polldrivenUtilUpdateInterval       = 100 * time.Millisecond
-	polldrivenWorkingBinUpdateInterval = 1 * time.Second
-	polldrivenLatestPollingUtilWeight  = 0.2
-)
+	// Max available inventory for this shop.
+	InventoryStockTotal int
+}
 
-type Simulator = simulator.SimulationDriver
-type CheckoutThrottleDriver = throttle.CheckoutThrottleDriver
-type ClientConfig = client.ClientConfig
-type NetworkParams = clientfactory.NetworkParams
+// Relative share of each window's checkouts granted per priority tier (weighted_share policy).
+var priorityTierWeights = map[int]float64{0: 1, 1: 3}
 
-// Flag to toggle whether we default (barring special case files) to random client order.
-var shouldRandomizeClientOrder = true
+// Checkouts per window reserved to each priority tier (reserved_capacity policy).
+var priorityTierReservedCheckouts = map[int]int64{1: 50}
 
-func validateParams(skippedLowUtilWindows int) {
-	// FIXME: should validate other params eventually
-	if skippedLowUtilWindows < 0 {
-		panic(fmt.Errorf("maxSkpdLowCheckoutUtilCount should be >= 0 but found %d", skippedLowUtilWindows))
-	}
+// Options of each queue type overriding the type's defaults (run `go run ./cmd/plugins` to list them), e.g.
+// "lottery_queue": {"entry_period": 20 * time.Second, "tier_weights": map[int]float64{1: 2}}.
+var queueOptions = map[string]simulation.Options{
+	"lua_driven_bins_queue": {"lua_queue_dir": "redis-lua/bins-queue/noop"},
+}
+
+// Options of each tracker type overriding the type's defaults.
+var trackerOptions = map[string]simulation.Options{
+	"fixed_window": {"low_util_max_threshold_pct": 0.25, "max_skipped_low_util_windows": 15},
+}
+
+// Shops simulated concurrently: each has its own clients, inventory, queue & tracker
+// while sharing server workers and (for Redis-backed queues) a single Redis.
+var shopConfigs = []ShopConfig{
+	{
+		ClientDistributionJsonPath: clientDistributionJsonPath,
+		NumClients:                 targetNumClients,
+		InventoryStockTotal:        inventoryStockTotal,
+	},
 }
 
 func setLogging(logLevel string) {
@@ -132,254 +193,89 @@ func isNonrandomClientsConfig(distributionFilename string) bool {
 	return false
 }
 
-func configureExperiment() {
-	distributionStrSlice := strings.Split(clientDistributionJsonPath, "/")
-	distributionFilename := strings.TrimSuffix(distributionStrSlice[len(distributionStrSlice)-1], ".json")
-	if isNonrandomClientsConfig(distributionFilename) {
-		shouldRandomizeClientOrder = false
-	}
-	if forceRandomClientOrder {
-		shouldRandomizeClientOrder = true
-	}
-
-	luaStrSlice := strings.Split(luaQueueDirPath, "/")
-	luaDirName := luaStrSlice[len(luaStrSlice)-1]
-
-	clientDistributionTag := fmt.Sprintf("client_distribution:%s", distributionFilename)
-	luaDirTag := fmt.Sprintf("lua_queue_dir:%s", luaDirName)
-	queueTag := fmt.Sprintf("queue_type:%s", queueType)
-	drainRateTrackerTag := fmt.Sprintf("drain_rate_tracker_type:%s", trackerType)
-	clientRepoTag := fmt.Sprintf("client_repo_type:%s", clientRepoType)
-	windowDurSecondsTag := fmt.Sprintf("window_duration_seconds:%.2f", windowDuration.Seconds())
-	maxCheckoutsPerWindowTag := fmt.Sprintf("max_checkouts_per_window:%d", maxCheckoutsAllowedPerWindow)
-	randomizedClientsTag := fmt.Sprintf("client_order_randomized:%t", shouldRandomizeClientOrder)
-	numClientsTag := fmt.Sprintf("num_clients:%d", targetNumClients)
-	numServerWorkersTag := fmt.Sprintf("num_server_workers:%d", numServerWorkers)
-	unfairnessToleranceTag := fmt.Sprintf("unfairness_tolerance_seconds:%.2f", maxUnfairnessToleranceSeconds)
-	tags := []string{
-		clientDistributionTag, luaDirTag, queueTag, drainRateTrackerTag, clientRepoTag,
-		windowDurSecondsTag, maxCheckoutsPerWindowTag, randomizedClientsTag,
-		numClientsTag, numServerWorkersTag, unfairnessToleranceTag,
-	}
-	metrics.AddGlobalTags(tags)
-	metrics.Gauge("window_duration_seconds", windowDuration.Seconds(), nil)
-	metrics.Gauge("max_checkouts_per_window", float64(maxCheckoutsAllowedPerWindow), nil)
-	metrics.Gauge("num_clients", float64(targetNumClients), nil)
-	metrics.Gauge("num_server_workers", float64(numServerWorkers), nil)
-	metrics.Gauge("unfairness_tolerance_seconds", maxUnfairnessToleranceSeconds, nil)
-	fmt.Printf(
-		"\nExecuting with:\n\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n",
-		clientDistributionTag, luaDirTag, queueTag, drainRateTrackerTag, clientRepoTag,
-		numClientsTag, maxCheckoutsPerWindowTag, windowDurSecondsTag,
-		unfairnessToleranceTag, randomizedClientsTag,
-	)
-	fmt.Printf("\nSee dashboard at: %s\n\n", dashboardUrl)
+func distributionFilename(distributionPath string) string {
+	distributionStrSlice := strings.Split(distributionPath, "/")
+	return strings.TrimSuffix(distributionStrSlice[len(distributionStrSlice)-1], ".json")
 }
 
-func loadClientDistributionConfig(filepath string) ([]ClientConfig, int) {
-	var clientDistributionConfig []ClientConfig
-	clientDistributionJson, err := os.Open(filepath)
-	defer clientDistributionJson.Close()
-	if err != nil {
-		panic(fmt.Errorf("failed opening client distribution json at path '%s'", filepath))
-	}
-
-	jsonParser := json.NewDecoder(clientDistributionJson)
-	err = jsonParser.Decode(&clientDistributionConfig)
-	if err != nil {
-		panic(fmt.Errorf("failed parsing client distribution json with error '%s'", err.Error()))
-	}
-	log.Debug().Msg(fmt.Sprintf("ClientDistributionConfig: %v", clientDistributionConfig))
-	actualNumClients := 0
-	for _, clientConfig := range clientDistributionConfig {
-		pct := clientConfig.RepresentationPercent
-		actualNumClients += int(math.Floor(pct * targetNumClients))
-	}
-	if actualNumClients > targetNumClients || actualNumClients < targetNumClients-1 {
-		panic("Client config representationPercent values must sum to 1.0")
-	}
-	return clientDistributionConfig, actualNumClients
-}
-
-func prepareNetworkParams(ctx context.Context, numClients int) NetworkParams {
-	var clientsFinishedWaitGroup sync.WaitGroup
-	clientsFinishedWaitGroup.Add(numClients)
-	return NetworkParams{
-		Ctx:                      ctx,
-		ClientsFinishedWaitGroup: &clientsFinishedWaitGroup,
-		RequestTargetChannel:     make(chan *network_mock.MockRequest, maxNetworkIOBacklogSize),
-		ResponseChannelsMap:      make(map[int]chan *network_mock.MockResponse),
-	}
+// Whether we default (barring special case files) to random client order.
+func shouldRandomizeClientOrder(distributionPath string) bool {
+	return forceRandomClientOrder || !isNonrandomClientsConfig(distributionFilename(distributionPath))
 }
 
-func makeMockCheckoutClients(
-	clientDistributionConfig []ClientConfig,
-	networkParams NetworkParams,
-	shouldRandomizeOrder bool,
-) []client.Client {
-	checkoutClients := make([]client.Client, 0, targetNumClients)
-	numClientsFloat := float64(targetNumClients)
-	id := 1
-	for _, clientConfig := range clientDistributionConfig {
-		numToGenerate := int(math.Floor(clientConfig.RepresentationPercent * numClientsFloat))
-		for j := 0; j < numToGenerate; j++ {
-			c := clientfactory.MakeClientFromConfig(clientConfig, networkParams, id)
-			checkoutClients = append(checkoutClients, c)
-			networkParams.ResponseChannelsMap[id] =
-				make(chan *network_mock.MockResponse, maxNetworkIOBacklogSize)
-			id++
-		}
-	}
-	if shouldRandomizeOrder {
-		rand.Seed(time.Now().UnixNano())
-		rand.Shuffle(len(checkoutClients), func(i, j int) {
-			checkoutClients[i], checkoutClients[j] = checkoutClients[j], checkoutClients[i]
+// The simulation configured by the constants above (metrics sent to the local datadog agent).
+func simulationConfig() simulation.Config {
+	shops := make([]simulation.ShopConfig, 0, len(shopConfigs))
+	for _, shopConfig := range shopConfigs {
+		shops = append(shops, simulation.ShopConfig{
+			ClientDistributionJsonPath: shopConfig.ClientDistributionJsonPath,
+			NumClients:                 shopConfig.NumClients,
+			InventoryStockTotal:        shopConfig.InventoryStockTotal,
+			RandomizeClientOrder:       shouldRandomizeClientOrder(shopConfig.ClientDistributionJsonPath),
 		})
 	}
-	return checkoutClients
-}
-
-func makeRedisClient(redisAddr string) (*redis.Client, error) {
-	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr})
-	_, pingErr := redisClient.Ping().Result()
-	return redisClient, pingErr
-}
+	return simulation.Config{
+		Shops: shops,
 
-func prepareLuaQueueConstants() lua_queue.LuaQueueConstants {
-	return lua_queue.LuaQueueConstants{
 		QueueType:                    queueType,
-		ShopScopePrefix:              shopScopePrefixDemo,
-		MaxCheckoutsAllowedPerWindow: maxCheckoutsAllowedPerWindow,
+		QueueOptions:                 queueOptions[queueType],
+		TrackerType:                  trackerType,
+		TrackerOptions:               trackerOptions[trackerType],
 		WindowDuration:               windowDuration,
+		MaxCheckoutsAllowedPerWindow: maxCheckoutsAllowedPerWindow,
 
-		PollDrivenMaxTargetPollingUtil:     polldrivenMaxTargetPollingUtil,
-		PollDrivenUtilUpdateInterval:       polldrivenUtilUpdateInterval,
-		PollDrivenWorkingBinUpdateInterval: polldrivenWorkingBinUpdateInterval,
-		PollDrivenLatestPollingUtilWeight:  polldrivenLatestPollingUtilWeight,
-	}
-}
-
-func makeUserQueue(
-	ctx context.Context,
-	startSignalWaitGroup *sync.WaitGroup,
-	redisClient *redis.Client,
-	globalInventoryCounter *common.AtomicCounter,
-	luaQueueParams *lua_queue.LuaQueueParams,
-) queue.Queue {
-	switch queueType {
-	case "noop_queue":
-		return queuefactory.MakeNoopQueue()
-	case "sorted_set":
-		return queuefactory.MakeSortedSetQueue(redisClient, int64(maxCheckoutsAllowedPerWindow))
-	case "capped_bins_queue":
-		return queuefactory.MakeCappedBinsQueue(int64(maxCheckoutsAllowedPerWindow))
-	case "interval_bins_queue":
-		return queuefactory.MakeIntervalBinsQueue(
-			ctx, startSignalWaitGroup, windowDuration,
-			maxCheckoutsAllowedPerWindow, 2000*time.Millisecond,
-		)
-	case "polldriven_capped_bins_queue":
-		return queuefactory.MakePollDrivenCappedBinsQueue(
-			startSignalWaitGroup,
-			maxCheckoutsAllowedPerWindow,
-			windowDuration,
-			polldrivenMaxTargetPollingUtil,
-			polldrivenUtilUpdateInterval,
-			polldrivenWorkingBinUpdateInterval,
-			polldrivenLatestPollingUtilWeight,
-		)
-	case "lua_driven_bins_queue":
-		return queuefactory.MakeLuaDrivenBinsQueue(
-			redisClient,
-			luaQueueParams.LuaMethodToShaMap,
-			luaQueueParams.ShopScopePrefix,
-			luaQueueParams.LuaMethodToShopScopePrefixedKeys,
-			luaQueueParams.LuaMethodToClientScopeNonPrefixedKeys,
-			luaQueueParams.LuaMethodToConstantArgsMap,
-			luaQueueParams.KeysBuilder,
-			luaQueueParams.ArgsBuilder,
-			luaQueueParams.Postprocessor,
-			globalInventoryCounter,
-		)
-	default:
-		panic(fmt.Errorf("queue type must be one of: {sorted_set, capped_bins_queue}"))
-	}
-}
-
-func makeRateTracker(
-	ctx context.Context,
-	startSignalWaitGroup *sync.WaitGroup,
-	windowDuration time.Duration,
-	maxAllowed uint64,
-) tracker.Tracker {
-	switch trackerType {
-	case "fixed_window":
-		t := trackerfactory.MakeFixedWindowTracker(ctx, startSignalWaitGroup, windowDuration, maxAllowed)
-		go t.MonitorAndEmitFeedback(lowCheckoutUtilMaxThresholdPct, maxSkpdLowCheckoutUtilCount)
-		return t
-	default:
-		panic(fmt.Errorf("tracker type must be one of: {fixed_window}"))
-	}
-}
-
-func makeCheckoutThrottleDriver(
-	ctx context.Context,
-	ctxCancelFunc context.CancelFunc,
-	startSignalWaitGroup *sync.WaitGroup,
-	userQueue queue.Queue,
-	rateTracker tracker.Tracker,
-	globalInventoryCounter *common.AtomicCounter,
-) *CheckoutThrottleDriver {
-	t := &CheckoutThrottleDriver{
-		Ctx:                    ctx,
-		CtxCancelFunc:          ctxCancelFunc,
-		StartSignalWaitGroup:   startSignalWaitGroup,
-		ThrottleQueue:          userQueue,
-		RateTracker:            rateTracker,
-		GlobalInventoryCounter: globalInventoryCounter,
-	}
-	// Drive utilization tracking via background goroutine rather than rely on client polling.
-	go t.MonitorUtilAndNotifyQueue()
-	return t
-}
-
-func makeClientRepo(clientRepoType string) simulator.ClientRepo {
-	switch clientRepoType {
-	case "simple_client_repo":
-		return simulator.MakeSimpleClientRepo(make(map[int]client.Client))
-	default:
-		panic(fmt.Errorf("clientRepo type must be one of: {simple_client_repo}"))
+		PriorityTierPolicy:            priorityTierPolicy,
+		PriorityTierWeights:           priorityTierWeights,
+		PriorityTierReservedCheckouts: priorityTierReservedCheckouts,
+
+		SignsThrottleCookies: signsThrottleCookies,
+		ThrottleCookieSecret: throttleCookieSecret,
+		ThrottleCookieMaxAge: throttleCookieMaxAge,
+
+		ClientRepoType:                clientRepoType,
+		MaxUnfairnessToleranceSeconds: maxUnfairnessToleranceSeconds,
+		FairnessBaselinePath:          fairnessBaselinePath,
+		Seed:                          seed,
+
+		MaxNetworkIOBacklogSize:    maxNetworkIOBacklogSize,
+		NetworkModelType:           networkModelType,
+		DefaultLinkProfile:         defaultLinkProfile,
+		ExternalThrottleBaseURL:    externalThrottleBaseURL,
+		StartsMockExternalThrottle: startsMockExternalThrottle,
+		ServesThrottleOverHTTP:     servesThrottleOverHTTP,
+		HTTPListenAddr:             httpListenAddr,
+		HTTPRequestTimeout:         httpRequestTimeout,
+
+		NumServerNodes:     numServerNodes,
+		NumServerWorkers:   numServerWorkers,
+		LoadBalancerPolicy: loadBalancerPolicy,
+
+		CheckoutServiceTimeMedianMs: checkoutServiceTimeMedianMs,
+		PollServiceTimeMedianMs:     pollServiceTimeMedianMs,
+		ServiceTimeSigma:            serviceTimeSigma,
+		MaxQueueingDelay:            maxQueueingDelay,
+
+		CheckoutEndpointCpuMs: checkoutEndpointCpuMs,
+		PollEndpointCpuMs:     pollEndpointCpuMs,
+		QueueOperationCpuMs:   queueOperationCpuMs,
+		RedisCallCpuMs:        redisCallCpuMs,
+		LuaScriptCpuMs:        luaScriptCpuMs,
+
+		RedisAddr:    redisAddr,
+		DrainTimeout: drainTimeout,
+
+		CheckpointAfter: checkpointAfter,
+		CheckpointPath:  checkpointPath,
+		ResumeFromPath:  resumeFromPath,
+
+		MetricsSink: metrics.MakeStatsdSink(metrics.DefaultStatsdAddr),
 	}
 }
 
-func makeSimulator(
-	ctx context.Context,
-	ctxCancelFunc context.CancelFunc,
-	throttleDriver *throttle.CheckoutThrottleDriver,
-	checkoutClients []client.Client,
-	numServerWorkers int,
-	inventoryStockTotal int,
-	requestTargetChannel chan *network_mock.MockRequest,
-	responseChannelsMap map[int]chan *network_mock.MockResponse,
-	maxUnfairnessToleranceSeconds float64,
-	clientsFinishedWaitGroup *sync.WaitGroup,
-	clientRepo simulator.ClientRepo,
-	startSignalWaitGroup *sync.WaitGroup,
-) *Simulator {
-	simDriver := &Simulator{
-		Ctx:                                ctx,
-		CtxCancelFunc:                      ctxCancelFunc,
-		CheckoutThrottleDriver:             throttleDriver,
-		Clients:                            checkoutClients,
-		NumServerWorkers:                   numServerWorkers,
-		InventoryStockTotal:                inventoryStockTotal,
-		RequestTargetChannel:               requestTargetChannel,
-		ResponseChannelsMap:                responseChannelsMap,
-		SimulationCompletedListenerChannel: make(chan struct{}),
-		StartSignalWaitGroup:               startSignalWaitGroup,
-		ClientsFinishedWaitGroup:           clientsFinishedWaitGroup,
-		ClientRepo:                         clientRepo,
-		MaxUnfairnessToleranceSeconds:      maxUnfairnessToleranceSeconds,
+func printExperiment(cfg simulation.Config) {
+	fmt.Printf("\nExecuting with:\n\n")
+	for _, tag := range cfg.ExperimentTags() {
+		fmt.Println(tag)
 	}
-	return simDriver
+	fmt.Printf("\nSee dashboard at: %s\n\n", dashboardUrl)
 }
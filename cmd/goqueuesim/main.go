@@ -5,10 +5,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"sync"
 
-	"github.com/Shopify/goqueuesim/internal/common"
-	"github.com/Shopify/goqueuesim/internal/lua_config"
+	"github.com/Shopify/goqueuesim/simulation"
 
 	"github.com/rs/zerolog/log"
 )
@@ -24,53 +22,21 @@ func main() {
 	// Start goroutine to listen for interrupt signal => if received, cancel running context.
 	go func() { <-sig; log.Info().Msg("shutting down simulator"); cancel() }()
 
-	var startSignalWaitGroup sync.WaitGroup
-	startSignalWaitGroup.Add(1)
-
-	validateParams(maxSkpdLowCheckoutUtilCount)
 	setLogging(logLevel)
-	configureExperiment()
-	clientsConfig, actualNumClients := loadClientDistributionConfig(clientDistributionJsonPath)
-	networkParams := prepareNetworkParams(ctx, actualNumClients)
 
-	// Prepare throttle simulation configured for target params.
-	checkoutClients := makeMockCheckoutClients(clientsConfig, networkParams, shouldRandomizeClientOrder)
-	clientRepo := makeClientRepo(clientRepoType)
+	sim, err := simulation.FromConfig(simulationConfig()).Build()
+	if err != nil {
+		panic(err)
+	}
 
-	luaQueueConstants := prepareLuaQueueConstants()
-	luaQueueParams := lua_config.SetDefaultLuaQueueParams()
-	redisClient, redisErr := makeRedisClient(redisAddr)
-	if luaQueueConstants.QueueType == "lua_driven_bins_queue" {
-		if redisErr != nil {
-			panic(fmt.Errorf("Redis is required for Lua queues but failed to respond a ping request"))
+	// Each run drains before returning => runs start afresh from one another (until interrupted).
+	for run := 1; run <= numSimulationRuns && ctx.Err() == nil; run++ {
+		if numSimulationRuns > 1 {
+			fmt.Printf("\nSimulation run %d/%d\n", run, numSimulationRuns)
+		}
+		printExperiment(sim.Config())
+		if _, err := sim.Run(ctx); err != nil {
+			panic(err)
 		}
-		luaQueueParams = lua_config.ConfigureRedisLua(luaQueueDirPath, redisClient, luaQueueConstants)
 	}
-
-	globalInventoryCounter := common.AtomicCounter{Count: inventoryStockTotal}
-	userQueue := makeUserQueue(ctx, &startSignalWaitGroup, redisClient, &globalInventoryCounter, luaQueueParams)
-	userQueue.Clear()
-
-	rateTracker := makeRateTracker(ctx, &startSignalWaitGroup, windowDuration, maxCheckoutsAllowedPerWindow)
-
-	checkoutThrottleDriver := makeCheckoutThrottleDriver(
-		ctx, cancel, &startSignalWaitGroup, userQueue, rateTracker, &globalInventoryCounter,
-	)
-
-	simDriver := makeSimulator(
-		ctx,
-		cancel,
-		checkoutThrottleDriver,
-		checkoutClients,
-		numServerWorkers,
-		inventoryStockTotal,
-		networkParams.RequestTargetChannel,
-		networkParams.ResponseChannelsMap,
-		maxUnfairnessToleranceSeconds,
-		networkParams.ClientsFinishedWaitGroup,
-		clientRepo,
-		&startSignalWaitGroup,
-	)
-
-	simDriver.StartSimulation()
 }
@@ -0,0 +1,49 @@
+// Command plugins prints every registered queue type, tracker type, client repo type & client type along with the
+// options each accepts (their kind, default & bounds).
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	clientfactory "github.com/Shopify/goqueuesim/internal/client/impl"
+	"github.com/Shopify/goqueuesim/internal/registry"
+	"github.com/Shopify/goqueuesim/internal/simulator"
+	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
+	trackerfactory "github.com/Shopify/goqueuesim/internal/throttle/tracker/impl"
+)
+
+func main() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	printPlugins(w, "Queue types (queueType & queueOptions)", queuefactory.QueueTypes())
+	printPlugins(w, "Tracker types (trackerType & trackerOptions)", trackerfactory.TrackerTypes())
+	printPlugins(w, "Client repo types (clientRepoType)", simulator.ClientRepoTypes())
+	fmt.Fprintln(w, "Client types (client_type of client distributions, run `go run ./cmd/clientschema` for their properties):")
+	for _, schema := range clientfactory.ClientTypeSchemas() {
+		fmt.Fprintf(w, "  %s\t%s\n", schema.ClientType, schema.Description)
+	}
+	w.Flush()
+}
+
+func printPlugins(w *tabwriter.Writer, title string, plugins []registry.Plugin) {
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, plugin := range plugins {
+		fmt.Fprintf(w, "\n  %s: %s\n", plugin.Name, plugin.Description)
+		if len(plugin.Options) == 0 {
+			fmt.Fprintln(w, "    (no options)")
+		}
+		for _, option := range plugin.Options {
+			constraint := option.Range()
+			if len(option.OneOf) > 0 {
+				constraint = fmt.Sprintf("{%s}", strings.Join(option.OneOf, ", "))
+			}
+			fmt.Fprintf(
+				w, "    %s\t%s\tdefault %v\t%s\t%s\n",
+				option.Name, option.Kind, option.Default, constraint, option.Description,
+			)
+		}
+	}
+	fmt.Fprintln(w)
+}
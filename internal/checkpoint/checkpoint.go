@@ -0,0 +1,87 @@
+// Package checkpoint snapshots the state of a drained simulation so that it may be resumed later.
+//
+// Simulations run in real time, so every timestamp is saved as an offset on the simulation clock (time elapsed
+// since the simulation started) & restored relative to the resumed run's own start.
+package checkpoint
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Clock maps wall clock times to offsets on the simulation clock & back.
+type Clock struct {
+	// Wall clock time at which the simulation (as resumed) started.
+	Epoch time.Time
+}
+
+// Offset of t on the simulation clock (nil for the zero time).
+func (c Clock) Offset(t time.Time) *time.Duration {
+	if t.IsZero() {
+		return nil
+	}
+	offset := t.Sub(c.Epoch)
+	return &offset
+}
+
+// Wall clock time of offset on the simulation clock (zero time for nil).
+func (c Clock) Time(offset *time.Duration) time.Time {
+	if offset == nil {
+		return time.Time{}
+	}
+	return c.Epoch.Add(*offset)
+}
+
+// Checkpointer is implemented by queues & trackers able to snapshot their internals & restore them.
+type Checkpointer interface {
+	Checkpoint(clock Clock) (json.RawMessage, error)
+	Restore(state json.RawMessage, clock Clock) error
+}
+
+// ClientState is the session of one client, throttle cookie & pending timers included.
+type ClientState struct {
+	Id             int
+	Label          string
+	ThrottleState  string
+	ThrottleCookie map[string]string `json:",omitempty"`
+
+	QueueEntry                *time.Duration `json:",omitempty"`
+	QueueExit                 *time.Duration `json:",omitempty"`
+	AdmissionNotice           *time.Duration `json:",omitempty"`
+	AdvisedPollAfter          *time.Duration `json:",omitempty"`
+	EstimatedAdmission        *time.Duration `json:",omitempty"`
+	InitialEstimatedAdmission *time.Duration `json:",omitempty"`
+	QueuePosition             int64
+
+	// When the initial checkout request of clients yet to be queued is (or was) due.
+	InitialRequest *time.Duration `json:",omitempty"`
+
+	// When the next poll of polling clients is due & whether they will still be polling by then.
+	NextPoll     *time.Duration `json:",omitempty"`
+	KeepsPolling bool           `json:",omitempty"`
+	PollsSoFar   int            `json:",omitempty"`
+
+	NumReloads      int  `json:",omitempty"`
+	HitCheckoutStep bool `json:",omitempty"`
+	Abandoned       bool `json:",omitempty"`
+
+	// Traits drawn when the client was made (values sampled from property_distributions, clock skew & patience),
+	// restored so that resumed clients behave as they would have.
+	SampledProperties map[string]float64 `json:",omitempty"`
+	ClockOffset       time.Duration      `json:",omitempty"`
+	Patience          time.Duration      `json:",omitempty"`
+	NeverAbandons     bool               `json:",omitempty"`
+
+	// Tampering progress of cookie tampering clients.
+	TamperedPolls int               `json:",omitempty"`
+	StaleCookie   map[string]string `json:",omitempty"`
+
+	// Session the person behind a multi-session client checked out with (0 while none was admitted).
+	SessionGroupWinner int `json:",omitempty"`
+}
+
+// ClientCheckpointer is implemented by clients able to snapshot their session & resume it.
+type ClientCheckpointer interface {
+	CheckpointSession(clock Clock) ClientState
+	RestoreSession(state ClientState, clock Clock) error
+}
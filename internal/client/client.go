@@ -8,13 +8,17 @@ import (
 
 type Client interface {
 	ID() int
+	PersonID() int
+	ShopID() int
 	Label() string
+	PriorityTier() int
 	Lock()
 	Unlock()
 
 	QueueEntryTime() time.Time
 	QueueExitTime() time.Time
 	QueueDuration() time.Duration
+	AdmissionNoticeDelay() time.Duration
 
 	SetThrottleCookieVal(key, value string) error
 	GetThrottleCookieVal(key string) (string, bool)
@@ -25,13 +29,25 @@ type Client interface {
 	InCheckout() bool
 	ReachedCheckout() bool
 	HasExited() bool
+	Abandoned() bool
 	MarkQueued() error
 	MarkInCheckout() error
 	MarkExited() error
+	MarkAbandoned() error
+	Reload(keepThrottleCookie bool) error
+	NumReloads() int
+
+	// Listener notified (client locked) when the client leaves the queue without being admitted.
+	SetQueueLeaveListener(listener func(c Client))
 
 	AdvisePollAfter(t time.Time) error
 	AdvisedPollAfter() time.Time
 
+	AdviseQueuePosition(pos int64, estimatedAdmission time.Time) error
+	QueuePosition() int64
+	EstimatedAdmissionTime() time.Time
+	InitialEstimatedWait() (time.Duration, bool)
+
 	ThrottleState() ThrottleState
 	SessionData() network_mock.SessionData
 
@@ -4,6 +4,7 @@ type ClientConfig struct {
 	RepresentationPercent  float64            `json:"representation_percent"`
 	ClientType             string             `json:"client_type"`
 	HumanizedLabel         string             `json:"humanized_label"`
+	PriorityTier           int                `json:"priority_tier"`
 	ObeysServerPollAfter   bool               `json:"obeys_server_poll_after"`
 	MaxInitialDelayMs      int                `json:"max_initial_delay_ms"`
 	MaxNetworkJitterMs     int                `json:"max_network_jitter_ms"`
@@ -11,4 +12,42 @@ type ClientConfig struct {
 	CustomIntProperties    map[string]int     `json:"custom_int_properties"`
 	CustomFloatProperties  map[string]float64 `json:"custom_float_properties"`
 	CustomBoolProperties   map[string]bool    `json:"custom_bool_properties"`
+
+	// Exact number of clients (persons) generated, instead of a representation_percent of those left uncounted.
+	Count int `json:"count"`
+
+	// Entries splitting this one's clients (e.g. a "mobile" group across behaviors), inheriting its settings.
+	Mixture []ClientConfig `json:"mixture"`
+
+	// Numeric custom properties sampled per client rather than fixed (e.g. dflt_poll_interval_seconds ~ lognormal).
+	PropertyDistributions map[string]Distribution `json:"property_distributions"`
+
+	// Delay before the initial checkout request, sampled per client (replaces max_initial_delay_ms).
+	InitialDelayMs *Distribution `json:"initial_delay_ms"`
+	// Network jitter, sampled per request (replaces max_network_jitter_ms).
+	NetworkJitterMs *Distribution `json:"network_jitter_ms"`
+	// Interval between routine polls, sampled per poll (replaces dflt_poll_interval_seconds).
+	PollIntervalSeconds *Distribution `json:"poll_interval_seconds"`
+
+	// Network link the client connects over (e.g. mobile_3g) when the network is simulated.
+	LinkProfile string `json:"link_profile"`
+
+	// Values drawn from PropertyDistributions once sampled for a client (by property name).
+	SampledProperties map[string]float64 `json:"-"`
+}
+
+// Distribution of the delay before the initial checkout request: uniform in [0, max_initial_delay_ms) unless given.
+func (c ClientConfig) InitialDelayDistribution() Distribution {
+	if c.InitialDelayMs != nil {
+		return *c.InitialDelayMs
+	}
+	return UniformUpTo(float64(c.MaxInitialDelayMs))
+}
+
+// Distribution of per request network jitter: uniform in [0, max_network_jitter_ms) unless given.
+func (c ClientConfig) NetworkJitterDistribution() Distribution {
+	if c.NetworkJitterMs != nil {
+		return *c.NetworkJitterMs
+	}
+	return UniformUpTo(float64(c.MaxNetworkJitterMs))
 }
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const representationPercentTolerance = 0.001
+
+// ResolveClientDistribution flattens nested mixtures into leaf entries (inheriting their groups' settings), each
+// assigned an exact Count. Explicit counts are honoured first & the clients left over (targetNumClients at the top
+// level unless every entry is counted, a group's own count within its mixture) are apportioned by
+// representation_percent using largest remainders, so that none get dropped to rounding.
+func ResolveClientDistribution(configs []ClientConfig, targetNumClients int) ([]ClientConfig, error) {
+	allCounted := len(configs) > 0
+	total := 0
+	for _, config := range configs {
+		allCounted = allCounted && config.Count > 0
+		total += config.Count
+	}
+	if !allCounted {
+		total = targetNumClients
+	}
+	return resolveMixture(configs, total, "")
+}
+
+func resolveMixture(configs []ClientConfig, total int, path string) ([]ClientConfig, error) {
+	counts, err := apportion(configs, total, path)
+	if err != nil {
+		return nil, err
+	}
+	leaves := make([]ClientConfig, 0, len(configs))
+	for i, config := range configs {
+		config.Count = counts[i]
+		if len(config.Mixture) == 0 {
+			leaves = append(leaves, config)
+			continue
+		}
+		children := make([]ClientConfig, 0, len(config.Mixture))
+		for _, child := range config.Mixture {
+			children = append(children, inherit(config, child))
+		}
+		subLeaves, err := resolveMixture(children, config.Count, fmt.Sprintf("%smixture of entry %d: ", path, i))
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, subLeaves...)
+	}
+	return leaves, nil
+}
+
+// Number of clients of each entry out of total.
+func apportion(configs []ClientConfig, total int, path string) ([]int, error) {
+	counts := make([]int, len(configs))
+	remaining := total
+	pctSum := 0.0
+	for i, config := range configs {
+		if config.Count < 0 {
+			return nil, fmt.Errorf("%sentry %d: count must be >= 0, got %d", path, i, config.Count)
+		}
+		counts[i] = config.Count
+		remaining -= config.Count
+		if config.Count == 0 {
+			pctSum += config.RepresentationPercent
+		}
+	}
+	if remaining < 0 {
+		return nil, fmt.Errorf("%scounts add up to %d clients, more than the %d available", path, total-remaining, total)
+	}
+	if remaining == 0 {
+		return counts, nil
+	}
+	if math.Abs(pctSum-1) > representationPercentTolerance {
+		return nil, fmt.Errorf(
+			"%srepresentation_percent values of entries without a count must sum to 1.0 (to share %d clients), got %v",
+			path, remaining, pctSum,
+		)
+	}
+	type share struct {
+		idx       int
+		remainder float64
+	}
+	shares := make([]share, 0, len(configs))
+	assigned := 0
+	for i, config := range configs {
+		if config.Count > 0 {
+			continue
+		}
+		exact := config.RepresentationPercent / pctSum * float64(remaining)
+		counts[i] = int(math.Floor(exact))
+		assigned += counts[i]
+		shares = append(shares, share{idx: i, remainder: exact - math.Floor(exact)})
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].remainder > shares[j].remainder })
+	for i := 0; assigned < remaining; i++ {
+		counts[shares[i%len(shares)].idx]++
+		assigned++
+	}
+	return counts, nil
+}
+
+// Returns child with the zero-valued settings it leaves unspecified taken from its group.
+func inherit(group, child ClientConfig) ClientConfig {
+	if child.ClientType == "" {
+		child.ClientType = group.ClientType
+	}
+	if group.HumanizedLabel != "" && child.HumanizedLabel != "" {
+		child.HumanizedLabel = group.HumanizedLabel + "/" + child.HumanizedLabel
+	} else if child.HumanizedLabel == "" {
+		child.HumanizedLabel = group.HumanizedLabel
+	}
+	if child.PriorityTier == 0 {
+		child.PriorityTier = group.PriorityTier
+	}
+	child.ObeysServerPollAfter = child.ObeysServerPollAfter || group.ObeysServerPollAfter
+	if child.InitialDelayMs == nil && child.MaxInitialDelayMs == 0 {
+		child.InitialDelayMs = group.InitialDelayMs
+		child.MaxInitialDelayMs = group.MaxInitialDelayMs
+	}
+	if child.NetworkJitterMs == nil && child.MaxNetworkJitterMs == 0 {
+		child.NetworkJitterMs = group.NetworkJitterMs
+		child.MaxNetworkJitterMs = group.MaxNetworkJitterMs
+	}
+	if child.PollIntervalSeconds == nil {
+		child.PollIntervalSeconds = group.PollIntervalSeconds
+	}
+	if child.LinkProfile == "" {
+		child.LinkProfile = group.LinkProfile
+	}
+	// Whichever of a fixed value or a distribution the child gives for a property overrides the group's.
+	distributions := make(map[string]Distribution, len(group.PropertyDistributions)+len(child.PropertyDistributions))
+	for name, d := range group.PropertyDistributions {
+		_, fixedInt := child.CustomIntProperties[name]
+		_, fixedFloat := child.CustomFloatProperties[name]
+		if !fixedInt && !fixedFloat {
+			distributions[name] = d
+		}
+	}
+	for name, d := range child.PropertyDistributions {
+		distributions[name] = d
+	}
+	intProperties := mergeIntProperties(group.CustomIntProperties, child.CustomIntProperties)
+	floatProperties := mergeFloatProperties(group.CustomFloatProperties, child.CustomFloatProperties)
+	for name := range child.PropertyDistributions {
+		if _, fixed := child.CustomIntProperties[name]; !fixed {
+			delete(intProperties, name)
+		}
+		if _, fixed := child.CustomFloatProperties[name]; !fixed {
+			delete(floatProperties, name)
+		}
+	}
+	child.CustomStringProperties = mergeStringProperties(group.CustomStringProperties, child.CustomStringProperties)
+	child.CustomIntProperties = intProperties
+	child.CustomFloatProperties = floatProperties
+	child.CustomBoolProperties = mergeBoolProperties(group.CustomBoolProperties, child.CustomBoolProperties)
+	child.PropertyDistributions = distributions
+	return child
+}
+
+func mergeStringProperties(group, child map[string]string) map[string]string {
+	merged := make(map[string]string, len(group)+len(child))
+	for k, v := range group {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
+
+func mergeIntProperties(group, child map[string]int) map[string]int {
+	merged := make(map[string]int, len(group)+len(child))
+	for k, v := range group {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
+
+func mergeFloatProperties(group, child map[string]float64) map[string]float64 {
+	merged := make(map[string]float64, len(group)+len(child))
+	for k, v := range group {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
+
+func mergeBoolProperties(group, child map[string]bool) map[string]bool {
+	merged := make(map[string]bool, len(group)+len(child))
+	for k, v := range group {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Counts resolved for each leaf, by label.
+func resolvedCounts(t *testing.T, configs []ClientConfig, targetNumClients int) map[string]int {
+	t.Helper()
+	leaves, err := ResolveClientDistribution(configs, targetNumClients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counts := make(map[string]int, len(leaves))
+	for _, leaf := range leaves {
+		counts[leaf.HumanizedLabel] = leaf.Count
+	}
+	return counts
+}
+
+func TestResolveClientDistributionCounts(t *testing.T) {
+	tests := []struct {
+		name             string
+		configs          []ClientConfig
+		targetNumClients int
+		want             map[string]int
+	}{
+		{
+			name: "percentages",
+			configs: []ClientConfig{
+				{HumanizedLabel: "a", RepresentationPercent: 0.5},
+				{HumanizedLabel: "b", RepresentationPercent: 0.3},
+				{HumanizedLabel: "c", RepresentationPercent: 0.2},
+			},
+			targetNumClients: 10,
+			want:             map[string]int{"a": 5, "b": 3, "c": 2},
+		},
+		{
+			name: "largest remainders drop no client",
+			configs: []ClientConfig{
+				{HumanizedLabel: "a", RepresentationPercent: 1.0 / 3},
+				{HumanizedLabel: "b", RepresentationPercent: 1.0 / 3},
+				{HumanizedLabel: "c", RepresentationPercent: 1.0 / 3},
+			},
+			targetNumClients: 10,
+			want:             map[string]int{"a": 4, "b": 3, "c": 3},
+		},
+		{
+			name: "percentages within tolerance are normalized",
+			configs: []ClientConfig{
+				{HumanizedLabel: "a", RepresentationPercent: 0.6005},
+				{HumanizedLabel: "b", RepresentationPercent: 0.4},
+			},
+			targetNumClients: 1000,
+			want:             map[string]int{"a": 600, "b": 400},
+		},
+		{
+			name: "counts first, percentages share the rest",
+			configs: []ClientConfig{
+				{HumanizedLabel: "a", Count: 4},
+				{HumanizedLabel: "b", RepresentationPercent: 0.5},
+				{HumanizedLabel: "c", RepresentationPercent: 0.5},
+			},
+			targetNumClients: 10,
+			want:             map[string]int{"a": 4, "b": 3, "c": 3},
+		},
+		{
+			name: "counts alone override the target",
+			configs: []ClientConfig{
+				{HumanizedLabel: "a", Count: 2},
+				{HumanizedLabel: "b", Count: 5},
+			},
+			targetNumClients: 100,
+			want:             map[string]int{"a": 2, "b": 5},
+		},
+		{
+			name: "nested mixture",
+			configs: []ClientConfig{
+				{
+					HumanizedLabel:        "mobile",
+					RepresentationPercent: 0.4,
+					Mixture: []ClientConfig{
+						{HumanizedLabel: "patient", RepresentationPercent: 0.75},
+						{HumanizedLabel: "impatient", RepresentationPercent: 0.25},
+					},
+				},
+				{HumanizedLabel: "desktop", RepresentationPercent: 0.6},
+			},
+			targetNumClients: 20,
+			want:             map[string]int{"mobile/patient": 6, "mobile/impatient": 2, "desktop": 12},
+		},
+	}
+	for _, tt := range tests {
+		if got := resolvedCounts(t, tt.configs, tt.targetNumClients); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveClientDistributionRejectsBadSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []ClientConfig
+		wantErr string
+	}{
+		{
+			name: "percentages not summing to 1",
+			configs: []ClientConfig{
+				{RepresentationPercent: 0.5},
+				{RepresentationPercent: 0.2},
+			},
+			wantErr: "representation_percent values of entries without a count must sum to 1.0",
+		},
+		{
+			name: "negative count",
+			configs: []ClientConfig{
+				{Count: -1},
+				{RepresentationPercent: 1},
+			},
+			wantErr: "entry 0: count must be >= 0, got -1",
+		},
+		{
+			name: "counts exceeding the target",
+			configs: []ClientConfig{
+				{Count: 20},
+				{RepresentationPercent: 1},
+			},
+			wantErr: "counts add up to 20 clients, more than the 10 available",
+		},
+		{
+			name: "bad nested mixture",
+			configs: []ClientConfig{
+				{RepresentationPercent: 1, Mixture: []ClientConfig{{RepresentationPercent: 0.9}}},
+			},
+			wantErr: "mixture of entry 0: representation_percent values",
+		},
+	}
+	for _, tt := range tests {
+		_, err := ResolveClientDistribution(tt.configs, 10)
+		if err == nil {
+			t.Errorf("%s: got no error, want %q", tt.name, tt.wantErr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %q, want %q", tt.name, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestResolveClientDistributionInheritsGroupSettings(t *testing.T) {
+	jitter := UniformUpTo(50)
+	group := ClientConfig{
+		HumanizedLabel:        "loyal",
+		ClientType:            "impatient",
+		PriorityTier:          1,
+		RepresentationPercent: 1,
+		NetworkJitterMs:       &jitter,
+		CustomIntProperties:   map[string]int{"patience_seconds": 60, "max_reloads": 2},
+		PropertyDistributions: map[string]Distribution{
+			"dflt_poll_interval_seconds": {Type: "constant", Value: 5},
+		},
+		Mixture: []ClientConfig{
+			{
+				HumanizedLabel:        "fast",
+				RepresentationPercent: 1,
+				CustomIntProperties:   map[string]int{"dflt_poll_interval_seconds": 1},
+				PropertyDistributions: map[string]Distribution{
+					"patience_seconds": {Type: "uniform", Min: 10, Max: 20},
+				},
+			},
+		},
+	}
+	leaves, err := ResolveClientDistribution([]ClientConfig{group}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(leaves) != 1 {
+		t.Fatalf("got %d leaves, want 1", len(leaves))
+	}
+	leaf := leaves[0]
+	if leaf.HumanizedLabel != "loyal/fast" || leaf.ClientType != "impatient" || leaf.PriorityTier != 1 {
+		t.Errorf("got %+v, want group settings inherited", leaf)
+	}
+	if leaf.Count != 3 {
+		t.Errorf("got count %d, want 3", leaf.Count)
+	}
+	if leaf.NetworkJitterMs == nil || !reflect.DeepEqual(*leaf.NetworkJitterMs, jitter) {
+		t.Errorf("got network jitter %v, want %v", leaf.NetworkJitterMs, jitter)
+	}
+	// The child's fixed values & distributions override whichever the group gave for the same property.
+	wantIntProperties := map[string]int{"max_reloads": 2, "dflt_poll_interval_seconds": 1}
+	if !reflect.DeepEqual(leaf.CustomIntProperties, wantIntProperties) {
+		t.Errorf("got int properties %v, want %v", leaf.CustomIntProperties, wantIntProperties)
+	}
+	wantDistributions := map[string]Distribution{"patience_seconds": {Type: "uniform", Min: 10, Max: 20}}
+	if !reflect.DeepEqual(leaf.PropertyDistributions, wantDistributions) {
+		t.Errorf("got property distributions %v, want %v", leaf.PropertyDistributions, wantDistributions)
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Distribution specifies how a numeric client parameter is sampled, e.g. {"type": "lognormal", "median": 5, "sigma": 0.5}:
+// -> constant: always value
+// -> uniform: within [min, max)
+// -> normal: with mean & stddev
+// -> lognormal: with median & sigma (the standard deviation of its log)
+// -> exponential: with mean
+// -> empirical: histogram of weighted bins, e.g. {"type": "empirical", "bins": [{"min": 0, "max": 50, "weight": 3}, ...]}
+type Distribution struct {
+	Type   string  `json:"type"`
+	Value  float64 `json:"value"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"`
+	Median float64 `json:"median"`
+	Sigma  float64 `json:"sigma"`
+
+	Bins []HistogramBin `json:"bins"`
+}
+
+// HistogramBin is a range [min, max) sampled uniformly, weight times as often as a bin of weight 1.
+type HistogramBin struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Weight float64 `json:"weight"`
+}
+
+// Uniform distribution in [0, max) (always 0 if max is 0).
+func UniformUpTo(max float64) Distribution {
+	return Distribution{Type: "uniform", Min: 0, Max: max}
+}
+
+func (d Distribution) Validate() error {
+	switch d.Type {
+	case "constant":
+	case "uniform":
+		if d.Max < d.Min {
+			return fmt.Errorf("uniform distribution needs min <= max, got [%v, %v)", d.Min, d.Max)
+		}
+	case "normal":
+		if d.Stddev < 0 {
+			return fmt.Errorf("normal distribution needs stddev >= 0, got %v", d.Stddev)
+		}
+	case "lognormal":
+		if d.Median <= 0 || d.Sigma < 0 {
+			return fmt.Errorf("lognormal distribution needs median > 0 & sigma >= 0, got %v & %v", d.Median, d.Sigma)
+		}
+	case "exponential":
+		if d.Mean <= 0 {
+			return fmt.Errorf("exponential distribution needs mean > 0, got %v", d.Mean)
+		}
+	case "empirical":
+		totalWeight := 0.0
+		for _, bin := range d.Bins {
+			if bin.Max < bin.Min || bin.Weight < 0 {
+				return fmt.Errorf("empirical distribution bins need min <= max & weight >= 0, got %+v", bin)
+			}
+			totalWeight += bin.Weight
+		}
+		if totalWeight <= 0 {
+			return fmt.Errorf("empirical distribution needs bins of positive total weight")
+		}
+	default:
+		return fmt.Errorf(
+			"distribution type must be one of: {constant, uniform, normal, lognormal, exponential, empirical}, got '%s'",
+			d.Type,
+		)
+	}
+	return nil
+}
+
+// Draws a value (the distribution must be valid).
+func (d Distribution) Sample() float64 {
+	switch d.Type {
+	case "uniform":
+		return d.Min + rand.Float64()*(d.Max-d.Min)
+	case "normal":
+		return d.Mean + rand.NormFloat64()*d.Stddev
+	case "lognormal":
+		return d.Median * math.Exp(rand.NormFloat64()*d.Sigma)
+	case "exponential":
+		return rand.ExpFloat64() * d.Mean
+	case "empirical":
+		totalWeight := 0.0
+		for _, bin := range d.Bins {
+			totalWeight += bin.Weight
+		}
+		target := rand.Float64() * totalWeight
+		for _, bin := range d.Bins {
+			if target < bin.Weight {
+				return bin.Min + rand.Float64()*(bin.Max-bin.Min)
+			}
+			target -= bin.Weight
+		}
+		last := d.Bins[len(d.Bins)-1]
+		return last.Max
+	default:
+		return d.Value
+	}
+}
+
+// Expected value (the distribution must be valid).
+func (d Distribution) ExpectedValue() float64 {
+	switch d.Type {
+	case "uniform":
+		return (d.Min + d.Max) / 2
+	case "normal", "exponential":
+		return d.Mean
+	case "lognormal":
+		return d.Median * math.Exp(d.Sigma*d.Sigma/2)
+	case "empirical":
+		totalWeight, weightedSum := 0.0, 0.0
+		for _, bin := range d.Bins {
+			totalWeight += bin.Weight
+			weightedSum += bin.Weight * (bin.Min + bin.Max) / 2
+		}
+		return weightedSum / totalWeight
+	default:
+		return d.Value
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDistributionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       Distribution
+		wantErr string
+	}{
+		{"constant", Distribution{Type: "constant", Value: 3}, ""},
+		{"uniform", Distribution{Type: "uniform", Min: 1, Max: 2}, ""},
+		{"empty uniform", Distribution{Type: "uniform", Min: 2, Max: 2}, ""},
+		{"normal", Distribution{Type: "normal", Mean: 5, Stddev: 1}, ""},
+		{"lognormal", Distribution{Type: "lognormal", Median: 5, Sigma: 0.5}, ""},
+		{"exponential", Distribution{Type: "exponential", Mean: 2}, ""},
+		{"empirical", Distribution{Type: "empirical", Bins: []HistogramBin{{0, 1, 0}, {1, 2, 3}}}, ""},
+
+		{"missing type", Distribution{}, "distribution type must be one of"},
+		{"unknown type", Distribution{Type: "poisson"}, "got 'poisson'"},
+		{"inverted uniform", Distribution{Type: "uniform", Min: 2, Max: 1}, "needs min <= max"},
+		{"negative stddev", Distribution{Type: "normal", Stddev: -1}, "needs stddev >= 0"},
+		{"zero median", Distribution{Type: "lognormal", Sigma: 1}, "needs median > 0"},
+		{"negative sigma", Distribution{Type: "lognormal", Median: 1, Sigma: -1}, "sigma >= 0"},
+		{"zero mean", Distribution{Type: "exponential"}, "needs mean > 0"},
+		{"no bins", Distribution{Type: "empirical"}, "positive total weight"},
+		{"zero weights", Distribution{Type: "empirical", Bins: []HistogramBin{{0, 1, 0}}}, "positive total weight"},
+		{"negative weight", Distribution{Type: "empirical", Bins: []HistogramBin{{0, 1, -1}, {1, 2, 2}}}, "weight >= 0"},
+		{"inverted bin", Distribution{Type: "empirical", Bins: []HistogramBin{{2, 1, 1}}}, "min <= max"},
+	}
+	for _, tt := range tests {
+		err := tt.d.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("%s: got no error, want %q", tt.name, tt.wantErr)
+		case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("%s: got error %q, want %q", tt.name, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestDistributionSampleIsDeterministicWhenSeeded(t *testing.T) {
+	distributions := []Distribution{
+		{Type: "uniform", Min: 1, Max: 3},
+		{Type: "normal", Mean: 5, Stddev: 2},
+		{Type: "lognormal", Median: 5, Sigma: 0.5},
+		{Type: "exponential", Mean: 2},
+		{Type: "empirical", Bins: []HistogramBin{{0, 1, 1}, {10, 20, 2}}},
+	}
+	draw := func() []float64 {
+		rand.Seed(42)
+		values := make([]float64, 0, 10*len(distributions))
+		for i := 0; i < 10; i++ {
+			for _, d := range distributions {
+				values = append(values, d.Sample())
+			}
+		}
+		return values
+	}
+	first, second := draw(), draw()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("draw %d: got %v then %v with the same seed", i, first[i], second[i])
+		}
+	}
+}
+
+func TestDistributionSampleRange(t *testing.T) {
+	rand.Seed(1)
+	tests := []struct {
+		d        Distribution
+		min, max float64
+	}{
+		{Distribution{Type: "constant", Value: 7}, 7, 7},
+		{UniformUpTo(0), 0, 0},
+		{UniformUpTo(10), 0, 10},
+		{Distribution{Type: "uniform", Min: -2, Max: 2}, -2, 2},
+		{Distribution{Type: "lognormal", Median: 5, Sigma: 0}, 5, 5},
+		{Distribution{Type: "exponential", Mean: 1}, 0, math.Inf(1)},
+		{Distribution{Type: "empirical", Bins: []HistogramBin{{0, 1, 0}, {10, 20, 1}}}, 10, 20},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 1000; i++ {
+			if v := tt.d.Sample(); v < tt.min || v > tt.max {
+				t.Errorf("%+v: got %v, want within [%v, %v]", tt.d, v, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+// Bins are drawn in proportion to their weight relative to the total, whatever scale the weights are given in.
+func TestEmpiricalWeightsAreNormalized(t *testing.T) {
+	for _, scale := range []float64{1, 0.01, 1000} {
+		d := Distribution{Type: "empirical", Bins: []HistogramBin{{0, 1, 1 * scale}, {1, 2, 3 * scale}}}
+		rand.Seed(7)
+		const numDraws = 20000
+		inFirstBin := 0
+		for i := 0; i < numDraws; i++ {
+			if d.Sample() < 1 {
+				inFirstBin++
+			}
+		}
+		if share := float64(inFirstBin) / numDraws; math.Abs(share-0.25) > 0.02 {
+			t.Errorf("weights scaled by %v: got first bin share %.3f, want 0.25", scale, share)
+		}
+		if got, want := d.ExpectedValue(), 0.25*0.5+0.75*1.5; math.Abs(got-want) > 1e-9 {
+			t.Errorf("weights scaled by %v: got expected value %v, want %v", scale, got, want)
+		}
+	}
+}
+
+func TestDistributionExpectedValue(t *testing.T) {
+	tests := []struct {
+		d    Distribution
+		want float64
+	}{
+		{Distribution{Type: "constant", Value: 4}, 4},
+		{Distribution{Type: "uniform", Min: 2, Max: 6}, 4},
+		{Distribution{Type: "normal", Mean: 3, Stddev: 9}, 3},
+		{Distribution{Type: "exponential", Mean: 2}, 2},
+		{Distribution{Type: "lognormal", Median: 1, Sigma: 2}, math.Exp(2)},
+	}
+	for _, tt := range tests {
+		if got := tt.d.ExpectedValue(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v: got %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
@@ -4,55 +4,125 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network"
 	"github.com/Shopify/goqueuesim/internal/network_mock"
 	"github.com/rs/zerolog/log"
 )
 
 type BaseClient struct {
-	Ctx   context.Context
-	Id    int
-	label string
+	Ctx    context.Context
+	Id     int
+	ShopId int
+	label  string
+
+	// Person behind the session (several sessions may share one, e.g. browser tabs).
+	personId int
+
+	// Higher tiers (e.g. loyalty members) take precedence under tiered queue policies.
+	priorityTier int
 
 	ClientsFinishedWaitGroup *sync.WaitGroup
 	RequestTargetChannel     chan<- *network_mock.MockRequest
+	Network                  network.Model
+	Metrics                  *metrics.Recorder
 
 	DefaultPollInterval time.Duration
-	MaxInitialDelayMs   int
-	MaxNetworkJitterMs  int
 	ObeysPollAfter      bool
 
+	// Sampled per client, per request & per poll respectively (routine polls are DefaultPollInterval apart if nil).
+	InitialDelayMs      client.Distribution
+	NetworkJitterMs     client.Distribution
+	PollIntervalSeconds *client.Distribution
+
+	// Dropped clients reload (up to MaxRetries times) with a fresh session instead of giving up.
+	MaxRetries          int
+	ReloadDelay         time.Duration
+	KeepsCookieOnReload bool
+	numReloads          int
+
+	// Requests left unanswered for RequestTimeout (or shed right away) are retried up to MaxRequestRetries times,
+	// backing off exponentially from RequestRetryBackoff, before the client counts as dropped.
+	RequestTimeout      time.Duration
+	MaxRequestRetries   int
+	RequestRetryBackoff time.Duration
+	lastResponseTime    time.Time
+
+	// Decides when to poll next (see PollingStrategy).
+	PollingStrategy PollingStrategy
+
 	StartedPolling bool
 	PollStopper    chan struct{} // Channel closed to stop polling.
+	pollsSoFar     int           // Since last starting to poll.
+
+	// When the initial checkout request & next poll are due (kept for checkpoints, see client_checkpoint.go).
+	initialRequestTime time.Time
+	nextPollTime       time.Time
+	keepsPolling       bool
+
+	// Set on sessions restored from a checkpoint until they resume the request or polling they were due.
+	resumesInitialRequest bool
+	resumesPolling        bool
+
+	// Skewed wall clock & background tab timer clamping (perfect unless configured).
+	clock clientClock
+
+	// Values drawn from the property_distributions of the client's config (kept for checkpoints).
+	sampledProperties map[string]float64
 
 	HitCheckoutStep bool
 
+	// Set when the client deliberately gave up waiting in queue (as opposed to vanishing or timing out).
+	abandoned bool
+
 	advisedPollAfter time.Time
 	state            client.ThrottleState
 
+	queuePosition             int64
+	estimatedAdmissionTime    time.Time
+	initialEstimatedAdmission time.Time
+
 	queueEntryTime time.Time
 	queueExitTime  time.Time
 
+	// When the client learnt of its admission (later than queueExitTime over a slow network).
+	admissionNoticeTime time.Time
+
 	mutex     sync.RWMutex
 	isLocked  bool
 	pollTimer *time.Timer
 
+	// Notified when leaving the queue without being admitted, e.g. so that the server removes the client from it.
+	queueLeaveListener func(c client.Client)
+
 	throttleCookie map[string]string // Analogous to "key1=value1;...;keyN=valueN" cookie.
 }
 
 func (bc *BaseClient) ID() int {
 	return bc.Id
+}
+
+func (bc *BaseClient) PersonID() int {
+	return bc.personId
+}
 
+func (bc *BaseClient) ShopID() int {
+	return bc.ShopId
 }
+
 func (bc *BaseClient) Label() string {
 	return bc.label
 }
 
+func (bc *BaseClient) PriorityTier() int {
+	return bc.priorityTier
+}
+
 func (bc *BaseClient) Lock() {
 	bc.mutex.Lock()
 	bc.isLocked = true
@@ -75,6 +145,14 @@ func (bc *BaseClient) QueueDuration() time.Duration {
 	return bc.queueExitTime.Sub(bc.queueEntryTime)
 }
 
+// Time between being admitted by the server & receiving word of it (0 until then).
+func (bc *BaseClient) AdmissionNoticeDelay() time.Duration {
+	if bc.admissionNoticeTime.IsZero() {
+		return 0
+	}
+	return bc.admissionNoticeTime.Sub(bc.queueExitTime)
+}
+
 func (bc *BaseClient) SetThrottleCookieVal(key, value string) error {
 	if !bc.isLocked {
 		return errors.New("lock required to mutate throttle cookie")
@@ -124,7 +202,10 @@ func (bc *BaseClient) MarkQueued() error {
 	if !bc.isLocked {
 		return errors.New("lock required to mutate throttle state")
 	}
-	bc.queueEntryTime = time.Now()
+	// A session resumed from a surviving throttle cookie keeps its original place in time.
+	if bc.queueEntryTime.IsZero() {
+		bc.queueEntryTime = time.Now()
+	}
 	bc.state = client.Queued
 	bc.SetThrottleCookieVal(client.ThrottleStateKey, client.Queued.String())
 	return nil
@@ -153,6 +234,54 @@ func (bc *BaseClient) HasExited() bool {
 	return bc.state == client.Exited
 }
 
+func (bc *BaseClient) Abandoned() bool {
+	return bc.abandoned
+}
+
+// Exits the queue while recording how long the client was willing to wait.
+func (bc *BaseClient) MarkAbandoned() error {
+	if !bc.isLocked {
+		return errors.New("lock required to mutate throttle state")
+	}
+	bc.queueExitTime = time.Now()
+	bc.abandoned = true
+	return bc.MarkExited()
+}
+
+// Starts over with a fresh session: the throttle cookie (& queue entry time) only survive if kept.
+func (bc *BaseClient) Reload(keepThrottleCookie bool) error {
+	if !bc.isLocked {
+		return errors.New("lock required to mutate throttle state")
+	}
+	bc.StopPolling()
+	if bc.state == client.Queued && !keepThrottleCookie {
+		bc.leaveQueue()
+	}
+	bc.state = client.Initial
+	bc.advisedPollAfter = time.Time{}
+	bc.numReloads++
+	if !keepThrottleCookie {
+		bc.queueEntryTime = time.Time{}
+		bc.initialEstimatedAdmission = time.Time{}
+		bc.ClearThrottleCookie()
+	}
+	return nil
+}
+
+func (bc *BaseClient) NumReloads() int {
+	return bc.numReloads
+}
+
+func (bc *BaseClient) SetQueueLeaveListener(listener func(c client.Client)) {
+	bc.queueLeaveListener = listener
+}
+
+func (bc *BaseClient) leaveQueue() {
+	if bc.queueLeaveListener != nil {
+		bc.queueLeaveListener(bc)
+	}
+}
+
 func (bc *BaseClient) AdvisePollAfter(t time.Time) error {
 	if !bc.isLocked {
 		return errors.New("lock required to mutate advised poll after")
@@ -165,10 +294,41 @@ func (bc *BaseClient) AdvisedPollAfter() time.Time {
 	return bc.advisedPollAfter
 }
 
+func (bc *BaseClient) AdviseQueuePosition(pos int64, estimatedAdmission time.Time) error {
+	if !bc.isLocked {
+		return errors.New("lock required to mutate advised queue position")
+	}
+	bc.queuePosition = pos
+	bc.estimatedAdmissionTime = estimatedAdmission
+	if bc.initialEstimatedAdmission.IsZero() {
+		bc.initialEstimatedAdmission = estimatedAdmission
+	}
+	return nil
+}
+
+func (bc *BaseClient) QueuePosition() int64 {
+	return bc.queuePosition
+}
+
+func (bc *BaseClient) EstimatedAdmissionTime() time.Time {
+	return bc.estimatedAdmissionTime
+}
+
+// Wait estimated by the first ETA received since entering the queue (to compare against QueueDuration).
+func (bc *BaseClient) InitialEstimatedWait() (time.Duration, bool) {
+	if bc.initialEstimatedAdmission.IsZero() {
+		return 0, false
+	}
+	return bc.initialEstimatedAdmission.Sub(bc.queueEntryTime), true
+}
+
 func (bc *BaseClient) MarkExited() error {
 	if !bc.isLocked {
 		return errors.New("lock required to mutate throttle state")
 	}
+	if bc.state == client.Queued {
+		bc.leaveQueue()
+	}
 	bc.state = client.Exited
 	_ = bc.SetThrottleCookieVal(client.ThrottleStateKey, client.Exited.String())
 	bc.ClientsFinishedWaitGroup.Done()
@@ -179,32 +339,138 @@ func (bc *BaseClient) ThrottleState() client.ThrottleState {
 	return bc.state
 }
 
+// Snapshot of the session (lock required) safe to hand over to other routines, e.g. encoding it over HTTP.
 func (bc *BaseClient) SessionData() network_mock.SessionData {
 	return network_mock.SessionData{
 		Id:               bc.ID(),
+		ShopId:           bc.ShopID(),
 		QueueEntryTime:   bc.QueueEntryTime(),
 		AdvisedPollAfter: bc.AdvisedPollAfter(),
 		ThrottleState:    bc.ThrottleState().String(),
-		ThrottleCookie:   bc.throttleCookie,
+		ThrottleCookie:   copyCookie(bc.throttleCookie),
+
+		QueuePosition:          bc.QueuePosition(),
+		EstimatedAdmissionTime: bc.EstimatedAdmissionTime(),
+
+		Generation: bc.numReloads,
 	}
 }
 
+// Sends request, giving up on it (see handleDroppedRequest) if shed or left unanswered once out of retries.
 func (bc *BaseClient) dieOnRequestTimeout(request *network_mock.MockRequest) {
-	select {
-	case bc.RequestTargetChannel <- request:
-	default:
-		log.Debug().Msg(fmt.Sprintf("Failed to send request...killing client %d\n", bc.ID()))
-		bc.MarkExited()
-		bc.StopPolling()
+	bc.sendRequest(request, 0)
+}
+
+func (bc *BaseClient) sendRequest(request *network_mock.MockRequest, attempt int) {
+	sentTime := time.Now()
+	numReloads := bc.numReloads
+	onDropped := func() {
+		bc.Lock()
+		defer bc.Unlock()
+		if bc.awaits(sentTime, numReloads) {
+			bc.retryOrDrop(request, attempt, sentTime)
+		}
+	}
+	if !bc.Network.SendRequest(request, bc.RequestTargetChannel, onDropped) {
+		bc.retryOrDrop(request, attempt, sentTime)
+		return
+	}
+	if bc.RequestTimeout <= 0 {
+		return
+	}
+	time.AfterFunc(bc.RequestTimeout, func() {
+		bc.Lock()
+		defer bc.Unlock()
+		if bc.Ctx.Err() != nil || !bc.awaits(sentTime, numReloads) {
+			return
+		}
 		labelTag := fmt.Sprintf("client_label:%s", bc.Label())
-		metrics.Incr("server.timeout", []string{"operation:timeout", labelTag})
+		bc.Metrics.Incr("client.request_timeouts", []string{labelTag})
+		bc.retryOrDrop(request, attempt, sentTime)
+	})
+}
+
+// Whether the client still awaits an answer to a request sent at sentTime before its numReloads-th reload (it may
+// since have reloaded, heard back or left). The server may have acted upon requests whose response got lost, so
+// retries carry the session as it stands (e.g. queued rather than initial).
+func (bc *BaseClient) awaits(sentTime time.Time, numReloads int) bool {
+	return bc.numReloads == numReloads && !bc.lastResponseTime.After(sentTime) && bc.ThrottleState() != client.Exited
+}
+
+// Sends request (first sent at sentTime) again after backing off if retries are left, else gives up on it.
+func (bc *BaseClient) retryOrDrop(request *network_mock.MockRequest, attempt int, sentTime time.Time) {
+	if attempt >= bc.MaxRequestRetries {
+		bc.handleDroppedRequest()
+		return
+	}
+	labelTag := fmt.Sprintf("client_label:%s", bc.Label())
+	bc.Metrics.Incr("client.request_retries", []string{labelTag})
+	numReloads := bc.numReloads
+	backoff := bc.RequestRetryBackoff * time.Duration(1<<uint(attempt))
+	time.AfterFunc(backoff, func() {
+		bc.Lock()
+		defer bc.Unlock()
+		if bc.Ctx.Err() != nil || !bc.awaits(sentTime, numReloads) {
+			return
+		}
+		retried := &network_mock.MockRequest{Endpoint: request.Endpoint, ClientData: bc.SessionData()}
+		bc.sendRequest(retried, attempt+1)
+	})
+}
+
+func (bc *BaseClient) handleDroppedRequest() {
+	labelTag := fmt.Sprintf("client_label:%s", bc.Label())
+	if bc.numReloads < bc.MaxRetries {
+		log.Debug().Msg(fmt.Sprintf("Failed to send request...reloading client %d\n", bc.ID()))
+		bc.Reload(bc.KeepsCookieOnReload)
+		bc.Metrics.Incr("server.timeout", []string{"operation:retry", labelTag})
+		go bc.resendCheckoutRequestAfter(bc.ReloadDelay)
+		return
+	}
+	log.Debug().Msg(fmt.Sprintf("Failed to send request...killing client %d\n", bc.ID()))
+	bc.MarkExited()
+	bc.StopPolling()
+	bc.Metrics.Incr("server.timeout", []string{"operation:timeout", labelTag})
+}
+
+func (bc *BaseClient) resendCheckoutRequestAfter(reloadDelay time.Duration) {
+	select {
+	case <-bc.Ctx.Done():
+		return
+	case <-time.After(reloadDelay):
+	}
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.ThrottleState() != client.Initial {
+		return
 	}
+	bc.dieOnRequestTimeout(network_mock.MakeCheckoutRequest(bc.SessionData()))
 }
 
+// Schedules the initial checkout request after the client's initial delay, which it waits out unlocked (so that
+// e.g. its session may be checkpointed meanwhile).
 func (bc *BaseClient) SendInitialCheckoutRequest() {
-	time.Sleep(time.Duration(rand.Intn(bc.MaxInitialDelayMs)) * time.Millisecond)
-	checkoutRequest := network_mock.MakeCheckoutRequest(bc.SessionData())
-	bc.dieOnRequestTimeout(checkoutRequest)
+	initialDelay := sampleDuration(bc.InitialDelayMs, time.Millisecond)
+	if bc.resumesInitialRequest {
+		bc.resumesInitialRequest = false
+		initialDelay = time.Until(bc.initialRequestTime)
+	}
+	bc.initialRequestTime = time.Now().Add(initialDelay)
+	go bc.sendInitialCheckoutRequestAfter(initialDelay, bc.numReloads)
+}
+
+func (bc *BaseClient) sendInitialCheckoutRequestAfter(initialDelay time.Duration, numReloads int) {
+	select {
+	case <-bc.Ctx.Done():
+		return
+	case <-time.After(initialDelay):
+	}
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.ThrottleState() != client.Initial || bc.numReloads != numReloads {
+		return
+	}
+	bc.dieOnRequestTimeout(network_mock.MakeCheckoutRequest(bc.SessionData()))
 }
 
 func (bc *BaseClient) makeSinglePollRequest() error {
@@ -217,21 +483,42 @@ func (bc *BaseClient) makeSinglePollRequest() error {
 	}
 	log.Info().Int("client_id", bc.Id).Msg("sending poll request...")
 	bc.StartedPolling = true
+	if bc.ObeysPollAfter && !bc.AdvisedPollAfter().IsZero() {
+		// Server-side view of how (im)precisely obedient clients honour poll-after advice.
+		labelTag := fmt.Sprintf("client_label:%s", bc.Label())
+		lateness := time.Since(bc.AdvisedPollAfter())
+		bc.Metrics.Distribution("client.poll_after_lateness_ms", float64(lateness.Milliseconds()), []string{labelTag})
+	}
 	pollingRequest := network_mock.MakePollRequest(bc.SessionData())
 	bc.dieOnRequestTimeout(pollingRequest)
 	return nil
 }
 
+// Polls right away, then as scheduled by the client's PollingStrategy until leaving the queue or giving up.
 func (bc *BaseClient) StartPolling() {
 	if bc.StartedPolling || !bc.isLocked {
 		return
 	}
-	bc.makeSinglePollRequest()
-	if !bc.StartedPolling {
-		return
+	// Sessions resumed from a checkpoint carry on polling as scheduled.
+	firstPollDelay, keepPolling := time.Until(bc.nextPollTime), bc.keepsPolling
+	if !bc.resumesPolling {
+		bc.pollsSoFar = 0
+		firstPollDelay, keepPolling = bc.PollingStrategy.NextPoll(bc, bc.pollsSoFar)
+		if keepPolling {
+			bc.makeSinglePollRequest()
+			if !bc.StartedPolling {
+				return
+			}
+			bc.pollsSoFar++
+			firstPollDelay, keepPolling = bc.PollingStrategy.NextPoll(bc, bc.pollsSoFar)
+		}
+		firstPollDelay = bc.clock.timerDelay(firstPollDelay)
 	}
+	bc.resumesPolling = false
+	bc.StartedPolling = true
 	bc.PollStopper = make(chan struct{})
-	bc.pollTimer = time.NewTimer(bc.DefaultPollInterval)
+	bc.schedulePoll(firstPollDelay, keepPolling)
+	bc.pollTimer = time.NewTimer(firstPollDelay)
 	go func() {
 		for {
 			select {
@@ -240,28 +527,43 @@ func (bc *BaseClient) StartPolling() {
 			case <-bc.PollStopper:
 				return
 			case <-bc.pollTimer.C:
-				time.Sleep(time.Duration(rand.Intn(bc.MaxNetworkJitterMs)) * time.Millisecond)
 				bc.Lock()
 				if !bc.IsQueued() {
 					bc.StopPolling()
 					bc.Unlock()
 					return
 				}
-				willObeyPollAfter := bc.ObeysPollAfter && !bc.AdvisedPollAfter().IsZero()
-				if willObeyPollAfter && time.Now().Before(bc.AdvisedPollAfter()) {
-					bc.makeSinglePollRequest()
+				if !keepPolling {
+					bc.vanish()
 					bc.Unlock()
-					bc.pollTimer.Reset(time.Until(bc.AdvisedPollAfter()))
-					continue
+					return
 				}
 				bc.makeSinglePollRequest()
+				bc.pollsSoFar++
+				var nextPollDelay time.Duration
+				nextPollDelay, keepPolling = bc.PollingStrategy.NextPoll(bc, bc.pollsSoFar)
+				nextPollDelay = bc.clock.timerDelay(nextPollDelay)
+				bc.schedulePoll(nextPollDelay, keepPolling)
 				bc.Unlock()
-				bc.pollTimer.Reset(bc.DefaultPollInterval)
+				bc.pollTimer.Reset(nextPollDelay)
 			}
 		}
 	}()
 }
 
+func (bc *BaseClient) schedulePoll(delay time.Duration, keepPolling bool) {
+	bc.nextPollTime = time.Now().Add(delay)
+	bc.keepsPolling = keepPolling
+}
+
+// Leaves the queue without a word, as clients giving up on polling do.
+func (bc *BaseClient) vanish() {
+	_ = bc.MarkExited()
+	bc.StopPolling()
+	labelTag := fmt.Sprintf("client_label:%s", bc.Label())
+	bc.Metrics.Incr("server.checkout", []string{"operation:vanished", labelTag})
+}
+
 func (bc *BaseClient) StopPolling() error {
 	if !bc.isLocked {
 		return errors.New("lock required to mutate polling state")
@@ -270,19 +572,45 @@ func (bc *BaseClient) StopPolling() error {
 		return nil
 	}
 	bc.StartedPolling = false
-	bc.pollTimer.Stop()
-	close(bc.PollStopper)
+	bc.nextPollTime = time.Time{}
+	// The very first poll may be dropped before its timer & stopper exist (or still refer to a past session).
+	if bc.pollTimer != nil {
+		bc.pollTimer.Stop()
+	}
+	if bc.PollStopper != nil {
+		select {
+		case <-bc.PollStopper:
+		default:
+			close(bc.PollStopper)
+		}
+	}
 	return nil
 }
 
 func (bc *BaseClient) delegateResponseTo(c client.Client, resp *network_mock.MockResponse) {
+	if resp.ClientData.Generation < c.NumReloads() {
+		// Stale response to a session the client abandoned by reloading.
+		return
+	}
+	bc.lastResponseTime = time.Now()
 	clientState := c.ThrottleState()
+	if clientState == client.Exited {
+		// Client left (e.g. abandoned) while the response was in flight.
+		return
+	}
 	if resp.ClientData.ThrottleState != clientState.String() {
+		if respState, ok := client.ParseThrottleState(resp.ClientData.ThrottleState); ok && respState < clientState {
+			// Overtaken in flight by the response to a later request.
+			return
+		}
 		panic("Asymmetric data: server failed to mark target response state on client")
 	}
 	if !resp.ClientData.AdvisedPollAfter.IsZero() {
 		c.AdvisePollAfter(resp.ClientData.AdvisedPollAfter)
 	}
+	if !resp.ClientData.EstimatedAdmissionTime.IsZero() {
+		c.AdviseQueuePosition(resp.ClientData.QueuePosition, resp.ClientData.EstimatedAdmissionTime)
+	}
 	switch clientState {
 	case client.Initial:
 		log.Info().Int("client_id", c.ID()).Msg("sending initial checkout request...")
@@ -294,13 +622,23 @@ func (bc *BaseClient) delegateResponseTo(c client.Client, resp *network_mock.Moc
 		log.Info().Int("client_id", c.ID()).Msg("stopped polling")
 		_ = c.MarkExited()
 		c.StopPolling()
+		bc.admissionNoticeTime = time.Now()
 		labelTag := fmt.Sprintf("client_label:%s", c.Label())
-		metrics.Incr("server.checkout", []string{"operation:success", labelTag})
-		metrics.Distribution(
+		bc.Metrics.Distribution(
+			"client.admission_notice_delay_ms",
+			float64(c.AdmissionNoticeDelay().Milliseconds()),
+			[]string{labelTag},
+		)
+		bc.Metrics.Incr("server.checkout", []string{"operation:success", labelTag})
+		bc.Metrics.Distribution(
 			"client.queue_time_ms",
 			float64(c.QueueDuration().Milliseconds()),
 			[]string{"operation:checkout_successful", labelTag},
 		)
+		if estimatedWait, ok := c.InitialEstimatedWait(); ok {
+			etaError := c.QueueDuration() - estimatedWait
+			bc.Metrics.Distribution("client.eta_error_ms", float64(etaError.Milliseconds()), []string{labelTag})
+		}
 	case client.Exited:
 		return
 	default:
@@ -315,3 +653,16 @@ func (bc *BaseClient) HandleResponse(resp *network_mock.MockResponse) error {
 	bc.delegateResponseTo(bc, resp)
 	return nil
 }
+
+// Interval until the next routine poll.
+func (bc *BaseClient) pollInterval() time.Duration {
+	if bc.PollIntervalSeconds == nil {
+		return bc.DefaultPollInterval
+	}
+	return sampleDuration(*bc.PollIntervalSeconds, time.Second)
+}
+
+// Next sample of d in units of unit (negative samples are clamped to 0).
+func sampleDuration(d client.Distribution, unit time.Duration) time.Duration {
+	return time.Duration(math.Max(0, d.Sample()) * float64(unit))
+}
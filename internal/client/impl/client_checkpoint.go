@@ -0,0 +1,164 @@
+package impl
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// Snapshots the session of a stopped client, along with the traits sampled when making it.
+func (bc *BaseClient) CheckpointSession(clock checkpoint.Clock) checkpoint.ClientState {
+	state := checkpoint.ClientState{
+		Id:             bc.Id,
+		Label:          bc.label,
+		ThrottleState:  bc.state.String(),
+		ThrottleCookie: copyCookie(bc.throttleCookie),
+
+		QueueEntry:                clock.Offset(bc.queueEntryTime),
+		QueueExit:                 clock.Offset(bc.queueExitTime),
+		AdmissionNotice:           clock.Offset(bc.admissionNoticeTime),
+		AdvisedPollAfter:          clock.Offset(bc.advisedPollAfter),
+		EstimatedAdmission:        clock.Offset(bc.estimatedAdmissionTime),
+		InitialEstimatedAdmission: clock.Offset(bc.initialEstimatedAdmission),
+		QueuePosition:             bc.queuePosition,
+
+		NumReloads:      bc.numReloads,
+		HitCheckoutStep: bc.HitCheckoutStep,
+		Abandoned:       bc.abandoned,
+
+		SampledProperties: bc.sampledProperties,
+		ClockOffset:       bc.clock.offset,
+	}
+	switch bc.state {
+	case client.Initial:
+		state.InitialRequest = clock.Offset(bc.initialRequestTime)
+	case client.Queued:
+		// Resumed sessions yet to start polling keep the poll they were due.
+		if bc.StartedPolling || bc.resumesPolling {
+			state.NextPoll = clock.Offset(bc.nextPollTime)
+			state.KeepsPolling = bc.keepsPolling
+			state.PollsSoFar = bc.pollsSoFar
+		}
+	}
+	return state
+}
+
+// Restores the session of a client yet to start, which then resumes whatever request or poll it was due. Its
+// sampled properties must have been resumed when making it (see NetworkParams.ResumedProperties).
+func (bc *BaseClient) RestoreSession(state checkpoint.ClientState, clock checkpoint.Clock) error {
+	if state.Id != bc.Id || state.Label != bc.label {
+		return fmt.Errorf(
+			"checkpointed client %d (%s) does not match client %d (%s) of the resumed simulation",
+			state.Id, state.Label, bc.Id, bc.label,
+		)
+	}
+	sampledAlike := len(state.SampledProperties) == 0 && len(bc.sampledProperties) == 0 ||
+		reflect.DeepEqual(state.SampledProperties, bc.sampledProperties)
+	if !sampledAlike {
+		return fmt.Errorf(
+			"checkpointed client %d sampled properties %v but was made with %v",
+			state.Id, state.SampledProperties, bc.sampledProperties,
+		)
+	}
+	throttleState, ok := client.ParseThrottleState(state.ThrottleState)
+	if !ok {
+		return fmt.Errorf("checkpointed client %d has unknown throttle state '%s'", state.Id, state.ThrottleState)
+	}
+	bc.state = throttleState
+	bc.throttleCookie = copyCookie(state.ThrottleCookie)
+	if bc.throttleCookie == nil {
+		bc.throttleCookie = make(map[string]string)
+	}
+
+	bc.queueEntryTime = clock.Time(state.QueueEntry)
+	bc.queueExitTime = clock.Time(state.QueueExit)
+	bc.admissionNoticeTime = clock.Time(state.AdmissionNotice)
+	bc.advisedPollAfter = clock.Time(state.AdvisedPollAfter)
+	bc.estimatedAdmissionTime = clock.Time(state.EstimatedAdmission)
+	bc.initialEstimatedAdmission = clock.Time(state.InitialEstimatedAdmission)
+	bc.queuePosition = state.QueuePosition
+
+	bc.numReloads = state.NumReloads
+	bc.HitCheckoutStep = state.HitCheckoutStep
+	bc.abandoned = state.Abandoned
+	bc.clock.offset = state.ClockOffset
+
+	if state.InitialRequest != nil {
+		bc.initialRequestTime = clock.Time(state.InitialRequest)
+		bc.resumesInitialRequest = true
+	}
+	if state.NextPoll != nil {
+		bc.nextPollTime = clock.Time(state.NextPoll)
+		bc.keepsPolling = state.KeepsPolling
+		bc.pollsSoFar = state.PollsSoFar
+		bc.resumesPolling = true
+	}
+	if throttleState == client.Exited {
+		bc.ClientsFinishedWaitGroup.Done()
+	}
+	return nil
+}
+
+func (ic *ImpatientClient) CheckpointSession(clock checkpoint.Clock) checkpoint.ClientState {
+	state := ic.BaseClient.CheckpointSession(clock)
+	state.Patience = ic.Patience
+	state.NeverAbandons = ic.NeverAbandons
+	return state
+}
+
+func (ic *ImpatientClient) RestoreSession(state checkpoint.ClientState, clock checkpoint.Clock) error {
+	if err := ic.BaseClient.RestoreSession(state, clock); err != nil {
+		return err
+	}
+	ic.Patience = state.Patience
+	ic.NeverAbandons = state.NeverAbandons
+	return nil
+}
+
+func (ctc *CookieTamperingClient) CheckpointSession(clock checkpoint.Clock) checkpoint.ClientState {
+	state := ctc.BaseClient.CheckpointSession(clock)
+	state.TamperedPolls = ctc.tamperedPolls
+	if ctc.staleCookie != nil {
+		state.StaleCookie = copyCookie(ctc.staleCookie)
+	}
+	return state
+}
+
+// Cookies leaked before the checkpoint are leaked again (the jar only ever keeps a shop's earliest one).
+func (ctc *CookieTamperingClient) RestoreSession(state checkpoint.ClientState, clock checkpoint.Clock) error {
+	if err := ctc.BaseClient.RestoreSession(state, clock); err != nil {
+		return err
+	}
+	ctc.tamperedPolls = state.TamperedPolls
+	ctc.staleCookie = nil
+	if state.StaleCookie != nil {
+		ctc.staleCookie = copyCookie(state.StaleCookie)
+		ctc.Jar.Leak(ctc.ShopID(), ctc.QueueEntryTime(), ctc.staleCookie)
+	}
+	return nil
+}
+
+func (msc *MultiSessionClient) CheckpointSession(clock checkpoint.Clock) checkpoint.ClientState {
+	state := msc.BaseClient.CheckpointSession(clock)
+	msc.group.mu.Lock()
+	if msc.group.hasWon {
+		state.SessionGroupWinner = msc.group.winnerId
+	}
+	msc.group.mu.Unlock()
+	return state
+}
+
+func (msc *MultiSessionClient) RestoreSession(state checkpoint.ClientState, clock checkpoint.Clock) error {
+	if err := msc.BaseClient.RestoreSession(state, clock); err != nil {
+		return err
+	}
+	if state.SessionGroupWinner != 0 {
+		msc.group.mu.Lock()
+		msc.group.hasWon = true
+		msc.group.winnerId = state.SessionGroupWinner
+		msc.group.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package impl
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+const (
+	defaultBackgroundTimerClampMs = 60000
+)
+
+// clientClock models a browser's imperfect timekeeping: a wall clock skewed from the server's & timers
+// clamped (e.g. to once a minute) while the tab is in the background. Its zero value is a perfect clock.
+type clientClock struct {
+	offset time.Duration
+
+	backgroundTimerClamp time.Duration
+
+	// Tab visibility alternates with exponentially distributed durations (disabled if both are zero).
+	meanVisibleDur time.Duration
+	meanHiddenDur  time.Duration
+
+	hidden               bool
+	nextVisibilityChange time.Time
+}
+
+func makeClientClock(config client.ClientConfig) clientClock {
+	offsetMeanMs := intProperty(config, "clock_offset_mean_ms")
+	offsetStddevMs := intProperty(config, "clock_offset_stddev_ms")
+	offsetMs := float64(offsetMeanMs) + rand.NormFloat64()*float64(offsetStddevMs)
+
+	clampMs := intProperty(config, "background_timer_clamp_ms")
+	meanVisibleSecs := floatProperty(config, "mean_visible_secs")
+	meanHiddenSecs := floatProperty(config, "mean_hidden_secs")
+
+	cc := clientClock{
+		offset:               time.Duration(offsetMs * float64(time.Millisecond)),
+		backgroundTimerClamp: time.Duration(clampMs) * time.Millisecond,
+		meanVisibleDur:       time.Duration(meanVisibleSecs * float64(time.Second)),
+		meanHiddenDur:        time.Duration(meanHiddenSecs * float64(time.Second)),
+	}
+	if cc.meanHiddenDur > 0 {
+		// Start hidden with the long-run fraction of time spent hidden.
+		hiddenFraction := meanHiddenSecs / (meanVisibleSecs + meanHiddenSecs)
+		cc.hidden = rand.Float64() < hiddenFraction
+		cc.nextVisibilityChange = time.Now().Add(cc.sampleVisibilityDur())
+	}
+	return cc
+}
+
+// The client's (skewed) view of the current time.
+func (cc *clientClock) now() time.Time {
+	return time.Now().Add(cc.offset)
+}
+
+// Duration the client believes remains until t (a server-issued timestamp).
+func (cc *clientClock) until(t time.Time) time.Duration {
+	return t.Sub(cc.now())
+}
+
+func (cc *clientClock) isHidden() bool {
+	if cc.meanHiddenDur <= 0 {
+		return false
+	}
+	if cc.meanVisibleDur <= 0 {
+		return true
+	}
+	for now := time.Now(); !now.Before(cc.nextVisibilityChange); {
+		cc.hidden = !cc.hidden
+		cc.nextVisibilityChange = cc.nextVisibilityChange.Add(cc.sampleVisibilityDur())
+	}
+	return cc.hidden
+}
+
+// Delay a timer requested for d actually fires after, given the tab's current visibility.
+func (cc *clientClock) timerDelay(d time.Duration) time.Duration {
+	if cc.isHidden() && d < cc.backgroundTimerClamp {
+		return cc.backgroundTimerClamp
+	}
+	return d
+}
+
+func (cc *clientClock) sampleVisibilityDur() time.Duration {
+	meanDur := cc.meanVisibleDur
+	if cc.hidden {
+		meanDur = cc.meanHiddenDur
+	}
+	return time.Duration(rand.ExpFloat64() * float64(meanDur))
+}
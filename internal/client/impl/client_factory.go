@@ -7,18 +7,34 @@ import (
 	"time"
 
 	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/client/script"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network"
 	"github.com/Shopify/goqueuesim/internal/network_mock"
 )
 
 const (
 	defaultPollIntervalSeconds = 5
+	defaultReloadDelayMs       = 1000
+
+	defaultRequestTimeoutMs      = 10000
+	defaultRequestRetryBackoffMs = 500
 )
 
 type NetworkParams struct {
 	Ctx                      context.Context
+	ShopId                   int
 	ClientsFinishedWaitGroup *sync.WaitGroup
 	RequestTargetChannel     chan *network_mock.MockRequest
 	ResponseChannelsMap      map[int]chan *network_mock.MockResponse
+	Network                  network.Model
+	Metrics                  *metrics.Recorder
+
+	// Shared by colluding clients leaking throttle cookies to one another.
+	CookieJar *CookieJar
+
+	// Property values sampled for each client (by id) of the checkpoint resumed, so that they are not drawn afresh.
+	ResumedProperties map[int]map[string]float64
 }
 
 func MakeClientFromConfig(
@@ -26,21 +42,36 @@ func MakeClientFromConfig(
 	networkParams NetworkParams,
 	id int,
 ) client.Client {
+	config = sampleProperties(config, networkParams.ResumedProperties[id])
+	schema := mustClientTypeSchema(config.ClientType)
+	if schema.Make == nil {
+		panic(fmt.Errorf("client_type '%s' opens several sessions (see MakeSessionsFromConfig)", config.ClientType))
+	}
 	baseClient := MakeBaseClient(config, networkParams, id)
-	switch config.ClientType {
-	case "routinely_polling_client":
-		return &baseClient
-	case "fully_disappearing_client":
-		return MakeFullyDisappearingClient(config, &baseClient)
-	case "lazy_polling_client":
-		return MakeLazyPollingClient(config, &baseClient)
-	case "exponential_backoff_client":
-		return MakeExponentialBackoffClient(config, &baseClient)
-	case "jit_greedy_poller":
-		return MakeJitGreedyPoller(config, &baseClient)
-	default:
-		panic(fmt.Errorf("client type must be one of: {routinely_polling_client}"))
+	return schema.Make(config, networkParams, &baseClient)
+}
+
+// Returns the sessions backing one simulated person: a single client unless its type opens several.
+func MakeSessionsFromConfig(
+	config client.ClientConfig,
+	networkParams NetworkParams,
+	firstId int,
+) []client.Client {
+	// Sampled once per person => sessions of the same person behave alike.
+	config = sampleProperties(config, networkParams.ResumedProperties[firstId])
+	if schema := mustClientTypeSchema(config.ClientType); schema.MakeSessions != nil {
+		return schema.MakeSessions(config, networkParams, firstId)
+	}
+	return []client.Client{MakeClientFromConfig(config, networkParams, firstId)}
+}
+
+// Configs passed ValidateClientConfig => unknown client types are a programming error.
+func mustClientTypeSchema(clientType string) ClientTypeSchema {
+	_, schema, err := clientTypes.Lookup(clientType)
+	if err != nil {
+		panic(err)
 	}
+	return schema.(ClientTypeSchema)
 }
 
 func MakeBaseClient(
@@ -48,21 +79,39 @@ func MakeBaseClient(
 	networkParams NetworkParams,
 	id int,
 ) BaseClient {
-	pollIntervalSeconds, found := config.CustomIntProperties["dflt_poll_interval_seconds"]
-	if !found || pollIntervalSeconds <= 0 {
-		pollIntervalSeconds = defaultPollIntervalSeconds
+	defaultPollInterval := time.Duration(intProperty(config, "dflt_poll_interval_seconds")) * time.Second
+	if config.PollIntervalSeconds != nil {
+		// Nominal interval (e.g. backoff clients' base) of clients polling at random intervals.
+		defaultPollInterval = time.Duration(config.PollIntervalSeconds.ExpectedValue() * float64(time.Second))
 	}
+	reloadDelayMs := intProperty(config, "reload_delay_ms")
+	networkParams.Network.AttachClient(id, config.LinkProfile)
 	return BaseClient{
 		Ctx:                      networkParams.Ctx,
 		Id:                       id,
+		personId:                 id,
+		ShopId:                   networkParams.ShopId,
 		label:                    config.HumanizedLabel,
+		priorityTier:             config.PriorityTier,
 		ClientsFinishedWaitGroup: networkParams.ClientsFinishedWaitGroup,
 		RequestTargetChannel:     networkParams.RequestTargetChannel,
+		Network:                  networkParams.Network,
+		Metrics:                  networkParams.Metrics,
 		HitCheckoutStep:          false,
-		DefaultPollInterval:      time.Duration(pollIntervalSeconds) * time.Second,
-		MaxInitialDelayMs:        config.MaxInitialDelayMs,
-		MaxNetworkJitterMs:       config.MaxNetworkJitterMs,
+		DefaultPollInterval:      defaultPollInterval,
 		ObeysPollAfter:           config.ObeysServerPollAfter,
+		InitialDelayMs:           config.InitialDelayDistribution(),
+		NetworkJitterMs:          config.NetworkJitterDistribution(),
+		PollIntervalSeconds:      config.PollIntervalSeconds,
+		MaxRetries:               intProperty(config, "max_retries"),
+		ReloadDelay:              time.Duration(reloadDelayMs) * time.Millisecond,
+		KeepsCookieOnReload:      boolProperty(config, "retry_keeps_throttle_cookie"),
+		RequestTimeout:           time.Duration(intProperty(config, "request_timeout_ms")) * time.Millisecond,
+		MaxRequestRetries:        intProperty(config, "max_request_retries"),
+		RequestRetryBackoff:      time.Duration(intProperty(config, "request_retry_backoff_ms")) * time.Millisecond,
+		PollingStrategy:          withClientDefaults(fixedInterval{}, config.ObeysServerPollAfter),
+		clock:                    makeClientClock(config),
+		sampledProperties:        config.SampledProperties,
 		StartedPolling:           false,
 		state:                    client.Initial,
 	}
@@ -72,10 +121,11 @@ func MakeFullyDisappearingClient(
 	config client.ClientConfig,
 	baseClient *BaseClient,
 ) client.Client {
-	pollsBeforeDisappearing, found := config.CustomIntProperties["polls_before_disappearing"]
-	if !found || pollsBeforeDisappearing < 0 {
-		pollsBeforeDisappearing = 0
-	}
+	pollsBeforeDisappearing := intProperty(config, "polls_before_disappearing")
+	baseClient.PollingStrategy = withClientDefaults(
+		givingUpAfter{fixedInterval{}, pollsBeforeDisappearing},
+		config.ObeysServerPollAfter,
+	)
 	return &FullyDisappearingClient{
 		BaseClient:              baseClient,
 		PollsBeforeDisappearing: pollsBeforeDisappearing,
@@ -86,14 +136,12 @@ func MakeLazyPollingClient(
 	config client.ClientConfig,
 	baseClient *BaseClient,
 ) client.Client {
-	skipPollingRoundProbabilityPct, found := config.CustomFloatProperties["skip_polling_probability_pct"]
-	if !found || skipPollingRoundProbabilityPct <= 0 || skipPollingRoundProbabilityPct >= 1 {
-		skipPollingRoundProbabilityPct = 0.05
-	}
-	maxSleepMs, found := config.CustomIntProperties["max_ms_sleep_dur"]
-	if !found || maxSleepMs <= 0 {
-		maxSleepMs = 10000
-	}
+	skipPollingRoundProbabilityPct := floatProperty(config, "skip_polling_probability_pct")
+	maxSleepMs := intProperty(config, "max_ms_sleep_dur")
+	baseClient.PollingStrategy = withClientDefaults(
+		withInactivity{fixedInterval{}, skipPollingRoundProbabilityPct, time.Duration(maxSleepMs) * time.Millisecond},
+		config.ObeysServerPollAfter,
+	)
 	return &LazyPollingClient{
 		BaseClient:                     baseClient,
 		SkipPollingRoundProbabilityPct: skipPollingRoundProbabilityPct,
@@ -105,11 +153,12 @@ func MakeExponentialBackoffClient(
 	config client.ClientConfig,
 	baseClient *BaseClient,
 ) client.Client {
-	maximumBackoffMs, found := config.CustomIntProperties["maximum_backoff_ms"]
-	if !found || maximumBackoffMs <= 0 {
-		maximumBackoffMs = 1
-	}
+	maximumBackoffMs := intProperty(config, "maximum_backoff_ms")
 	maximumBackoff := time.Duration(maximumBackoffMs) * time.Millisecond
+	baseClient.PollingStrategy = withClientDefaults(
+		withBackoffCap{exponentialBackoff{}, maximumBackoff},
+		config.ObeysServerPollAfter,
+	)
 	return &ExponentialBackoffClient{
 		BaseClient:     baseClient,
 		MaximumBackoff: maximumBackoff,
@@ -120,29 +169,20 @@ func MakeJitGreedyPoller(
 	config client.ClientConfig,
 	baseClient *BaseClient,
 ) client.Client {
-	windowCheaterOnly, found := config.CustomBoolProperties["window_cheater_only"]
-	if !found {
-		windowCheaterOnly = false
-	}
-	greedyPollsCount, found := config.CustomIntProperties["greedy_polls_count"]
-	if !found || greedyPollsCount <= 0 {
-		greedyPollsCount = 1
-	}
-	earlyPollLeadTimeMs, found := config.CustomIntProperties["early_poll_lead_time_ms"]
-	if !found || earlyPollLeadTimeMs <= 0 {
-		earlyPollLeadTimeMs = 1
-	}
-	greedyPollsDelayMs, found := config.CustomIntProperties["greedy_polls_delay_ms"]
-	if !found || greedyPollsDelayMs <= 0 {
-		greedyPollsDelayMs = 1
-	}
-	windowDurMs, found := config.CustomIntProperties["window_dur_ms"]
-	if !found || windowDurMs <= 0 {
-		windowDurMs = 1
-	}
+	windowCheaterOnly := boolProperty(config, "window_cheater_only")
+	greedyPollsCount := intProperty(config, "greedy_polls_count")
+	earlyPollLeadTimeMs := intProperty(config, "early_poll_lead_time_ms")
+	greedyPollsDelayMs := intProperty(config, "greedy_polls_delay_ms")
+	windowDurMs := intProperty(config, "window_dur_ms")
 	earlyPollLeadTime := time.Duration(earlyPollLeadTimeMs) * time.Millisecond
 	greedyPollsDelay := time.Duration(greedyPollsDelayMs) * time.Millisecond
 	windowDur := time.Duration(windowDurMs) * time.Millisecond
+	// Greedy pollers time polls themselves off poll-after advice rather than obeying it.
+	baseClient.PollingStrategy = withJitter{inGreedyBursts{
+		windowAligned{windowCheaterOnly, windowDur, earlyPollLeadTime},
+		greedyPollsCount,
+		greedyPollsDelay,
+	}}
 	return &JitGreedyPoller{
 		BaseClient:        baseClient,
 		windowCheaterOnly: windowCheaterOnly,
@@ -152,3 +192,85 @@ func MakeJitGreedyPoller(
 		windowDur:         windowDur,
 	}
 }
+
+func MakeEtaSensitiveClient(
+	config client.ClientConfig,
+	baseClient *BaseClient,
+) client.Client {
+	maxToleratedWaitSeconds := intProperty(config, "max_tolerated_wait_seconds")
+	return &EtaSensitiveClient{
+		BaseClient:       baseClient,
+		MaxToleratedWait: time.Duration(maxToleratedWaitSeconds) * time.Second,
+	}
+}
+
+func MakeImpatientClient(
+	config client.ClientConfig,
+	baseClient *BaseClient,
+) client.Client {
+	patience, runsOut := samplePatience(config)
+	return &ImpatientClient{
+		BaseClient:    baseClient,
+		Patience:      patience,
+		NeverAbandons: !runsOut,
+	}
+}
+
+func MakeMultiSessionClients(
+	config client.ClientConfig,
+	networkParams NetworkParams,
+	firstId int,
+) []client.Client {
+	numSessions := intProperty(config, "num_sessions")
+	group := &sessionGroup{sessions: make([]*MultiSessionClient, 0, numSessions)}
+	sessions := make([]client.Client, 0, numSessions)
+	for i := 0; i < numSessions; i++ {
+		baseClient := MakeBaseClient(config, networkParams, firstId+i)
+		baseClient.personId = firstId
+		session := &MultiSessionClient{BaseClient: &baseClient, group: group}
+		group.sessions = append(group.sessions, session)
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func MakeCookieTamperingClient(
+	config client.ClientConfig,
+	networkParams NetworkParams,
+	baseClient *BaseClient,
+) client.Client {
+	strategy := stringProperty(config, "tamper_strategy")
+	tamperedValue := intProperty(config, "tampered_value")
+	maxTamperedPolls := intProperty(config, "max_tampered_polls")
+	return &CookieTamperingClient{
+		BaseClient:       baseClient,
+		Strategy:         strategy,
+		TamperedValue:    int64(tamperedValue),
+		MaxTamperedPolls: maxTamperedPolls,
+		Jar:              networkParams.CookieJar,
+	}
+}
+
+func MakeScriptedClient(
+	config client.ClientConfig,
+	baseClient *BaseClient,
+) client.Client {
+	nextPollDelay, err := script.Compile(stringProperty(config, "next_poll_delay_ms"), scriptVariables)
+	if err != nil {
+		panic(err)
+	}
+	var exitWhen *script.Expr
+	if exitWhenSrc, found := config.CustomStringProperties["exit_when"]; found {
+		exitWhen, err = script.Compile(exitWhenSrc, scriptVariables)
+		if err != nil {
+			panic(err)
+		}
+	}
+	// Scripts decide for themselves whether to obey poll-after advice.
+	baseClient.PollingStrategy = withJitter{scriptedPolling{nextPollDelay}}
+	return &ScriptedClient{
+		BaseClient:    baseClient,
+		NextPollDelay: nextPollDelay,
+		ExitWhen:      exitWhen,
+	}
+}
@@ -0,0 +1,552 @@
+package impl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/client/script"
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/registry"
+)
+
+type PropertyKind string
+
+const (
+	StringProperty PropertyKind = "string"
+	IntProperty    PropertyKind = "int"
+	FloatProperty  PropertyKind = "float"
+	BoolProperty   PropertyKind = "bool"
+)
+
+// PropertySchema documents & constrains one of a client type's custom_*_properties.
+type PropertySchema struct {
+	Name string
+	// Name is a prefix followed by a numeric suffix (e.g. "hazard_rate_after_seconds:30").
+	IsPrefix    bool
+	Kind        PropertyKind
+	Description string
+	// Value used when the property is absent (nil => required unless Optional).
+	Default  interface{}
+	Optional bool
+	// Inclusive bounds of numeric properties.
+	Min float64
+	Max float64
+	// Accepted values of string properties (any if empty).
+	OneOf []string
+	// Further validation of string properties (e.g. compiling scripts).
+	check func(value string) error
+}
+
+// Builds a client of its type around the base client every type shares.
+type ClientConstructor func(config client.ClientConfig, networkParams NetworkParams, baseClient *BaseClient) client.Client
+
+// Builds every session of a person whose type opens several (ids from firstId onwards).
+type SessionsConstructor func(config client.ClientConfig, networkParams NetworkParams, firstId int) []client.Client
+
+// ClientTypeSchema documents a client_type & the custom properties it accepts (on top of the common ones), along
+// with its constructor (exactly one of Make & MakeSessions).
+type ClientTypeSchema struct {
+	ClientType   string
+	Description  string
+	Properties   []PropertySchema
+	Make         ClientConstructor
+	MakeSessions SessionsConstructor
+	// Whether clients of the type act upon the queue position & ETA advertised (only kept up to date by some queues).
+	ActsOnAdmissionEstimates bool
+}
+
+func intSchema(name string, dflt int, min, max float64, description string) PropertySchema {
+	return PropertySchema{Name: name, Kind: IntProperty, Default: dflt, Min: min, Max: max, Description: description}
+}
+
+func floatSchema(name string, dflt float64, min, max float64, description string) PropertySchema {
+	return PropertySchema{Name: name, Kind: FloatProperty, Default: dflt, Min: min, Max: max, Description: description}
+}
+
+func boolSchema(name string, dflt bool, description string) PropertySchema {
+	return PropertySchema{Name: name, Kind: BoolProperty, Default: dflt, Description: description}
+}
+
+func requiredIntSchema(name string, min, max float64, description string) PropertySchema {
+	return PropertySchema{Name: name, Kind: IntProperty, Min: min, Max: max, Description: description}
+}
+
+func scriptSchema(name string, dflt interface{}, description string) PropertySchema {
+	return PropertySchema{
+		Name:        name,
+		Kind:        StringProperty,
+		Default:     dflt,
+		Optional:    dflt == nil,
+		Description: description,
+		check: func(value string) error {
+			_, err := script.Compile(value, scriptVariables)
+			return err
+		},
+	}
+}
+
+var unbounded = math.Inf(1)
+
+// Properties accepted by every client type.
+var commonPropertySchemas = []PropertySchema{
+	intSchema("dflt_poll_interval_seconds", defaultPollIntervalSeconds, 1, unbounded, "Seconds between routine polls."),
+	intSchema("max_retries", 0, 0, unbounded, "Reloads attempted after being dropped (0 => give up)."),
+	intSchema("reload_delay_ms", defaultReloadDelayMs, 0, unbounded, "Delay before reloading after being dropped."),
+	boolSchema("retry_keeps_throttle_cookie", false, "Whether reloads keep the throttle cookie (resuming the queue place)."),
+	intSchema("request_timeout_ms", defaultRequestTimeoutMs, 0, unbounded, "Wait for a response before retrying (0 => forever)."),
+	intSchema("max_request_retries", 0, 0, unbounded, "Retries of unanswered or shed requests before being dropped."),
+	intSchema("request_retry_backoff_ms", defaultRequestRetryBackoffMs, 0, unbounded, "Delay before the first retry (doubling after)."),
+	intSchema("clock_offset_mean_ms", 0, -unbounded, unbounded, "Mean skew of the client's clock from the server's."),
+	intSchema("clock_offset_stddev_ms", 0, 0, unbounded, "Standard deviation of the client's clock skew."),
+	intSchema("background_timer_clamp_ms", defaultBackgroundTimerClampMs, 0, unbounded, "Minimum timer delay while the tab is hidden."),
+	floatSchema("mean_visible_secs", 0, 0, unbounded, "Mean duration the tab stays visible."),
+	floatSchema("mean_hidden_secs", 0, 0, unbounded, "Mean duration the tab stays hidden (0 => never hidden)."),
+}
+
+var builtinClientTypeSchemas = []ClientTypeSchema{
+	{
+		ClientType:  "routinely_polling_client",
+		Make:        makeRoutinelyPollingClient,
+		Description: "Polls every dflt_poll_interval_seconds (or when advised to, if obedient).",
+	},
+	{
+		ClientType:  "fully_disappearing_client",
+		Make:        ignoringNetworkParams(MakeFullyDisappearingClient),
+		Description: "Stops polling entirely (vanishes) after some number of polls.",
+		Properties: []PropertySchema{
+			intSchema("polls_before_disappearing", 0, 0, unbounded, "Polls made before vanishing."),
+		},
+	},
+	{
+		ClientType:  "lazy_polling_client",
+		Make:        ignoringNetworkParams(MakeLazyPollingClient),
+		Description: "Randomly goes inactive for a while between polls.",
+		Properties: []PropertySchema{
+			floatSchema("skip_polling_probability_pct", 0.05, 0, 0.99, "Probability of going inactive between polls (0-1)."),
+			intSchema("max_ms_sleep_dur", 10000, 1, unbounded, "Longest inactive period."),
+		},
+	},
+	{
+		ClientType:  "exponential_backoff_client",
+		Make:        ignoringNetworkParams(MakeExponentialBackoffClient),
+		Description: "Doubles its polling interval after every poll.",
+		Properties: []PropertySchema{
+			requiredIntSchema("maximum_backoff_ms", 1, unbounded, "Cap on the polling interval."),
+		},
+	},
+	{
+		ClientType:  "jit_greedy_poller",
+		Make:        ignoringNetworkParams(MakeJitGreedyPoller),
+		Description: "Polls in greedy bursts timed just before rate tracker windows open (or poll-afters are due).",
+		Properties: []PropertySchema{
+			boolSchema("window_cheater_only", false, "Whether to ignore poll-after advice & only time windows."),
+			intSchema("greedy_polls_count", 1, 1, unbounded, "Polls per burst."),
+			intSchema("greedy_polls_delay_ms", 100, 1, unbounded, "Delay between polls of a burst."),
+			intSchema("early_poll_lead_time_ms", 200, 0, unbounded, "How early bursts start before windows open."),
+			requiredIntSchema("window_dur_ms", 1, unbounded, "Duration of the targeted rate tracker windows."),
+		},
+	},
+	{
+		ClientType:  "eta_sensitive_client",
+		Make:        ignoringNetworkParams(MakeEtaSensitiveClient),
+		Description: "Abandons as soon as the advertised wait exceeds its tolerance.",
+
+		ActsOnAdmissionEstimates: true,
+		Properties: []PropertySchema{
+			intSchema("max_tolerated_wait_seconds", 120, 1, unbounded, "Longest advertised wait tolerated."),
+		},
+	},
+	{
+		ClientType:  "impatient_client",
+		Make:        ignoringNetworkParams(MakeImpatientClient),
+		Description: "Abandons once its randomly sampled patience runs out.",
+		Properties: []PropertySchema{
+			{
+				Name:        "patience_distribution",
+				Kind:        StringProperty,
+				Default:     "exponential",
+				OneOf:       []string{"exponential", "weibull", "hazard"},
+				Description: "Distribution patience is sampled from.",
+			},
+			floatSchema("patience_mean_seconds", defaultPatienceMeanSeconds, 0.001, unbounded, "Mean exponential patience."),
+			floatSchema("patience_weibull_shape", defaultPatienceWeibullShape, 0.001, unbounded, "Weibull patience shape."),
+			floatSchema("patience_weibull_scale_seconds", defaultPatienceWeibullScaleSecs, 0.001, unbounded, "Weibull patience scale."),
+			{
+				Name:        hazardRateAfterSecondsPropPrefix,
+				IsPrefix:    true,
+				Kind:        FloatProperty,
+				Optional:    true,
+				Max:         unbounded,
+				Description: "Abandonment rate per second from the suffixed number of seconds waited onwards.",
+			},
+		},
+	},
+	{
+		ClientType:   "multi_session_client",
+		MakeSessions: MakeMultiSessionClients,
+		Description:  "Person opening several sessions (e.g. tabs) & checking out with the first admitted.",
+		Properties: []PropertySchema{
+			intSchema("num_sessions", 3, 1, unbounded, "Sessions opened by the person."),
+		},
+	},
+	{
+		ClientType:  "cookie_tampering_client",
+		Make:        MakeCookieTamperingClient,
+		Description: "Rewrites the queue state stored in its throttle cookie before polling.",
+		Properties: []PropertySchema{
+			{
+				Name:        "tamper_strategy",
+				Kind:        StringProperty,
+				Default:     "lower_bin",
+				OneOf:       []string{"lower_bin", "copy_cookie", "replay_stale"},
+				Description: "How the cookie is tampered with.",
+			},
+			intSchema("tampered_value", 0, -unbounded, unbounded, "Value integer cookie fields are rewritten to (lower_bin)."),
+			intSchema("max_tampered_polls", 10, 1, unbounded, "Polls after which the client gives up tampering."),
+		},
+	},
+	{
+		ClientType:  "scripted_client",
+		Make:        ignoringNetworkParams(MakeScriptedClient),
+		Description: "Polls & abandons as defined by script expressions.",
+		Properties: []PropertySchema{
+			scriptSchema("next_poll_delay_ms", "default_poll_interval_ms", "Expression evaluated after every poll for the delay before the next."),
+			scriptSchema("exit_when", nil, "Expression evaluated after every response: abandons when non-zero."),
+		},
+	},
+}
+
+var clientTypes = registry.MakeRegistry("client_type")
+
+// Makes a client type available by name (typically from an init function of the file implementing it).
+func RegisterClientType(schema ClientTypeSchema) {
+	if (schema.Make == nil) == (schema.MakeSessions == nil) {
+		panic(fmt.Errorf("client_type '%s' must set exactly one of Make & MakeSessions", schema.ClientType))
+	}
+	clientTypes.Register(registry.Plugin{Name: schema.ClientType, Description: schema.Description}, schema)
+}
+
+func init() {
+	for _, schema := range builtinClientTypeSchemas {
+		RegisterClientType(schema)
+	}
+}
+
+func makeRoutinelyPollingClient(_ client.ClientConfig, _ NetworkParams, baseClient *BaseClient) client.Client {
+	return baseClient
+}
+
+func ignoringNetworkParams(
+	makeClient func(config client.ClientConfig, baseClient *BaseClient) client.Client,
+) ClientConstructor {
+	return func(config client.ClientConfig, _ NetworkParams, baseClient *BaseClient) client.Client {
+		return makeClient(config, baseClient)
+	}
+}
+
+// Documented schemas of every registered client type, sorted by client_type.
+func ClientTypeSchemas() []ClientTypeSchema {
+	schemas := make([]ClientTypeSchema, 0)
+	for _, name := range clientTypes.Names() {
+		schema, _ := clientTypeSchema(name)
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// Properties accepted by every client type on top of their own.
+func CommonPropertySchemas() []PropertySchema {
+	return append([]PropertySchema(nil), commonPropertySchemas...)
+}
+
+// Whether clients configured by config act upon the queue position & ETA advertised.
+func ActsOnAdmissionEstimates(config client.ClientConfig) bool {
+	schema, _ := clientTypeSchema(config.ClientType)
+	return schema.ActsOnAdmissionEstimates
+}
+
+// Whether clients configured by config wait forever for their responses (a request_timeout_ms of 0, whether fixed,
+// defaulted or constantly distributed).
+func AwaitsResponsesForever(config client.ClientConfig) bool {
+	if distribution, found := config.PropertyDistributions["request_timeout_ms"]; found {
+		return distribution.Type == "constant" && math.Round(distribution.Value) <= 0
+	}
+	return intProperty(config, "request_timeout_ms") <= 0
+}
+
+func clientTypeSchema(clientType string) (ClientTypeSchema, bool) {
+	_, schema, err := clientTypes.Lookup(clientType)
+	if err != nil {
+		return ClientTypeSchema{}, false
+	}
+	return schema.(ClientTypeSchema), true
+}
+
+func propertySchema(clientType, name string) (PropertySchema, bool) {
+	typeSchema, _ := clientTypeSchema(clientType)
+	for _, properties := range [][]PropertySchema{typeSchema.Properties, commonPropertySchemas} {
+		for _, property := range properties {
+			if property.Name == name || property.IsPrefix && strings.HasPrefix(name, property.Name) {
+				return property, true
+			}
+		}
+	}
+	return PropertySchema{}, false
+}
+
+// ValidateClientConfig reports every problem with config: unknown client type, unknown or mistyped properties,
+// missing required ones & out of range values.
+func ValidateClientConfig(config client.ClientConfig) error {
+	problems := make([]string, 0)
+	typeSchema, found := clientTypeSchema(config.ClientType)
+	if !found {
+		_, _, err := clientTypes.Lookup(config.ClientType)
+		return err
+	}
+	if config.RepresentationPercent < 0 || config.RepresentationPercent > 1 {
+		problems = append(problems, fmt.Sprintf("representation_percent must be within [0, 1], got %v", config.RepresentationPercent))
+	}
+	if config.PriorityTier < 0 {
+		problems = append(problems, fmt.Sprintf("priority_tier must be >= 0, got %d", config.PriorityTier))
+	}
+	if config.MaxInitialDelayMs < 0 {
+		problems = append(problems, fmt.Sprintf("max_initial_delay_ms must be >= 0, got %d", config.MaxInitialDelayMs))
+	}
+	if config.MaxNetworkJitterMs < 0 {
+		problems = append(problems, fmt.Sprintf("max_network_jitter_ms must be >= 0, got %d", config.MaxNetworkJitterMs))
+	}
+	if _, found := network.LinkProfiles[config.LinkProfile]; config.LinkProfile != "" && !found {
+		problems = append(problems, fmt.Sprintf(
+			"link_profile must be one of: {%s}, got '%s'", strings.Join(network.LinkProfileNames(), ", "), config.LinkProfile,
+		))
+	}
+	fieldDistributions := []struct {
+		name         string
+		distribution *client.Distribution
+		replaced     string
+		replacedSet  bool
+	}{
+		{"initial_delay_ms", config.InitialDelayMs, "max_initial_delay_ms", config.MaxInitialDelayMs != 0},
+		{"network_jitter_ms", config.NetworkJitterMs, "max_network_jitter_ms", config.MaxNetworkJitterMs != 0},
+		{"poll_interval_seconds", config.PollIntervalSeconds, "dflt_poll_interval_seconds", isPropertyGiven(config, "dflt_poll_interval_seconds")},
+	}
+	for _, field := range fieldDistributions {
+		if field.distribution == nil {
+			continue
+		}
+		if field.replacedSet {
+			problems = append(problems, fmt.Sprintf("%s & %s are mutually exclusive", field.name, field.replaced))
+		}
+		if err := field.distribution.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", field.name, err.Error()))
+		}
+	}
+
+	if config.Count < 0 {
+		problems = append(problems, fmt.Sprintf("count must be >= 0, got %d", config.Count))
+	}
+	if len(config.Mixture) > 0 {
+		problems = append(problems, "mixtures must be resolved (see client.ResolveClientDistribution) before validation")
+	}
+
+	given := make(map[string]PropertyKind)
+	checkProperty := func(kind PropertyKind, name string, value interface{}) {
+		given[name] = kind
+		property, found := propertySchema(config.ClientType, name)
+		if !found {
+			problems = append(problems, fmt.Sprintf("unknown property '%s' for client_type %s", name, config.ClientType))
+			return
+		}
+		if property.Kind != kind {
+			problems = append(problems, fmt.Sprintf(
+				"property '%s' must be given in custom_%s_properties, not custom_%s_properties", name, property.Kind, kind,
+			))
+			return
+		}
+		if problem := property.validate(name, value); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	for name, value := range config.CustomStringProperties {
+		checkProperty(StringProperty, name, value)
+	}
+	for name, value := range config.CustomIntProperties {
+		checkProperty(IntProperty, name, value)
+	}
+	for name, value := range config.CustomFloatProperties {
+		checkProperty(FloatProperty, name, value)
+	}
+	for name, value := range config.CustomBoolProperties {
+		checkProperty(BoolProperty, name, value)
+	}
+	for name, distribution := range config.PropertyDistributions {
+		property, found := propertySchema(config.ClientType, name)
+		switch {
+		case !found:
+			problems = append(problems, fmt.Sprintf("unknown property '%s' for client_type %s", name, config.ClientType))
+		case property.Kind != IntProperty && property.Kind != FloatProperty:
+			problems = append(problems, fmt.Sprintf("%s property '%s' cannot be sampled from a distribution", property.Kind, name))
+		case given[name] != "":
+			problems = append(problems, fmt.Sprintf("property '%s' is given both a fixed value & a distribution", name))
+		default:
+			if err := distribution.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("property '%s': %s", name, err.Error()))
+			}
+		}
+		given[name] = property.Kind
+	}
+	for _, property := range typeSchema.Properties {
+		if _, ok := given[property.Name]; !ok && property.Default == nil && !property.Optional {
+			problems = append(problems, fmt.Sprintf(
+				"missing required property '%s' (custom_%s_properties)", property.Name, property.Kind,
+			))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// Returns a description of what is wrong with value (empty if valid).
+func (ps PropertySchema) validate(name string, value interface{}) string {
+	if ps.IsPrefix {
+		suffix := strings.TrimPrefix(name, ps.Name)
+		if v, err := strconv.ParseFloat(suffix, 64); err != nil || v < 0 {
+			return fmt.Sprintf("property '%s' must be suffixed by a number >= 0, got '%s'", name, suffix)
+		}
+	}
+	var number float64
+	switch v := value.(type) {
+	case int:
+		number = float64(v)
+	case float64:
+		number = v
+	case string:
+		if len(ps.OneOf) > 0 && !containsString(ps.OneOf, v) {
+			return fmt.Sprintf("property '%s' must be one of: {%s}, got '%s'", name, strings.Join(ps.OneOf, ", "), v)
+		}
+		if ps.check != nil {
+			if err := ps.check(v); err != nil {
+				return fmt.Sprintf("property '%s': %s", name, err.Error())
+			}
+		}
+		return ""
+	default:
+		return ""
+	}
+	if number < ps.Min || number > ps.Max {
+		return fmt.Sprintf("property '%s' must be within %s, got %v", name, ps.Range(), value)
+	}
+	return ""
+}
+
+// Human readable bounds of numeric properties (e.g. "[1, inf)").
+func (ps PropertySchema) Range() string {
+	if ps.Kind != IntProperty && ps.Kind != FloatProperty {
+		return ""
+	}
+	lower, upper := "[", "]"
+	if math.IsInf(ps.Min, -1) {
+		lower = "("
+	}
+	if math.IsInf(ps.Max, 1) {
+		upper = ")"
+	}
+	return fmt.Sprintf("%s%v, %v%s", lower, ps.Min, ps.Max, upper)
+}
+
+// Whether config gives name a fixed value or a distribution.
+func isPropertyGiven(config client.ClientConfig, name string) bool {
+	_, fixedInt := config.CustomIntProperties[name]
+	_, fixedFloat := config.CustomFloatProperties[name]
+	_, sampled := config.PropertyDistributions[name]
+	return fixedInt || fixedFloat || sampled
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Property values (or their schema defaults) for configs which passed ValidateClientConfig.
+
+func stringProperty(config client.ClientConfig, name string) string {
+	if v, found := config.CustomStringProperties[name]; found {
+		return v
+	}
+	property, _ := propertySchema(config.ClientType, name)
+	dflt, _ := property.Default.(string)
+	return dflt
+}
+
+func intProperty(config client.ClientConfig, name string) int {
+	if v, found := config.CustomIntProperties[name]; found {
+		return v
+	}
+	property, _ := propertySchema(config.ClientType, name)
+	dflt, _ := property.Default.(int)
+	return dflt
+}
+
+func floatProperty(config client.ClientConfig, name string) float64 {
+	if v, found := config.CustomFloatProperties[name]; found {
+		return v
+	}
+	property, _ := propertySchema(config.ClientType, name)
+	dflt, _ := property.Default.(float64)
+	return dflt
+}
+
+func boolProperty(config client.ClientConfig, name string) bool {
+	if v, found := config.CustomBoolProperties[name]; found {
+		return v
+	}
+	property, _ := propertySchema(config.ClientType, name)
+	dflt, _ := property.Default.(bool)
+	return dflt
+}
+
+// Returns a copy of config with every property_distributions entry replaced by a value sampled from it (clamped
+// into the property's bounds & rounded for int properties), unless resumed gives the value sampled before.
+func sampleProperties(config client.ClientConfig, resumed map[string]float64) client.ClientConfig {
+	if len(config.PropertyDistributions) == 0 {
+		return config
+	}
+	intProperties := make(map[string]int, len(config.CustomIntProperties)+len(config.PropertyDistributions))
+	for name, v := range config.CustomIntProperties {
+		intProperties[name] = v
+	}
+	floatProperties := make(map[string]float64, len(config.CustomFloatProperties)+len(config.PropertyDistributions))
+	for name, v := range config.CustomFloatProperties {
+		floatProperties[name] = v
+	}
+	sampled := make(map[string]float64, len(config.PropertyDistributions))
+	for name, distribution := range config.PropertyDistributions {
+		property, _ := propertySchema(config.ClientType, name)
+		value, found := resumed[name]
+		if !found {
+			value = math.Max(property.Min, math.Min(property.Max, distribution.Sample()))
+		}
+		sampled[name] = value
+		if property.Kind == IntProperty {
+			intProperties[name] = int(math.Round(value))
+		} else {
+			floatProperties[name] = value
+		}
+	}
+	config.CustomIntProperties = intProperties
+	config.CustomFloatProperties = floatProperties
+	config.PropertyDistributions = nil
+	config.SampledProperties = sampled
+	return config
+}
@@ -0,0 +1,49 @@
+package impl
+
+import (
+	"sync"
+	"time"
+)
+
+type leakedCookie struct {
+	queueEntryTime time.Time
+	cookie         map[string]string
+}
+
+// CookieJar is where colluding clients leak their throttle cookies for others to copy.
+// Only the earliest queued cookie of each shop is kept since it is the most valuable one.
+type CookieJar struct {
+	mu             sync.Mutex
+	earliestByShop map[int]leakedCookie
+}
+
+func MakeCookieJar() *CookieJar {
+	return &CookieJar{earliestByShop: make(map[int]leakedCookie)}
+}
+
+func (j *CookieJar) Leak(shopId int, queueEntryTime time.Time, cookie map[string]string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if leaked, ok := j.earliestByShop[shopId]; ok && !queueEntryTime.Before(leaked.queueEntryTime) {
+		return
+	}
+	j.earliestByShop[shopId] = leakedCookie{queueEntryTime: queueEntryTime, cookie: copyCookie(cookie)}
+}
+
+func (j *CookieJar) Earliest(shopId int) (map[string]string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	leaked, ok := j.earliestByShop[shopId]
+	if !ok {
+		return nil, false
+	}
+	return copyCookie(leaked.cookie), true
+}
+
+func copyCookie(cookie map[string]string) map[string]string {
+	copied := make(map[string]string, len(cookie))
+	for k, v := range cookie {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,77 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// CookieTamperingClient polls routinely but rewrites the queue state stored in its throttle cookie before polling:
+// -> lower_bin: integer cookie values (bin ids, queue positions) are replaced by TamperedValue
+// -> copy_cookie: the earliest queued cookie leaked to the shared CookieJar is copied
+// -> replay_stale: the cookie received upon being queued is replayed on every poll
+// After MaxTamperedPolls polls without being admitted, the client gives up tampering & polls honestly.
+type CookieTamperingClient struct {
+	*BaseClient
+	Strategy         string
+	TamperedValue    int64
+	MaxTamperedPolls int
+	Jar              *CookieJar
+
+	staleCookie   map[string]string
+	tamperedPolls int
+}
+
+func (ctc *CookieTamperingClient) HandleResponse(resp *network_mock.MockResponse) error {
+	if !ctc.isLocked {
+		return errors.New("lock required to handle server response")
+	}
+	stillQueued := ctc.IsQueued() && resp.ClientData.ThrottleState == client.Queued.String()
+	if stillQueued && ctc.tamperedPolls < ctc.MaxTamperedPolls {
+		ctc.tamper()
+		ctc.tamperedPolls++
+	}
+	ctc.delegateResponseTo(ctc, resp)
+	return nil
+}
+
+func (ctc *CookieTamperingClient) tamper() {
+	if ctc.staleCookie == nil {
+		// First response since being queued => cookie is still as the queue issued it.
+		ctc.staleCookie = copyCookie(ctc.throttleCookie)
+		ctc.Jar.Leak(ctc.ShopID(), ctc.QueueEntryTime(), ctc.throttleCookie)
+	}
+	switch ctc.Strategy {
+	case "lower_bin":
+		for key, value := range ctc.throttleCookie {
+			if _, err := strconv.ParseInt(value, 10, 64); err == nil && key != client.ThrottleStateKey {
+				ctc.throttleCookie[key] = strconv.FormatInt(ctc.TamperedValue, 10)
+			}
+		}
+	case "copy_cookie":
+		if leaked, ok := ctc.Jar.Earliest(ctc.ShopID()); ok {
+			ctc.replaceQueueCookieVals(leaked)
+		}
+	case "replay_stale":
+		ctc.replaceQueueCookieVals(ctc.staleCookie)
+	}
+	labelTag := fmt.Sprintf("client_label:%s", ctc.Label())
+	ctc.Metrics.Incr("client.cookie_tampered", []string{fmt.Sprintf("tamper_strategy:%s", ctc.Strategy), labelTag})
+}
+
+// Replaces every queue-issued cookie value (the throttle state is tracked by the client itself).
+func (ctc *CookieTamperingClient) replaceQueueCookieVals(cookie map[string]string) {
+	for key := range ctc.throttleCookie {
+		if key != client.ThrottleStateKey {
+			delete(ctc.throttleCookie, key)
+		}
+	}
+	for key, value := range cookie {
+		if key != client.ThrottleStateKey {
+			ctc.throttleCookie[key] = value
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// EtaSensitiveClient polls routinely but leaves the queue as soon as the advertised wait exceeds its tolerance.
+type EtaSensitiveClient struct {
+	*BaseClient
+	MaxToleratedWait time.Duration
+}
+
+func (esc *EtaSensitiveClient) HandleResponse(resp *network_mock.MockResponse) error {
+	if !esc.isLocked {
+		return errors.New("lock required to handle server response")
+	}
+	esc.delegateResponseTo(esc, resp)
+	eta := esc.EstimatedAdmissionTime()
+	if esc.IsQueued() && !eta.IsZero() && time.Until(eta) > esc.MaxToleratedWait {
+		_ = esc.MarkAbandoned()
+		esc.StopPolling()
+		labelTag := fmt.Sprintf("client_label:%s", esc.Label())
+		esc.Metrics.Incr("server.checkout", []string{"operation:eta_abandoned", labelTag})
+	}
+	return nil
+}
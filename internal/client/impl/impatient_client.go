@@ -0,0 +1,51 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// ImpatientClient polls routinely but abandons the queue once its (randomly sampled) patience runs out.
+type ImpatientClient struct {
+	*BaseClient
+	Patience      time.Duration
+	NeverAbandons bool
+
+	abandonTimer *time.Timer
+}
+
+func (ic *ImpatientClient) StartPolling() {
+	ic.BaseClient.StartPolling()
+	if ic.abandonTimer != nil || ic.NeverAbandons || !ic.IsQueued() || !ic.isLocked {
+		return
+	}
+	ic.abandonTimer = time.AfterFunc(time.Until(ic.QueueEntryTime().Add(ic.Patience)), ic.abandon)
+}
+
+func (ic *ImpatientClient) abandon() {
+	if ic.Ctx.Err() != nil {
+		return
+	}
+	ic.Lock()
+	defer ic.Unlock()
+	if !ic.IsQueued() {
+		return
+	}
+	_ = ic.MarkAbandoned()
+	ic.StopPolling()
+	labelTag := fmt.Sprintf("client_label:%s", ic.Label())
+	waitBucketTag := fmt.Sprintf("wait_bucket:%s", client.WaitBucketName(client.WaitBucketIdx(ic.QueueDuration())))
+	ic.Metrics.Incr("server.checkout", []string{"operation:abandoned", labelTag, waitBucketTag})
+}
+
+func (ic *ImpatientClient) HandleResponse(resp *network_mock.MockResponse) error {
+	if !ic.isLocked {
+		return errors.New("lock required to handle server response")
+	}
+	ic.delegateResponseTo(ic, resp)
+	return nil
+}
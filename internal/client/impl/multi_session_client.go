@@ -0,0 +1,68 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// sessionGroup ties together the sessions (e.g. browser tabs) one person opened to hold several queue places.
+type sessionGroup struct {
+	mu       sync.Mutex
+	sessions []*MultiSessionClient
+	winnerId int
+	hasWon   bool
+}
+
+// MultiSessionClient is one of several sessions of the same person, each with an independent throttle cookie.
+// The person checks out with whichever session is admitted first & abandons the others.
+type MultiSessionClient struct {
+	*BaseClient
+	group *sessionGroup
+}
+
+func (msc *MultiSessionClient) HandleResponse(resp *network_mock.MockResponse) error {
+	if !msc.isLocked {
+		return errors.New("lock required to handle server response")
+	}
+	msc.delegateResponseTo(msc, resp)
+	if msc.ReachedCheckout() {
+		msc.group.onSessionAdmitted(msc)
+	}
+	return nil
+}
+
+func (g *sessionGroup) onSessionAdmitted(winner *MultiSessionClient) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	labelTag := fmt.Sprintf("client_label:%s", winner.Label())
+	if g.hasWon {
+		if g.winnerId != winner.ID() {
+			// Sibling got admitted before it could be abandoned => person holds several checkouts.
+			winner.Metrics.Incr("server.checkout", []string{"operation:duplicate_session_checkout", labelTag})
+		}
+		return
+	}
+	g.hasWon = true
+	g.winnerId = winner.ID()
+	// Siblings are locked asynchronously since the winner's lock is held (avoiding lock-order deadlocks).
+	go g.abandonSiblingsOf(winner.ID())
+}
+
+func (g *sessionGroup) abandonSiblingsOf(winnerId int) {
+	for _, session := range g.sessions {
+		if session.ID() == winnerId {
+			continue
+		}
+		session.Lock()
+		if !session.HasExited() && !session.ReachedCheckout() {
+			_ = session.MarkAbandoned()
+			session.StopPolling()
+			labelTag := fmt.Sprintf("client_label:%s", session.Label())
+			session.Metrics.Incr("server.checkout", []string{"operation:sibling_session_abandoned", labelTag})
+		}
+		session.Unlock()
+	}
+}
@@ -0,0 +1,92 @@
+package impl
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+const (
+	defaultPatienceMeanSeconds       = 60.0
+	defaultPatienceWeibullShape      = 1.5
+	defaultPatienceWeibullScaleSecs  = 60.0
+	hazardRateAfterSecondsPropPrefix = "hazard_rate_after_seconds:"
+)
+
+type hazardSegment struct {
+	startSecs   float64
+	ratePerSecs float64
+}
+
+// Samples how long a client is willing to wait in queue, per its configured "patience_distribution":
+// -> exponential: constant abandonment rate with mean "patience_mean_seconds"
+// -> weibull: rate growing (shape > 1) or shrinking (shape < 1) with time waited
+// -> hazard: piecewise constant rates given as "hazard_rate_after_seconds:<t>" (rate per second from t onwards)
+// Returns false if the sampled client never runs out of patience (e.g. hazard curve dropping to 0).
+func samplePatience(config client.ClientConfig) (time.Duration, bool) {
+	var patienceSecs float64
+	switch distribution := stringProperty(config, "patience_distribution"); distribution {
+	case "exponential":
+		meanSecs := floatProperty(config, "patience_mean_seconds")
+		patienceSecs = meanSecs * standardExponential()
+	case "weibull":
+		shape := floatProperty(config, "patience_weibull_shape")
+		scaleSecs := floatProperty(config, "patience_weibull_scale_seconds")
+		patienceSecs = scaleSecs * math.Pow(standardExponential(), 1/shape)
+	case "hazard":
+		patienceSecs = sampleFromHazardCurve(parseHazardCurve(config))
+	default:
+		panic(fmt.Errorf("patience_distribution must be one of: {exponential, weibull, hazard}, got %s", distribution))
+	}
+	if math.IsInf(patienceSecs, 1) {
+		return 0, false
+	}
+	return time.Duration(patienceSecs * float64(time.Second)), true
+}
+
+func standardExponential() float64 {
+	return -math.Log(1 - rand.Float64())
+}
+
+func parseHazardCurve(config client.ClientConfig) []hazardSegment {
+	segments := make([]hazardSegment, 0)
+	for key, rate := range config.CustomFloatProperties {
+		if !strings.HasPrefix(key, hazardRateAfterSecondsPropPrefix) {
+			continue
+		}
+		startSecs, err := strconv.ParseFloat(strings.TrimPrefix(key, hazardRateAfterSecondsPropPrefix), 64)
+		if err != nil || startSecs < 0 || rate < 0 {
+			panic(fmt.Errorf("invalid hazard curve property %s=%f", key, rate))
+		}
+		segments = append(segments, hazardSegment{startSecs: startSecs, ratePerSecs: rate})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].startSecs < segments[j].startSecs })
+	return segments
+}
+
+// Inverts the cumulative hazard: patience is when it first exceeds a standard exponential draw.
+func sampleFromHazardCurve(segments []hazardSegment) float64 {
+	target := standardExponential()
+	cumulativeHazard := 0.0
+	for i, segment := range segments {
+		endSecs := math.Inf(1)
+		if i+1 < len(segments) {
+			endSecs = segments[i+1].startSecs
+		}
+		if segment.ratePerSecs <= 0 {
+			continue
+		}
+		segmentHazard := segment.ratePerSecs * (endSecs - segment.startSecs)
+		if cumulativeHazard+segmentHazard >= target {
+			return segment.startSecs + (target-cumulativeHazard)/segment.ratePerSecs
+		}
+		cumulativeHazard += segmentHazard
+	}
+	return math.Inf(1)
+}
@@ -0,0 +1,142 @@
+package impl
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// PollingStrategy decides when a queued client polls next. Strategies compose through decorators, e.g.
+// withJitter(obeyingPollAfter(fixedInterval{})) is how routinely polling clients behave.
+type PollingStrategy interface {
+	// Called with the client locked after each of its polls (and once with pollsSoFar = 0 before the first one).
+	// Returns the delay before polling again & whether to keep polling: if not, the client vanishes after the delay.
+	NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool)
+}
+
+// Polls every DefaultPollInterval (or as often as sampled from PollIntervalSeconds).
+type fixedInterval struct{}
+
+func (fixedInterval) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	return bc.pollInterval(), true
+}
+
+// Doubles the delay after every poll: (2^pollsSoFar) * DefaultPollInterval.
+type exponentialBackoff struct{}
+
+func (exponentialBackoff) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	backoffSecs := math.Pow(2, float64(pollsSoFar)) * bc.DefaultPollInterval.Seconds()
+	// Capped well before overflowing time.Duration (callers usually add a tighter cap on top).
+	return time.Duration(math.Min(backoffSecs, math.MaxInt32) * float64(time.Second)), true
+}
+
+// Polls just before every rate tracker window opens & (unless windowCheaterOnly) just before advised poll-afters.
+type windowAligned struct {
+	windowCheaterOnly bool
+	windowDur         time.Duration
+	earlyPollLeadTime time.Duration
+}
+
+func (wa windowAligned) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	jitWindowDur := (wa.windowDur - wa.earlyPollLeadTime) % wa.windowDur
+	if wa.windowCheaterOnly || bc.AdvisedPollAfter().IsZero() {
+		return jitWindowDur, true
+	}
+	earlyPollTime := bc.AdvisedPollAfter().Add(-wa.earlyPollLeadTime)
+	if remTimeToPoll := bc.clock.until(earlyPollTime); remTimeToPoll > jitWindowDur {
+		return remTimeToPoll, true
+	}
+	return jitWindowDur, true
+}
+
+// Adds random network jitter (sampled from NetworkJitterMs) to every delay.
+type withJitter struct {
+	PollingStrategy
+}
+
+func (wj withJitter) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := wj.PollingStrategy.NextPoll(bc, pollsSoFar)
+	return delay + sampleDuration(bc.NetworkJitterMs, time.Millisecond), keepPolling
+}
+
+// Waits until the server's advised poll-after (as per the client's clock) whenever one lies ahead.
+type obeyingPollAfter struct {
+	PollingStrategy
+}
+
+func (opa obeyingPollAfter) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := opa.PollingStrategy.NextPoll(bc, pollsSoFar)
+	if !bc.AdvisedPollAfter().IsZero() && bc.clock.now().Before(bc.AdvisedPollAfter()) {
+		return bc.clock.until(bc.AdvisedPollAfter()), keepPolling
+	}
+	return delay, keepPolling
+}
+
+// Never waits longer than maxDelay.
+type withBackoffCap struct {
+	PollingStrategy
+	maxDelay time.Duration
+}
+
+func (wbc withBackoffCap) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := wbc.PollingStrategy.NextPoll(bc, pollsSoFar)
+	if delay > wbc.maxDelay {
+		delay = wbc.maxDelay
+	}
+	return delay, keepPolling
+}
+
+// Stops polling (& vanishes) once maxPolls polls were made.
+type givingUpAfter struct {
+	PollingStrategy
+	maxPolls int
+}
+
+func (gua givingUpAfter) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := gua.PollingStrategy.NextPoll(bc, pollsSoFar)
+	return delay, keepPolling && pollsSoFar < gua.maxPolls
+}
+
+// Between polls, repeatedly goes inactive with probability skipProbability for up to maxSleep at a time.
+type withInactivity struct {
+	PollingStrategy
+	skipProbability float64
+	maxSleep        time.Duration
+}
+
+func (wi withInactivity) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := wi.PollingStrategy.NextPoll(bc, pollsSoFar)
+	if pollsSoFar == 0 {
+		return delay, keepPolling
+	}
+	for rand.Float64() < wi.skipProbability {
+		labelTag := fmt.Sprintf("client_label:%s", bc.Label())
+		bc.Metrics.Incr("server.checkout", []string{"operation:temp_exit", labelTag})
+		delay += time.Duration(rand.Int63n(int64(wi.maxSleep)))
+	}
+	return delay, keepPolling
+}
+
+// Polls in bursts of burstSize polls burstDelay apart, deferring to the wrapped strategy between bursts.
+type inGreedyBursts struct {
+	PollingStrategy
+	burstSize  int
+	burstDelay time.Duration
+}
+
+func (igb inGreedyBursts) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delay, keepPolling := igb.PollingStrategy.NextPoll(bc, pollsSoFar)
+	if pollsSoFar%igb.burstSize != 0 {
+		return igb.burstDelay, keepPolling
+	}
+	return delay, keepPolling
+}
+
+// Decorates inner the way every client's polling is by default: obeying poll-after advice (if it does) & jittered.
+func withClientDefaults(inner PollingStrategy, obeysPollAfter bool) PollingStrategy {
+	if obeysPollAfter {
+		inner = obeyingPollAfter{inner}
+	}
+	return withJitter{inner}
+}
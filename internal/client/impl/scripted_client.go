@@ -0,0 +1,81 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client/script"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// Variables scripted clients' expressions may reference.
+var scriptVariables = []string{
+	"elapsed_wait_ms",
+	"advised_poll_after_ms",
+	"has_advised_poll_after",
+	"poll_count",
+	"queued",
+	"queue_position",
+	"eta_ms",
+	"default_poll_interval_ms",
+	"num_reloads",
+}
+
+// ScriptedClient polls on a schedule computed by a script expression & may abandon when another one holds.
+type ScriptedClient struct {
+	*BaseClient
+	NextPollDelay *script.Expr
+	ExitWhen      *script.Expr // Optional.
+}
+
+// Polls after however many milliseconds the script evaluates to (the default poll interval if it yields no usable value).
+type scriptedPolling struct {
+	nextPollDelay *script.Expr
+}
+
+func (sp scriptedPolling) NextPoll(bc *BaseClient, pollsSoFar int) (time.Duration, bool) {
+	delayMs := sp.nextPollDelay.Eval(scriptEnv(bc))
+	if math.IsNaN(delayMs) || math.IsInf(delayMs, 0) {
+		return bc.DefaultPollInterval, true
+	}
+	return time.Duration(math.Max(0, delayMs)) * time.Millisecond, true
+}
+
+func scriptEnv(bc *BaseClient) script.Env {
+	env := script.Env{
+		"poll_count":               float64(bc.pollsSoFar),
+		"queued":                   0,
+		"queue_position":           float64(bc.QueuePosition()),
+		"eta_ms":                   -1,
+		"default_poll_interval_ms": float64(bc.DefaultPollInterval.Milliseconds()),
+		"num_reloads":              float64(bc.NumReloads()),
+	}
+	if bc.IsQueued() {
+		env["queued"] = 1
+		env["elapsed_wait_ms"] = float64(bc.clock.now().Sub(bc.QueueEntryTime()).Milliseconds())
+	}
+	if advised := bc.AdvisedPollAfter(); !advised.IsZero() {
+		env["has_advised_poll_after"] = 1
+		env["advised_poll_after_ms"] = math.Max(0, float64(bc.clock.until(advised).Milliseconds()))
+	}
+	if eta := bc.EstimatedAdmissionTime(); !eta.IsZero() {
+		env["eta_ms"] = math.Max(0, float64(bc.clock.until(eta).Milliseconds()))
+	}
+	return env
+}
+
+func (sc *ScriptedClient) HandleResponse(resp *network_mock.MockResponse) error {
+	if !sc.isLocked {
+		return errors.New("lock required to handle server response")
+	}
+	sc.delegateResponseTo(sc, resp)
+	if sc.ExitWhen != nil && sc.IsQueued() && sc.ExitWhen.IsTrue(scriptEnv(sc.BaseClient)) {
+		_ = sc.MarkAbandoned()
+		sc.StopPolling()
+		labelTag := fmt.Sprintf("client_label:%s", sc.Label())
+		sc.Metrics.Incr("server.checkout", []string{"operation:script_exit", labelTag})
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package script implements the small expression language scripted clients define their behavior with.
+//
+// Expressions evaluate to float64 (booleans being 1 or 0) and support:
+// -> arithmetic: + - * / % ^ (power) & unary minus
+// -> comparisons: < <= > >= == != & logic: && || ! (short-circuiting)
+// -> conditionals: cond ? a : b
+// -> functions: min, max, abs, floor, ceil, rand() (uniform in [0, 1)), exp_rand(mean), norm_rand(mean, stddev)
+// -> variables: only those declared when compiling (unknown ones are compile errors)
+package script
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Env maps variable names to their current values.
+type Env map[string]float64
+
+type evalFunc func(env Env) float64
+
+// Expr is a compiled expression.
+type Expr struct {
+	Source string
+	eval   evalFunc
+}
+
+func (e *Expr) Eval(env Env) float64 {
+	return e.eval(env)
+}
+
+// Whether the expression evaluates to a non-zero value.
+func (e *Expr) IsTrue(env Env) bool {
+	return e.eval(env) != 0
+}
+
+type function struct {
+	arity int
+	call  func(args []float64) float64
+}
+
+var functions = map[string]function{
+	"min":       {2, func(args []float64) float64 { return math.Min(args[0], args[1]) }},
+	"max":       {2, func(args []float64) float64 { return math.Max(args[0], args[1]) }},
+	"abs":       {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"floor":     {1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"ceil":      {1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"rand":      {0, func(args []float64) float64 { return rand.Float64() }},
+	"exp_rand":  {1, func(args []float64) float64 { return rand.ExpFloat64() * args[0] }},
+	"norm_rand": {2, func(args []float64) float64 { return args[0] + rand.NormFloat64()*args[1] }},
+}
+
+// Compile parses src, which may only reference the given variables.
+func Compile(src string, variables []string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("script '%s': %s", src, err.Error())
+	}
+	declared := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		declared[v] = true
+	}
+	p := &parser{tokens: tokens, variables: declared}
+	eval, err := p.parseExpr()
+	if err == nil && p.peek().kind != endToken {
+		err = fmt.Errorf("unexpected '%s' at %d", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("script '%s': %s", src, err.Error())
+	}
+	return &Expr{Source: src, eval: eval}, nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package script
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var testVariables = []string{"x", "y", "queue_position"}
+
+func TestEval(t *testing.T) {
+	env := Env{"x": 3, "y": -2, "queue_position": 120}
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"42", 42},
+		{"0.5", 0.5},
+		{".25", 0.25},
+		{"x", 3},
+		{"queue_position / 60", 2},
+		{"  x  +  y  ", 1},
+
+		// Precedence & associativity.
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"7 % 4 * 2", 6},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"2 ^ -1", 0.5},
+		{"--x", 3},
+		{"2 * x ^ 2", 18},
+		{"1 + 2 < 4", 1},
+		{"x > 1 && y > 1 || x == 3", 1},
+		{"x > 1 || y > 1 && x != 3", 1},
+		{"!x", 0},
+		{"!(x < 0)", 1},
+		{"!x == 0", 1},
+
+		// Conditionals nest to the right.
+		{"x > 0 ? 1 : 2", 1},
+		{"y > 0 ? 1 : y < -5 ? 2 : 3", 3},
+		{"(x > 0 ? 10 : 20) + 1", 11},
+
+		// Comparisons & logic yield 1 or 0.
+		{"x <= 3", 1},
+		{"x >= 4", 0},
+		{"x < 3", 0},
+		{"2 && 3", 1},
+		{"0 || 0", 0},
+
+		// Functions.
+		{"min(x, y)", -2},
+		{"max(x, y)", 3},
+		{"abs(y)", 2},
+		{"floor(2.7)", 2},
+		{"ceil(2.2)", 3},
+		{"max(min(x, 10), abs(y) * 4)", 8},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, testVariables)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got := expr.Eval(env); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestUndefinedVariablesEvaluateToZero(t *testing.T) {
+	expr, err := Compile("x + 1", testVariables)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := expr.Eval(Env{}); got != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+}
+
+func TestIsTrue(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"1", true},
+		{"0", false},
+		{"-0.5", true},
+		{"x > 2", true},
+		{"x > 5", false},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, testVariables)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got := expr.IsTrue(Env{"x": 3}); got != tt.want {
+			t.Errorf("%q: got %t, want %t", tt.src, got, tt.want)
+		}
+	}
+}
+
+// Logic operators must not evaluate their rhs once the lhs decides the result (e.g. to skip rand() calls).
+func TestShortCircuit(t *testing.T) {
+	rand.Seed(1)
+	for _, src := range []string{"0 && rand()", "1 || rand()", "1 ? 2 : rand()"} {
+		expr, err := Compile(src, nil)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", src, err)
+		}
+		expr.Eval(Env{})
+	}
+	first := rand.Float64()
+	rand.Seed(1)
+	if want := rand.Float64(); first != want {
+		t.Errorf("rhs was evaluated (random stream advanced)")
+	}
+}
+
+func TestRandomFunctions(t *testing.T) {
+	rand.Seed(1)
+	tests := []struct {
+		src      string
+		min, max float64
+	}{
+		{"rand()", 0, 1},
+		{"exp_rand(0)", 0, 0},
+		{"norm_rand(5, 0)", 5, 5},
+		{"exp_rand(2)", 0, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, nil)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if got := expr.Eval(Env{}); got < tt.min || got > tt.max {
+				t.Errorf("%q: got %v, want within [%v, %v]", tt.src, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{"z + 1", "script 'z + 1': unknown variable 'z' at 0"},
+		{"x + queue_pos", "script 'x + queue_pos': unknown variable 'queue_pos' at 4"},
+		{"sqrt(x)", "script 'sqrt(x)': unknown function 'sqrt' at 0"},
+		{"min(x)", "script 'min(x)': function 'min' takes 2 arguments, got 1"},
+		{"rand(1)", "script 'rand(1)': function 'rand' takes 0 arguments, got 1"},
+		{"abs()", "script 'abs()': function 'abs' takes 1 arguments, got 0"},
+		{"max(x, y", "script 'max(x, y': expected ')' at 8"},
+		{"(x + 1", "script '(x + 1': expected ')' at 6"},
+		{"x ? 1", "script 'x ? 1': expected ':' at 5"},
+		{"x +", "script 'x +': unexpected '' at 3"},
+		{"", "script '': unexpected '' at 0"},
+		{"x y", "script 'x y': unexpected 'y' at 2"},
+		{"1 < x < 2", "script '1 < x < 2': unexpected '<' at 6"},
+		{"x )", "script 'x )': unexpected ')' at 2"},
+		{"* 2", "script '* 2': unexpected '*' at 0"},
+		{"x = 1", "script 'x = 1': unexpected character '=' at 2"},
+		{"x & y", "script 'x & y': unexpected character '&' at 2"},
+		{"1.2.3", "script '1.2.3': invalid number '1.2.3' at 0"},
+		{"x $ 2", "script 'x $ 2': unexpected character '$' at 2"},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, testVariables)
+		if err == nil {
+			t.Errorf("%q: got %v, want error %q", tt.src, expr.Eval(Env{}), tt.wantErr)
+			continue
+		}
+		if err.Error() != tt.wantErr {
+			t.Errorf("%q: got error %q, want %q", tt.src, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize("a<=-1.5&&!b_2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []token{
+		{kind: identToken, text: "a", pos: 0},
+		{kind: operatorToken, text: "<=", pos: 1},
+		{kind: operatorToken, text: "-", pos: 3},
+		{kind: numberToken, text: "1.5", value: 1.5, pos: 4},
+		{kind: operatorToken, text: "&&", pos: 7},
+		{kind: operatorToken, text: "!", pos: 9},
+		{kind: identToken, text: "b_2", pos: 10},
+		{kind: endToken, pos: 13},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(tokens), tokens, len(want))
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, tokens[i], want[i])
+		}
+	}
+}
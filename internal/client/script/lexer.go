@@ -0,0 +1,70 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	identToken
+	operatorToken
+	endToken
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+// Longest operators first so that e.g. "<=" is not lexed as "<" followed by "=".
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "+", "-", "*", "/", "%", "^", "<", ">", "!", "?", ":", "(", ")", ","}
+
+func tokenize(src string) ([]token, error) {
+	tokens := make([]token, 0)
+	for pos := 0; pos < len(src); {
+		r := rune(src[pos])
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case unicode.IsDigit(r) || r == '.':
+			end := pos
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			value, err := strconv.ParseFloat(src[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s' at %d", src[pos:end], pos)
+			}
+			tokens = append(tokens, token{kind: numberToken, text: src[pos:end], value: value, pos: pos})
+			pos = end
+		case unicode.IsLetter(r) || r == '_':
+			end := pos
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{kind: identToken, text: src[pos:end], pos: pos})
+			pos = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[pos:], op) {
+					tokens = append(tokens, token{kind: operatorToken, text: op, pos: pos})
+					pos += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character '%c' at %d", r, pos)
+			}
+		}
+	}
+	return append(tokens, token{kind: endToken, pos: len(src)}), nil
+}
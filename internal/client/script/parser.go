@@ -0,0 +1,277 @@
+package script
+
+import (
+	"fmt"
+	"math"
+)
+
+// Recursive descent parser compiling straight to closures, from lowest to highest precedence:
+// ternary, ||, &&, comparisons, + -, * / %, unary - !, ^ (right associative), primaries.
+type parser struct {
+	tokens    []token
+	pos       int
+	variables map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) acceptOperator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != operatorToken {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectOperator(op string) error {
+	if _, ok := p.acceptOperator(op); !ok {
+		return fmt.Errorf("expected '%s' at %d", op, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) parseExpr() (evalFunc, error) {
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.acceptOperator("?"); !ok {
+		return cond, nil
+	}
+	ifTrue, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOperator(":"); err != nil {
+		return nil, err
+	}
+	ifFalse, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return func(env Env) float64 {
+		if cond(env) != 0 {
+			return ifTrue(env)
+		}
+		return ifFalse(env)
+	}, nil
+}
+
+func (p *parser) parseOr() (evalFunc, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("||"); !ok {
+			return lhs, nil
+		}
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(env Env) float64 { return boolToFloat(l(env) != 0 || rhs(env) != 0) }
+	}
+}
+
+func (p *parser) parseAnd() (evalFunc, error) {
+	lhs, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("&&"); !ok {
+			return lhs, nil
+		}
+		rhs, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(env Env) float64 { return boolToFloat(l(env) != 0 && rhs(env) != 0) }
+	}
+}
+
+func (p *parser) parseComparison() (evalFunc, error) {
+	lhs, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOperator("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return lhs, nil
+	}
+	rhs, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	compare := map[string]func(a, b float64) bool{
+		"<":  func(a, b float64) bool { return a < b },
+		"<=": func(a, b float64) bool { return a <= b },
+		">":  func(a, b float64) bool { return a > b },
+		">=": func(a, b float64) bool { return a >= b },
+		"==": func(a, b float64) bool { return a == b },
+		"!=": func(a, b float64) bool { return a != b },
+	}[op]
+	return func(env Env) float64 { return boolToFloat(compare(lhs(env), rhs(env))) }, nil
+}
+
+func (p *parser) parseAdditive() (evalFunc, error) {
+	lhs, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator("+", "-")
+		if !ok {
+			return lhs, nil
+		}
+		rhs, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		if op == "+" {
+			lhs = func(env Env) float64 { return l(env) + rhs(env) }
+		} else {
+			lhs = func(env Env) float64 { return l(env) - rhs(env) }
+		}
+	}
+}
+
+func (p *parser) parseMultiplicative() (evalFunc, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator("*", "/", "%")
+		if !ok {
+			return lhs, nil
+		}
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		switch op {
+		case "*":
+			lhs = func(env Env) float64 { return l(env) * rhs(env) }
+		case "/":
+			lhs = func(env Env) float64 { return l(env) / rhs(env) }
+		case "%":
+			lhs = func(env Env) float64 { return math.Mod(l(env), rhs(env)) }
+		}
+	}
+}
+
+func (p *parser) parseUnary() (evalFunc, error) {
+	op, ok := p.acceptOperator("-", "!")
+	if !ok {
+		return p.parsePower()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if op == "-" {
+		return func(env Env) float64 { return -operand(env) }, nil
+	}
+	return func(env Env) float64 { return boolToFloat(operand(env) == 0) }, nil
+}
+
+func (p *parser) parsePower() (evalFunc, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.acceptOperator("^"); !ok {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return func(env Env) float64 { return math.Pow(base(env), exponent(env)) }, nil
+}
+
+func (p *parser) parsePrimary() (evalFunc, error) {
+	t := p.next()
+	switch t.kind {
+	case numberToken:
+		value := t.value
+		return func(env Env) float64 { return value }, nil
+	case identToken:
+		if _, ok := p.acceptOperator("("); ok {
+			return p.parseCall(t)
+		}
+		if !p.variables[t.text] {
+			return nil, fmt.Errorf("unknown variable '%s' at %d", t.text, t.pos)
+		}
+		name := t.text
+		return func(env Env) float64 { return env[name] }, nil
+	case operatorToken:
+		if t.text == "(" {
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOperator(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+}
+
+// Parses the arguments of a call whose opening parenthesis was consumed.
+func (p *parser) parseCall(name token) (evalFunc, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at %d", name.text, name.pos)
+	}
+	args := make([]evalFunc, 0, fn.arity)
+	if _, ok := p.acceptOperator(")"); !ok {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOperator(","); !ok {
+				break
+			}
+		}
+		if err := p.expectOperator(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("function '%s' takes %d arguments, got %d", name.text, fn.arity, len(args))
+	}
+	return func(env Env) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(env)
+		}
+		return fn.call(values)
+	}, nil
+}
@@ -32,3 +32,13 @@ const (
 func (s ThrottleState) String() string {
 	return [...]string{"initial", "queued", "inCheckout", "exited"}[s]
 }
+
+// Inverse of String (ok is false for unknown states).
+func ParseThrottleState(s string) (state ThrottleState, ok bool) {
+	for state := Initial; state <= Exited; state++ {
+		if state.String() == s {
+			return state, true
+		}
+	}
+	return Initial, false
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// Upper bounds of the time-in-queue buckets abandonment is reported by (the last bucket is unbounded).
+var WaitBucketBounds = []time.Duration{
+	10 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+	120 * time.Second,
+	300 * time.Second,
+}
+
+// Index of the time-in-queue bucket containing wait (in [0, len(WaitBucketBounds)]).
+func WaitBucketIdx(wait time.Duration) int {
+	for i, bound := range WaitBucketBounds {
+		if wait < bound {
+			return i
+		}
+	}
+	return len(WaitBucketBounds)
+}
+
+// Human readable name of bucket idx (e.g. "10s-30s" or "300s+").
+func WaitBucketName(idx int) string {
+	lower := time.Duration(0)
+	if idx > 0 {
+		lower = WaitBucketBounds[idx-1]
+	}
+	if idx >= len(WaitBucketBounds) {
+		return fmt.Sprintf("%.0fs+", lower.Seconds())
+	}
+	return fmt.Sprintf("%.0fs-%.0fs", lower.Seconds(), WaitBucketBounds[idx].Seconds())
+}
@@ -0,0 +1,123 @@
+package cost
+
+import (
+	"sort"
+	"sync"
+)
+
+// Kinds of work charged to a ledger (each priced by the cost Model).
+const (
+	CheckoutEndpoint = "endpoint.checkout"
+	PollEndpoint     = "endpoint.poll"
+	QueueOperation   = "queue"
+	TrackerOperation = "tracker"
+	RedisCommand     = "redis"
+	RedisEvalSha     = "redis.evalsha"
+)
+
+// Cost of serving some work, in notional server CPU time & calls made to Redis.
+type Cost struct {
+	CpuMs      float64
+	RedisCalls int64
+}
+
+func (c Cost) Plus(other Cost) Cost {
+	return Cost{CpuMs: c.CpuMs + other.CpuMs, RedisCalls: c.RedisCalls + other.RedisCalls}
+}
+
+// Model prices each kind of work (kinds left out are free).
+type Model map[string]Cost
+
+// Entry totals the charges of one operation, e.g. the "zrank" commands of kind RedisCommand.
+type Entry struct {
+	Kind      string
+	Operation string
+	Count     int64
+	Cost      Cost
+}
+
+// Ledger accumulates the cost of the work done for one shop. A nil ledger charges nothing.
+type Ledger struct {
+	model Model
+
+	mutex   sync.Mutex
+	entries map[string]*Entry
+}
+
+func MakeLedger(model Model) *Ledger {
+	return &Ledger{model: model, entries: make(map[string]*Entry)}
+}
+
+func (l *Ledger) Charge(kind, operation string) {
+	if l == nil {
+		return
+	}
+	key := kind + ":" + operation
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entry, found := l.entries[key]
+	if !found {
+		entry = &Entry{Kind: kind, Operation: operation}
+		l.entries[key] = entry
+	}
+	entry.Count++
+	entry.Cost = entry.Cost.Plus(l.model[kind])
+}
+
+// Adds entries charged elsewhere (e.g. before a checkpoint) to those of the ledger.
+func (l *Ledger) AddEntries(entries []Entry) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, added := range entries {
+		key := added.Kind + ":" + added.Operation
+		entry, found := l.entries[key]
+		if !found {
+			entry = &Entry{Kind: added.Kind, Operation: added.Operation}
+			l.entries[key] = entry
+		}
+		entry.Count += added.Count
+		entry.Cost = entry.Cost.Plus(added.Cost)
+	}
+}
+
+// Entries charged so far, sorted by kind & operation.
+func (l *Ledger) Entries() []Entry {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entries := make([]Entry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Operation < entries[j].Operation
+	})
+	return entries
+}
+
+func (l *Ledger) Total() Cost {
+	total := Cost{}
+	for _, entry := range l.Entries() {
+		total = total.Plus(entry.Cost)
+	}
+	return total
+}
+
+// Number of charges of the given kind (whatever their operation).
+func (l *Ledger) Count(kind string) int64 {
+	count := int64(0)
+	for _, entry := range l.Entries() {
+		if entry.Kind == kind {
+			count += entry.Count
+		}
+	}
+	return count
+}
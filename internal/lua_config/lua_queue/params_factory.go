@@ -178,7 +178,7 @@ func MakeOptNotimersPollDrivenParams(
 		LuaMethodToShaMap:                     luaMethodToShaMap,
 		KeysBuilder:                           &param_builders.OptPollDrivenNotimersLuaKeysBuilder{},
 		ArgsBuilder:                           &param_builders.OptPollDrivenNotimersLuaArgsBuilder{},
-		Postprocessor:                         &postprocessors.MinimalistPostprocessor{cps},
+		Postprocessor:                         &postprocessors.MinimalistPostprocessor{MaxCps: cps},
 	}
 	return queueParams
 }
@@ -226,7 +226,7 @@ func MakeOptKTimersPollDrivenParams(
 		LuaMethodToShaMap:                     luaMethodToShaMap,
 		KeysBuilder:                           &param_builders.OptPollDrivenKTimersLuaKeysBuilder{},
 		ArgsBuilder:                           &param_builders.OptPollDrivenKTimersLuaArgsBuilder{},
-		Postprocessor:                         &postprocessors.MinimalistPostprocessor{cps},
+		Postprocessor:                         &postprocessors.MinimalistPostprocessor{MaxCps: cps},
 	}
 	return queueParams
 }
@@ -315,7 +315,7 @@ func MakeOptPollDrivenNobinsParams(
 		LuaMethodToShaMap:                     luaMethodToShaMap,
 		KeysBuilder:                           &param_builders.OptPollDrivenNobinsLuaKeysBuilder{},
 		ArgsBuilder:                           argsBuilder ,
-		Postprocessor:                         &postprocessors.NobinsPostprocessor{cps},
+		Postprocessor:                         &postprocessors.NobinsPostprocessor{MaxCps: cps},
 	}
 	return queueParams
 }
@@ -31,6 +31,7 @@ func (p *MinimalistPostprocessor) PostprocessLuaResult(
 		c.SetThrottleCookieVal(redis_queue.LuaUserBinKey, strconv.FormatInt(binIdx, 10))
 		c.SetThrottleCookieVal(redis_queue.LuaUserPosKey, strconv.FormatInt(totalClients, 10))
 		c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+		c.AdviseQueuePosition(totalClients-1, time.Now().Add(remDurToPoll))
 
 		log.Debug().Msg(fmt.Sprintf(resultTuple[1].(string)))
 		return redis_queue.PostprocessResult{}
@@ -29,6 +29,7 @@ func (p *NobinsPostprocessor) PostprocessLuaResult(
 
 		c.SetThrottleCookieVal(redis_queue.LuaUserPosKey, strconv.FormatInt(totalClients, 10))
 		c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+		c.AdviseQueuePosition(totalClients-1, time.Now().Add(remDurToPoll))
 
 		log.Debug().Msg(fmt.Sprintf(resultTuple[1].(string)))
 		return redis_queue.PostprocessResult{}
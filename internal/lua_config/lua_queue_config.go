@@ -58,6 +58,8 @@ func ConfigureRedisLua(
 	if err != nil {
 		panic(err)
 	}
+	// Client-scoped keys are namespaced under the same shop as the shop-scoped keys.
+	queueParams.ShopScopePrefix = constants.ShopScopePrefix
 	return queueParams
 }
 
@@ -9,63 +9,102 @@ import (
 )
 
 const (
-	defaultStatsdAddr = "127.0.0.1:8125" // TODO: Move this into cli flag.
+	DefaultStatsdAddr = "127.0.0.1:8125"
 	statsdNamespace   = "checkout_queue_simulator."
 	statsdScope       = "default"
 )
 
-var Client statsd.ClientInterface
-var runtimeGlobalTags = make([]string, 0)
+// Sink receives every metric recorded (statsd.ClientInterface is one).
+type Sink interface {
+	Count(name string, value int64, tags []string, rate float64) error
+	Decr(name string, tags []string, rate float64) error
+	Distribution(name string, value float64, tags []string, rate float64) error
+	Gauge(name string, value float64, tags []string, rate float64) error
+	Histogram(name string, value float64, tags []string, rate float64) error
+	Incr(name string, tags []string, rate float64) error
+	Flush() error
+}
 
-func init() {
-	c, err := statsd.New(defaultStatsdAddr)
+// Sink sending metrics to the datadog agent at addr (metrics noop if it cannot be reached).
+func MakeStatsdSink(addr string) Sink {
+	c, err := statsd.New(addr)
 	if err != nil {
-		Client = &statsd.NoOpClient{}
 		log.Info().Msg("failed connecting to datadog agent => metrics will noop")
-	} else {
-		Client = c
-		c.Namespace = statsdNamespace
-		c.Tags = []string{fmt.Sprintf("scope:%s", statsdScope)}
-		log.Info().Msg("successfully connected to datadog agent")
+		return &statsd.NoOpClient{}
 	}
+	c.Namespace = statsdNamespace
+	c.Tags = []string{fmt.Sprintf("scope:%s", statsdScope)}
+	log.Info().Msg("successfully connected to datadog agent")
+	return c
+}
+
+// Recorder hands the metrics of one simulation to its sink, tagged with the simulation's global tags.
+// A nil recorder records nothing.
+type Recorder struct {
+	sink       Sink
+	globalTags []string
 }
 
-func AddGlobalTags(tags []string) {
-	runtimeGlobalTags = append(runtimeGlobalTags, tags...)
+func MakeRecorder(sink Sink, globalTags []string) *Recorder {
+	return &Recorder{sink: sink, globalTags: append([]string(nil), globalTags...)}
 }
 
-func Count(name string, value int64, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Count(name, value, tags, 1.0 /* rate */)
+func (r *Recorder) tagged(tags []string) []string {
+	return append(append(make([]string, 0, len(r.globalTags)+len(tags)), r.globalTags...), tags...)
 }
 
-func Decr(name string, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Decr(name, tags, 1.0 /* rate */)
+func (r *Recorder) Count(name string, value int64, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Count(name, value, r.tagged(tags), 1.0 /* rate */)
+}
+
+func (r *Recorder) Decr(name string, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Decr(name, r.tagged(tags), 1.0 /* rate */)
 }
 
-func Distribution(name string, value float64, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Distribution(name, value, tags, 1.0 /* rate */)
+func (r *Recorder) Distribution(name string, value float64, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Distribution(name, value, r.tagged(tags), 1.0 /* rate */)
 }
 
-func Gauge(name string, value float64, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Gauge(name, value, tags, 1.0 /* rate */)
+func (r *Recorder) Gauge(name string, value float64, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Gauge(name, value, r.tagged(tags), 1.0 /* rate */)
 }
 
-func Histogram(name string, value float64, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Histogram(name, value, tags, 1.0 /* rate */)
+func (r *Recorder) Histogram(name string, value float64, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Histogram(name, value, r.tagged(tags), 1.0 /* rate */)
 }
 
-func Incr(name string, tags []string) error {
-	tags = append(runtimeGlobalTags, tags...)
-	return Client.Incr(name, tags, 1.0 /* rate */)
+func (r *Recorder) Incr(name string, tags []string) error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Incr(name, r.tagged(tags), 1.0 /* rate */)
 }
 
-func BenchmarkMethod(startTime time.Time, methodName string, tags []string) {
+func (r *Recorder) BenchmarkMethod(startTime time.Time, methodName string, tags []string) {
 	elapsed := time.Since(startTime)
 	metricName := fmt.Sprintf("%s.elapsed_ns", methodName)
-	Distribution(metricName, float64(elapsed.Nanoseconds()), tags)
+	r.Distribution(metricName, float64(elapsed.Nanoseconds()), tags)
+}
+
+// Sends the metrics buffered so far.
+func (r *Recorder) Flush() error {
+	if r == nil {
+		return nil
+	}
+	return r.sink.Flush()
 }
@@ -0,0 +1,179 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// HTTP mapping of mock requests & responses:
+// -> POST /shops/<shop_id>/checkout & POST /shops/<shop_id>/poll
+// -> the client's session id & throttle cookie travel as cookies (the latter url-encoded, e.g. "ThrottleState=queued")
+// -> responses set the throttle cookie & advise when to poll next via Retry-After (seconds) & X-Poll-After (RFC 3339)
+// -> the response body holds the session's throttle state, queue position & estimated admission time as json
+const (
+	SessionCookieName  = "_checkout_session"
+	ThrottleCookieName = "_checkout_throttle"
+	PollAfterHeader    = "X-Poll-After"
+	RetryAfterHeader   = "Retry-After"
+)
+
+type httpSessionBody struct {
+	ClientId               int       `json:"client_id"`
+	ShopId                 int       `json:"shop_id"`
+	ThrottleState          string    `json:"throttle_state"`
+	QueueEntryTime         time.Time `json:"queue_entry_time"`
+	QueuePosition          int64     `json:"queue_position"`
+	EstimatedAdmissionTime time.Time `json:"estimated_admission_time"`
+}
+
+func EndpointPath(req *network_mock.MockRequest) string {
+	return fmt.Sprintf("/shops/%d/%s", req.ClientData.ShopId, EndpointName(req))
+}
+
+// Encodes req as an HTTP request against the server at baseURL (e.g. "http://localhost:8089").
+func NewHTTPRequest(ctx context.Context, baseURL string, req *network_mock.MockRequest) (*http.Request, error) {
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+EndpointPath(req), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.AddCookie(&http.Cookie{Name: SessionCookieName, Value: strconv.Itoa(req.ClientData.Id)})
+	if len(req.ClientData.ThrottleCookie) > 0 {
+		httpReq.AddCookie(&http.Cookie{Name: ThrottleCookieName, Value: encodeThrottleCookie(req.ClientData.ThrottleCookie)})
+	}
+	return httpReq, nil
+}
+
+// Decodes the mock request sent as r (the inverse of NewHTTPRequest).
+func ReadHTTPRequest(r *http.Request) (*network_mock.MockRequest, error) {
+	if r.Method != http.MethodPost {
+		return nil, fmt.Errorf("method must be POST, got %s", r.Method)
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "shops" {
+		return nil, fmt.Errorf("path must be /shops/<shop_id>/{checkout, poll}, got %s", r.URL.Path)
+	}
+	shopId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid shop id '%s'", parts[1])
+	}
+	sessionCookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s cookie", SessionCookieName)
+	}
+	clientId, err := strconv.Atoi(sessionCookie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session id '%s'", sessionCookie.Value)
+	}
+	session := network_mock.SessionData{Id: clientId, ShopId: shopId, ThrottleCookie: make(map[string]string)}
+	if throttleCookie, err := r.Cookie(ThrottleCookieName); err == nil {
+		session.ThrottleCookie, err = decodeThrottleCookie(throttleCookie.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s cookie: %s", ThrottleCookieName, err.Error())
+		}
+	}
+	switch parts[2] {
+	case "checkout":
+		return network_mock.MakeCheckoutRequest(session), nil
+	case "poll":
+		return network_mock.MakePollRequest(session), nil
+	default:
+		return nil, fmt.Errorf("endpoint must be one of: {checkout, poll}, got %s", parts[2])
+	}
+}
+
+func WriteHTTPResponse(w http.ResponseWriter, resp *network_mock.MockResponse) error {
+	session := resp.ClientData
+	http.SetCookie(w, &http.Cookie{
+		Name:     ThrottleCookieName,
+		Value:    encodeThrottleCookie(session.ThrottleCookie),
+		Path:     "/",
+		HttpOnly: true,
+	})
+	if !session.AdvisedPollAfter.IsZero() {
+		w.Header().Set(PollAfterHeader, session.AdvisedPollAfter.Format(time.RFC3339Nano))
+		retryAfterSecs := math.Max(0, math.Ceil(time.Until(session.AdvisedPollAfter).Seconds()))
+		w.Header().Set(RetryAfterHeader, strconv.Itoa(int(retryAfterSecs)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(httpSessionBody{
+		ClientId:               session.Id,
+		ShopId:                 session.ShopId,
+		ThrottleState:          session.ThrottleState,
+		QueueEntryTime:         session.QueueEntryTime,
+		QueuePosition:          session.QueuePosition,
+		EstimatedAdmissionTime: session.EstimatedAdmissionTime,
+	})
+}
+
+// Decodes the mock response sent as r (the inverse of WriteHTTPResponse).
+func ReadHTTPResponse(r *http.Response) (*network_mock.MockResponse, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", r.Status)
+	}
+	var body httpSessionBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid response body: %s", err.Error())
+	}
+	session := network_mock.SessionData{
+		Id:                     body.ClientId,
+		ShopId:                 body.ShopId,
+		ThrottleState:          body.ThrottleState,
+		QueueEntryTime:         body.QueueEntryTime,
+		QueuePosition:          body.QueuePosition,
+		EstimatedAdmissionTime: body.EstimatedAdmissionTime,
+		AdvisedPollAfter:       readPollAfter(r.Header),
+		ThrottleCookie:         make(map[string]string),
+	}
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != ThrottleCookieName {
+			continue
+		}
+		throttleCookie, err := decodeThrottleCookie(cookie.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s cookie: %s", ThrottleCookieName, err.Error())
+		}
+		session.ThrottleCookie = throttleCookie
+	}
+	return network_mock.MakeServerResponse(session), nil
+}
+
+// Absolute poll-after advice if given, else derived from the (coarser) Retry-After delay.
+func readPollAfter(header http.Header) time.Time {
+	if pollAfter, err := time.Parse(time.RFC3339Nano, header.Get(PollAfterHeader)); err == nil {
+		return pollAfter
+	}
+	if retryAfterSecs, err := strconv.Atoi(header.Get(RetryAfterHeader)); err == nil {
+		return time.Now().Add(time.Duration(retryAfterSecs) * time.Second)
+	}
+	return time.Time{}
+}
+
+func encodeThrottleCookie(throttleCookie map[string]string) string {
+	values := make(url.Values, len(throttleCookie))
+	for k, v := range throttleCookie {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+func decodeThrottleCookie(value string) (map[string]string, error) {
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, err
+	}
+	throttleCookie := make(map[string]string, len(values))
+	for k := range values {
+		throttleCookie[k] = values.Get(k)
+	}
+	return throttleCookie, nil
+}
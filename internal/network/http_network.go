@@ -0,0 +1,109 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPNetwork sends requests as real HTTP requests to the server at baseURL & hands the decoded responses to
+// clients, so that serialization & connection overhead are paid for real.
+type HTTPNetwork struct {
+	ctx              context.Context
+	baseURL          string
+	httpClient       *http.Client
+	responseChannels map[int]chan *network_mock.MockResponse
+	metrics          *metrics.Recorder
+}
+
+func MakeHTTPNetwork(
+	ctx context.Context,
+	baseURL string,
+	maxConnections int,
+	requestTimeout time.Duration,
+	responseChannels map[int]chan *network_mock.MockResponse,
+	metrics *metrics.Recorder,
+) *HTTPNetwork {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxConnections
+	transport.MaxIdleConnsPerHost = maxConnections
+	return &HTTPNetwork{
+		ctx:              ctx,
+		baseURL:          baseURL,
+		httpClient:       &http.Client{Transport: transport, Timeout: requestTimeout},
+		responseChannels: responseChannels,
+		metrics:          metrics,
+	}
+}
+
+func (n *HTTPNetwork) AttachClient(clientId int, linkProfile string) {}
+
+// Requests failing (e.g. rejected by an overloaded server) are reported through onDropped.
+func (n *HTTPNetwork) SendRequest(
+	req *network_mock.MockRequest,
+	requests chan<- *network_mock.MockRequest,
+	onDropped func(),
+) bool {
+	go func() {
+		resp, err := n.RoundTrip(req)
+		if err != nil {
+			if n.ctx.Err() != nil {
+				return
+			}
+			log.Debug().Int("client_id", req.ClientData.Id).Msg(fmt.Sprintf("HTTP request failed: %s", err.Error()))
+			onDropped()
+			return
+		}
+		n.SendResponse(resp, n.responseChannels[req.ClientData.Id])
+	}()
+	return true
+}
+
+func (n *HTTPNetwork) SendResponse(resp *network_mock.MockResponse, responses chan<- *network_mock.MockResponse) {
+	select {
+	case <-n.ctx.Done():
+	case responses <- resp:
+	}
+}
+
+// Performs req over HTTP, recording its round trip time & whether it reused a connection.
+func (n *HTTPNetwork) RoundTrip(req *network_mock.MockRequest) (*network_mock.MockResponse, error) {
+	httpReq, err := NewHTTPRequest(n.ctx, n.baseURL, req)
+	if err != nil {
+		return nil, err
+	}
+	endpointTag := fmt.Sprintf("endpoint:%s", EndpointName(req))
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			n.metrics.Incr("network.http_connections", []string{fmt.Sprintf("reused:%t", info.Reused), endpointTag})
+		},
+	}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
+	start := time.Now()
+	httpResp, err := n.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	resp, err := ReadHTTPResponse(httpResp)
+	if err == nil {
+		// Generations stay client side (as with the request a browser awaits the response to).
+		resp.ClientData.Generation = req.ClientData.Generation
+	}
+	n.metrics.Distribution("network.http_round_trip_ms", float64(time.Since(start).Milliseconds()), []string{endpointTag})
+	return resp, err
+}
+
+// Name of req's endpoint, as in HTTP paths & metric tags.
+func EndpointName(req *network_mock.MockRequest) string {
+	if req.Endpoint == network_mock.PollingEndpoint {
+		return "poll"
+	}
+	return "checkout"
+}
@@ -0,0 +1,69 @@
+package network
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// LinkProfile describes the network path between a client & the server.
+type LinkProfile struct {
+	Name string
+
+	// One way latencies (in ms) sampled per message, so that messages may overtake one another.
+	UplinkLatencyMs   client.Distribution
+	DownlinkLatencyMs client.Distribution
+
+	// Lost messages never arrive: clients find out by timing out on their request (& retrying it).
+	LossProbability float64
+}
+
+// Built-in link profiles, selected per client by the link_profile field of its config.
+var LinkProfiles = map[string]LinkProfile{
+	"ideal": {
+		Name:              "ideal",
+		UplinkLatencyMs:   client.Distribution{Type: "constant", Value: 0},
+		DownlinkLatencyMs: client.Distribution{Type: "constant", Value: 0},
+	},
+	"broadband": {
+		Name:              "broadband",
+		UplinkLatencyMs:   client.Distribution{Type: "normal", Mean: 15, Stddev: 5},
+		DownlinkLatencyMs: client.Distribution{Type: "normal", Mean: 15, Stddev: 5},
+		LossProbability:   0.001,
+	},
+	"mobile_4g": {
+		Name:              "mobile_4g",
+		UplinkLatencyMs:   client.Distribution{Type: "lognormal", Median: 45, Sigma: 0.4},
+		DownlinkLatencyMs: client.Distribution{Type: "lognormal", Median: 35, Sigma: 0.4},
+		LossProbability:   0.01,
+	},
+	"mobile_3g": {
+		Name:              "mobile_3g",
+		UplinkLatencyMs:   client.Distribution{Type: "lognormal", Median: 200, Sigma: 0.5},
+		DownlinkLatencyMs: client.Distribution{Type: "lognormal", Median: 150, Sigma: 0.5},
+		LossProbability:   0.03,
+	},
+}
+
+// Names of the built-in link profiles, sorted.
+func LinkProfileNames() []string {
+	names := make([]string, 0, len(LinkProfiles))
+	for name := range LinkProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Time for a message to cross the link given its latency distribution.
+func (l LinkProfile) transitDelay(latencyMs client.Distribution) time.Duration {
+	return time.Duration(math.Max(0, latencyMs.Sample()) * float64(time.Millisecond))
+}
+
+// Whether the next message sent over the link gets lost.
+func (l LinkProfile) losesMessage() bool {
+	return rand.Float64() < l.LossProbability
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+const mockTicketKey = "ticket"
+
+// MockQueueServer stands in for an external checkout queue: a first come first served queue per shop admitting
+// AdmissionsPerSecond clients (from the shop's first arrival on) until its inventory runs out. Everything it knows of
+// a client is in the throttle cookie it hands out (the client's ticket number & state).
+type MockQueueServer struct {
+	AdmissionsPerSecond float64
+	PollInterval        time.Duration
+
+	// Inventory of each shop (by id), none for shops missing.
+	Inventories map[int]int
+
+	mutex sync.Mutex
+	shops map[int]*mockShopQueue
+}
+
+type mockShopQueue struct {
+	firstArrival time.Time
+	nextTicket   int
+}
+
+func MakeMockQueueServer(
+	admissionsPerSecond float64,
+	inventories map[int]int,
+	pollInterval time.Duration,
+) *MockQueueServer {
+	return &MockQueueServer{
+		AdmissionsPerSecond: admissionsPerSecond,
+		Inventories:         inventories,
+		PollInterval:        pollInterval,
+		shops:               make(map[int]*mockShopQueue),
+	}
+}
+
+func (s *MockQueueServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := ReadHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	session := req.ClientData
+	s.mutex.Lock()
+	shop, found := s.shops[session.ShopId]
+	if !found {
+		shop = &mockShopQueue{firstArrival: time.Now()}
+		s.shops[session.ShopId] = shop
+	}
+	ticket, err := strconv.Atoi(session.ThrottleCookie[mockTicketKey])
+	if err != nil {
+		// New arrival (or a cookie it cannot make sense of) => back of the line.
+		ticket = shop.nextTicket
+		shop.nextTicket++
+		session.QueueEntryTime = time.Now()
+	}
+	admitted := int(time.Since(shop.firstArrival).Seconds() * s.AdmissionsPerSecond)
+	s.mutex.Unlock()
+
+	switch {
+	case ticket >= s.Inventories[session.ShopId]:
+		session.ThrottleState = client.Exited.String()
+	case ticket < admitted:
+		session.ThrottleState = client.InCheckout.String()
+	default:
+		session.ThrottleState = client.Queued.String()
+		session.QueuePosition = int64(ticket - admitted)
+		waitSecs := float64(ticket-admitted+1) / s.AdmissionsPerSecond
+		session.EstimatedAdmissionTime = time.Now().Add(time.Duration(waitSecs * float64(time.Second)))
+		session.AdvisedPollAfter = time.Now().Add(s.PollInterval)
+	}
+	session.ThrottleCookie = map[string]string{
+		mockTicketKey:           strconv.Itoa(ticket),
+		client.ThrottleStateKey: session.ThrottleState,
+	}
+	WriteHTTPResponse(w, network_mock.MakeServerResponse(session))
+}
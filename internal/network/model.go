@@ -0,0 +1,156 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// Model carries requests from clients to the server workers' backlog & responses back to clients.
+type Model interface {
+	// Connects the client over the named link profile (the model's default if empty).
+	AttachClient(clientId int, linkProfile string)
+
+	// Returns false if req was dropped right away for lack of room in the backlog. Models delivering req later
+	// call onDropped instead if the backlog turns out to be full upon arrival.
+	SendRequest(req *network_mock.MockRequest, requests chan<- *network_mock.MockRequest, onDropped func()) bool
+	SendResponse(resp *network_mock.MockResponse, responses chan<- *network_mock.MockResponse)
+}
+
+// InstantNetwork delivers every message at once & in order.
+type InstantNetwork struct {
+	metrics *metrics.Recorder
+}
+
+func MakeInstantNetwork(metrics *metrics.Recorder) *InstantNetwork {
+	return &InstantNetwork{metrics: metrics}
+}
+
+func (n *InstantNetwork) AttachClient(clientId int, linkProfile string) {}
+
+func (n *InstantNetwork) SendRequest(
+	req *network_mock.MockRequest,
+	requests chan<- *network_mock.MockRequest,
+	onDropped func(),
+) bool {
+	req.ArrivalTime = time.Now()
+	select {
+	case requests <- req:
+		return true
+	default:
+		recordShed(n.metrics, req)
+		return false
+	}
+}
+
+func (n *InstantNetwork) SendResponse(resp *network_mock.MockResponse, responses chan<- *network_mock.MockResponse) {
+	responses <- resp
+}
+
+// SimulatedNetwork delays every message by the latency of its client's link (losing some), so that clients learn
+// of their admission late & responses may arrive out of order.
+type SimulatedNetwork struct {
+	ctx         context.Context
+	defaultLink LinkProfile
+	metrics     *metrics.Recorder
+
+	mutex sync.RWMutex
+	links map[int]LinkProfile
+}
+
+func MakeSimulatedNetwork(ctx context.Context, defaultLink LinkProfile, metrics *metrics.Recorder) *SimulatedNetwork {
+	return &SimulatedNetwork{
+		ctx:         ctx,
+		defaultLink: defaultLink,
+		metrics:     metrics,
+		links:       make(map[int]LinkProfile),
+	}
+}
+
+func (n *SimulatedNetwork) AttachClient(clientId int, linkProfile string) {
+	link := n.defaultLink
+	if linkProfile != "" {
+		profile, found := LinkProfiles[linkProfile]
+		if !found {
+			panic(fmt.Errorf("link profile must be one of: %v", LinkProfileNames()))
+		}
+		link = profile
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.links[clientId] = link
+}
+
+func (n *SimulatedNetwork) link(clientId int) LinkProfile {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if link, found := n.links[clientId]; found {
+		return link
+	}
+	return n.defaultLink
+}
+
+func (n *SimulatedNetwork) SendRequest(
+	req *network_mock.MockRequest,
+	requests chan<- *network_mock.MockRequest,
+	onDropped func(),
+) bool {
+	link := n.link(req.ClientData.Id)
+	if link.losesMessage() {
+		recordLoss(n.metrics, "uplink", link)
+		return true
+	}
+	delay := link.transitDelay(link.UplinkLatencyMs)
+	recordTransit(n.metrics, "uplink", link, delay)
+	time.AfterFunc(delay, func() {
+		select {
+		case <-n.ctx.Done():
+			return
+		default:
+		}
+		req.ArrivalTime = time.Now()
+		select {
+		case requests <- req:
+		default:
+			recordShed(n.metrics, req)
+			onDropped()
+		}
+	})
+	return true
+}
+
+func (n *SimulatedNetwork) SendResponse(resp *network_mock.MockResponse, responses chan<- *network_mock.MockResponse) {
+	link := n.link(resp.ClientData.Id)
+	if link.losesMessage() {
+		recordLoss(n.metrics, "downlink", link)
+		return
+	}
+	delay := link.transitDelay(link.DownlinkLatencyMs)
+	recordTransit(n.metrics, "downlink", link, delay)
+	time.AfterFunc(delay, func() {
+		select {
+		case <-n.ctx.Done():
+		case responses <- resp:
+		}
+	})
+}
+
+func recordTransit(recorder *metrics.Recorder, direction string, link LinkProfile, delay time.Duration) {
+	tags := []string{fmt.Sprintf("direction:%s", direction), fmt.Sprintf("link_profile:%s", link.Name)}
+	recorder.Distribution("network.transit_ms", float64(delay.Milliseconds()), tags)
+}
+
+func recordLoss(recorder *metrics.Recorder, direction string, link LinkProfile) {
+	tags := []string{fmt.Sprintf("direction:%s", direction), fmt.Sprintf("link_profile:%s", link.Name)}
+	recorder.Incr("network.lost_messages", tags)
+}
+
+// Requests finding the server's accept queue full are shed at once.
+func recordShed(recorder *metrics.Recorder, req *network_mock.MockRequest) {
+	tags := []string{"reason:accept_queue_full", fmt.Sprintf("endpoint:%s", EndpointName(req))}
+	recorder.Incr("server.shed_requests", tags)
+}
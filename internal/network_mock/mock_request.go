@@ -1,5 +1,7 @@
 package network_mock
 
+import "time"
+
 type requestEndpointEnum int
 
 const (
@@ -10,6 +12,13 @@ const (
 type MockRequest struct {
 	Endpoint   requestEndpointEnum
 	ClientData SessionData
+
+	// When the request reached the server's accept queue (set by the network delivering it).
+	ArrivalTime time.Time
+
+	// Set on requests received over HTTP: their throttle cookie is what the throttle gets to see of the client's
+	// state & their response (nil if shed) is handed back on it rather than sent over the network model.
+	HTTPResponseChannel chan *MockResponse
 }
 
 func MakeCheckoutRequest(session SessionData) *MockRequest {
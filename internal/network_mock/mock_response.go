@@ -4,6 +4,18 @@ type MockResponse struct {
 	ClientData SessionData
 }
 
+// The response carries its own copy of the throttle cookie (the client's keeps being mutated under its lock).
 func MakeServerResponse(session SessionData) *MockResponse {
+	cookie := make(map[string]string, len(session.ThrottleCookie))
+	for key, value := range session.ThrottleCookie {
+		cookie[key] = value
+	}
+	session.ThrottleCookie = cookie
 	return &MockResponse{ClientData: session}
 }
+
+// Response to req conveying session (as of serving req).
+func MakeServerResponseTo(req *MockRequest, session SessionData) *MockResponse {
+	session.Generation = req.ClientData.Generation
+	return MakeServerResponse(session)
+}
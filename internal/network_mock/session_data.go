@@ -4,8 +4,17 @@ import "time"
 
 type SessionData struct {
 	Id               int
+	ShopId           int
 	QueueEntryTime   time.Time
 	AdvisedPollAfter time.Time
 	ThrottleState    string
 	ThrottleCookie   map[string]string
+
+	// Position in line (0 = next to proceed) & estimated admission time, when the queue provides them.
+	QueuePosition          int64
+	EstimatedAdmissionTime time.Time
+
+	// Session generation (number of reloads) a request was sent in, echoed by its response so that clients can tell
+	// responses to sessions they abandoned.
+	Generation int
 }
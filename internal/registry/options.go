@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type OptionKind string
+
+const (
+	DurationOption    OptionKind = "duration"
+	IntOption         OptionKind = "int"
+	FloatOption       OptionKind = "float"
+	StringOption      OptionKind = "string"
+	BoolOption        OptionKind = "bool"
+	TierWeightsOption OptionKind = "tier_weights"
+)
+
+// Options configure one plugin (by option name). Values are of the Go type of their kind: time.Duration, int,
+// float64, string, bool or map[int]float64 (ints are accepted for floats).
+type Options map[string]interface{}
+
+// OptionSchema documents & constrains one of a plugin's options.
+type OptionSchema struct {
+	Name        string
+	Kind        OptionKind
+	Description string
+	// Value used when the option is absent (never nil).
+	Default interface{}
+	// Inclusive bounds of numeric options (durations in seconds).
+	Min float64
+	Max float64
+	// Accepted values of string options (any if empty).
+	OneOf []string
+}
+
+var Unbounded = math.Inf(1)
+
+// Durations must be >= 1ms (they typically time routines).
+func DurationSchema(name string, dflt time.Duration, description string) OptionSchema {
+	return OptionSchema{Name: name, Kind: DurationOption, Default: dflt, Min: 0.001, Max: Unbounded, Description: description}
+}
+
+func IntSchema(name string, dflt int, min, max float64, description string) OptionSchema {
+	return OptionSchema{Name: name, Kind: IntOption, Default: dflt, Min: min, Max: max, Description: description}
+}
+
+func FloatSchema(name string, dflt float64, min, max float64, description string) OptionSchema {
+	return OptionSchema{Name: name, Kind: FloatOption, Default: dflt, Min: min, Max: max, Description: description}
+}
+
+func StringSchema(name string, dflt string, description string) OptionSchema {
+	return OptionSchema{Name: name, Kind: StringOption, Default: dflt, Description: description}
+}
+
+func TierWeightsSchema(name string, description string) OptionSchema {
+	return OptionSchema{Name: name, Kind: TierWeightsOption, Default: map[int]float64{}, Description: description}
+}
+
+// Returns options with every value converted to the Go type of its kind & defaults filled in for those absent,
+// or an error describing every unknown, mistyped or out of range option.
+func ResolveOptions(schemas []OptionSchema, options Options) (Options, error) {
+	resolved := make(Options, len(schemas))
+	problems := make([]string, 0)
+	for name := range options {
+		if _, found := findSchema(schemas, name); !found {
+			problems = append(problems, fmt.Sprintf("unknown option '%s'", name))
+		}
+	}
+	for _, schema := range schemas {
+		value, given := options[schema.Name]
+		if !given {
+			resolved[schema.Name] = schema.Default
+			continue
+		}
+		converted, problem := schema.convert(value)
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+		resolved[schema.Name] = converted
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return resolved, nil
+}
+
+func findSchema(schemas []OptionSchema, name string) (OptionSchema, bool) {
+	for _, schema := range schemas {
+		if schema.Name == name {
+			return schema, true
+		}
+	}
+	return OptionSchema{}, false
+}
+
+// Returns value as the Go type of the schema's kind, else a description of what is wrong with it.
+func (s OptionSchema) convert(value interface{}) (interface{}, string) {
+	var number float64
+	var converted interface{}
+	switch s.Kind {
+	case DurationOption:
+		d, ok := value.(time.Duration)
+		if !ok {
+			return nil, s.mistyped(value)
+		}
+		number, converted = d.Seconds(), d
+	case IntOption:
+		switch v := value.(type) {
+		case int:
+			number, converted = float64(v), v
+		case int64:
+			number, converted = float64(v), int(v)
+		default:
+			return nil, s.mistyped(value)
+		}
+	case FloatOption:
+		switch v := value.(type) {
+		case float64:
+			number, converted = v, v
+		case int:
+			number, converted = float64(v), float64(v)
+		default:
+			return nil, s.mistyped(value)
+		}
+	case StringOption:
+		str, ok := value.(string)
+		if !ok {
+			return nil, s.mistyped(value)
+		}
+		if len(s.OneOf) > 0 && !containsString(s.OneOf, str) {
+			return nil, fmt.Sprintf("option '%s' must be one of: {%s}, got '%s'", s.Name, strings.Join(s.OneOf, ", "), str)
+		}
+		return str, ""
+	case BoolOption:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, s.mistyped(value)
+		}
+		return b, ""
+	case TierWeightsOption:
+		weights, ok := value.(map[int]float64)
+		if !ok {
+			return nil, s.mistyped(value)
+		}
+		for tier, weight := range weights {
+			if weight <= 0 {
+				return nil, fmt.Sprintf("option '%s' must weigh every tier > 0, got %v for tier %d", s.Name, weight, tier)
+			}
+		}
+		return weights, ""
+	}
+	if number < s.Min || number > s.Max {
+		return nil, fmt.Sprintf("option '%s' must be within %s, got %v", s.Name, s.Range(), value)
+	}
+	return converted, ""
+}
+
+func (s OptionSchema) mistyped(value interface{}) string {
+	return fmt.Sprintf("option '%s' must be a %s, got %T", s.Name, s.Kind, value)
+}
+
+// Human readable bounds of numeric options (e.g. "[1, inf)").
+func (s OptionSchema) Range() string {
+	if s.Kind != IntOption && s.Kind != FloatOption && s.Kind != DurationOption {
+		return ""
+	}
+	lower, upper := "[", "]"
+	if math.IsInf(s.Min, -1) {
+		lower = "("
+	}
+	if math.IsInf(s.Max, 1) {
+		upper = ")"
+	}
+	if s.Kind == DurationOption {
+		upperBound := "+Inf"
+		if !math.IsInf(s.Max, 1) {
+			upperBound = secondsToDuration(s.Max).String()
+		}
+		return fmt.Sprintf("%s%s, %s%s", lower, secondsToDuration(s.Min), upperBound, upper)
+	}
+	return fmt.Sprintf("%s%v, %v%s", lower, s.Min, s.Max, upper)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Typed getters of resolved options (see ResolveOptions).
+
+func (o Options) Duration(name string) time.Duration {
+	d, _ := o[name].(time.Duration)
+	return d
+}
+
+func (o Options) Int(name string) int {
+	i, _ := o[name].(int)
+	return i
+}
+
+func (o Options) Float(name string) float64 {
+	f, _ := o[name].(float64)
+	return f
+}
+
+func (o Options) String(name string) string {
+	s, _ := o[name].(string)
+	return s
+}
+
+func (o Options) Bool(name string) bool {
+	b, _ := o[name].(bool)
+	return b
+}
+
+func (o Options) TierWeights(name string) map[int]float64 {
+	weights, _ := o[name].(map[int]float64)
+	return weights
+}
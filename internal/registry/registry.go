@@ -0,0 +1,99 @@
+// Package registry holds the named implementations (plugins) of an extension point, e.g. queue types, along with
+// the options each accepts. Implementations register themselves from an init function of their own package.
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Plugin documents one registered implementation & the options it accepts.
+type Plugin struct {
+	Name        string
+	Description string
+	Options     []OptionSchema
+}
+
+// Registry maps plugin names to their documentation & constructor (whose type is up to the extension point).
+type Registry struct {
+	kind string
+
+	mutex        sync.RWMutex
+	plugins      map[string]Plugin
+	constructors map[string]interface{}
+}
+
+// Returns an empty registry of plugins of the given kind (e.g. "queue type", named in errors).
+func MakeRegistry(kind string) *Registry {
+	return &Registry{kind: kind, plugins: make(map[string]Plugin), constructors: make(map[string]interface{})}
+}
+
+// Panics if the name is already taken (plugins register at init => a clash is a programming error).
+func (r *Registry) Register(plugin Plugin, constructor interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, found := r.plugins[plugin.Name]; found {
+		panic(fmt.Errorf("%s '%s' registered twice", r.kind, plugin.Name))
+	}
+	for _, option := range plugin.Options {
+		if option.Default == nil {
+			panic(fmt.Errorf("%s '%s' option '%s' has no default", r.kind, plugin.Name, option.Name))
+		}
+	}
+	r.plugins[plugin.Name] = plugin
+	r.constructors[plugin.Name] = constructor
+}
+
+// Returns the plugin registered under name & its constructor, else an error listing the registered names.
+func (r *Registry) Lookup(name string) (Plugin, interface{}, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	plugin, found := r.plugins[name]
+	if !found {
+		return Plugin{}, nil, fmt.Errorf(
+			"%s must be one of: {%s}, got '%s'", r.kind, strings.Join(r.namesLocked(), ", "), name,
+		)
+	}
+	return plugin, r.constructors[name], nil
+}
+
+// Returns the options of the plugin registered under name, resolved against its schema (see ResolveOptions).
+func (r *Registry) ResolveOptions(name string, options Options) (Options, error) {
+	plugin, _, err := r.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := ResolveOptions(plugin.Options, options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options of %s '%s': %s", r.kind, name, err.Error())
+	}
+	return resolved, nil
+}
+
+// Registered plugins, sorted by name.
+func (r *Registry) Plugins() []Plugin {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	plugins := make([]Plugin, 0, len(r.plugins))
+	for _, name := range r.namesLocked() {
+		plugins = append(plugins, r.plugins[name])
+	}
+	return plugins
+}
+
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.namesLocked()
+}
+
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
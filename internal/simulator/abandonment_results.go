@@ -0,0 +1,72 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// Reports how many queued clients gave up waiting, per label & per time-in-queue bucket.
+// The bucket abandon_rate is conditional: abandonments in the bucket over clients who waited at least that long.
+func (d *SimulationDriver) aggregateAbandonmentResults(shop *Shop) {
+	queuedByLabel := make(map[string]int)
+	abandonedByLabel := make(map[string]int)
+	numBuckets := len(client.WaitBucketBounds) + 1
+	reachedBucket := make([]int, numBuckets)
+	abandonedInBucket := make([]int, numBuckets)
+	totalAbandoned := 0
+	now := time.Now()
+	for _, c := range shop.Clients {
+		if c.QueueEntryTime().IsZero() {
+			continue
+		}
+		queuedByLabel[c.Label()]++
+		waited := now.Sub(c.QueueEntryTime())
+		if !c.QueueExitTime().IsZero() {
+			waited = c.QueueDuration()
+		}
+		bucketIdx := client.WaitBucketIdx(waited)
+		for i := 0; i <= bucketIdx; i++ {
+			reachedBucket[i]++
+		}
+		if c.Abandoned() {
+			abandonedByLabel[c.Label()]++
+			abandonedInBucket[bucketIdx]++
+			totalAbandoned++
+		}
+	}
+	if totalAbandoned == 0 {
+		return
+	}
+	fmt.Printf("\n\nshop_id=%d\nnum_abandoned=%d", shop.Id, totalAbandoned)
+
+	labels := make([]string, 0, len(queuedByLabel))
+	for label := range queuedByLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		abandonRate := float64(abandonedByLabel[label]) / float64(queuedByLabel[label])
+		tags := []string{fmt.Sprintf("client_label:%s", label), shop.MetricTag()}
+		d.Metrics.Gauge("abandonment.rate", abandonRate, tags)
+		fmt.Printf(
+			"\nclient_label=%s queued=%d abandoned=%d abandon_rate=%.3f",
+			label, queuedByLabel[label], abandonedByLabel[label], abandonRate,
+		)
+	}
+	for i := 0; i < numBuckets; i++ {
+		if reachedBucket[i] == 0 {
+			continue
+		}
+		abandonRate := float64(abandonedInBucket[i]) / float64(reachedBucket[i])
+		tags := []string{fmt.Sprintf("wait_bucket:%s", client.WaitBucketName(i)), shop.MetricTag()}
+		d.Metrics.Gauge("abandonment.bucket_rate", abandonRate, tags)
+		fmt.Printf(
+			"\nwait_bucket=%s waited=%d abandoned=%d abandon_rate=%.3f",
+			client.WaitBucketName(i), reachedBucket[i], abandonedInBucket[i], abandonRate,
+		)
+	}
+	fmt.Printf("\n")
+}
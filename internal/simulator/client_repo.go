@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/registry"
 )
 
 type ClientRepo interface {
@@ -32,3 +33,36 @@ func (r *SimpleClientRepo) FetchClientById(id int) client.Client {
 	r.Unlock()
 	return c
 }
+
+// ClientRepoType is a registered implementation of ClientRepo.
+type ClientRepoType struct {
+	registry.Plugin
+	Make func() ClientRepo
+}
+
+var clientRepoTypes = registry.MakeRegistry("clientRepo type")
+
+// Makes a client repo type available by name (typically from an init function of the file implementing it).
+func RegisterClientRepoType(clientRepoType ClientRepoType) {
+	clientRepoTypes.Register(clientRepoType.Plugin, clientRepoType)
+}
+
+func LookupClientRepoType(name string) (ClientRepoType, error) {
+	_, clientRepoType, err := clientRepoTypes.Lookup(name)
+	if err != nil {
+		return ClientRepoType{}, err
+	}
+	return clientRepoType.(ClientRepoType), nil
+}
+
+// Registered client repo types, sorted by name.
+func ClientRepoTypes() []registry.Plugin {
+	return clientRepoTypes.Plugins()
+}
+
+func init() {
+	RegisterClientRepoType(ClientRepoType{
+		Plugin: registry.Plugin{Name: "simple_client_repo", Description: "In-memory map of clients by id."},
+		Make:   func() ClientRepo { return MakeSimpleClientRepo(make(map[int]client.Client)) },
+	})
+}
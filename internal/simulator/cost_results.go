@@ -0,0 +1,45 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/Shopify/goqueuesim/internal/cost"
+)
+
+// Total cost of serving the shop's clients & cost per successful checkout, to compare how much polling (& Redis
+// work) each queue requires to admit a client.
+func (d *SimulationDriver) aggregateCostResults(shop *Shop) {
+	if shop.Costs == nil {
+		return
+	}
+	costs := shop.Costs
+	numCheckouts := 0
+	for _, c := range shop.Clients {
+		if c.ReachedCheckout() {
+			numCheckouts++
+		}
+	}
+	entries := costs.Entries()
+	if numCheckouts == 0 || len(entries) == 0 {
+		return
+	}
+	total := costs.Total()
+	pollsPerCheckout := float64(costs.Count(cost.PollEndpoint)) / float64(numCheckouts)
+	cpuMsPerCheckout := total.CpuMs / float64(numCheckouts)
+	redisCallsPerCheckout := float64(total.RedisCalls) / float64(numCheckouts)
+	shopTags := []string{shop.MetricTag()}
+	d.Metrics.Gauge("cost.polls_per_checkout", pollsPerCheckout, shopTags)
+	d.Metrics.Gauge("cost.cpu_ms_per_checkout", cpuMsPerCheckout, shopTags)
+	d.Metrics.Gauge("cost.redis_calls_per_checkout", redisCallsPerCheckout, shopTags)
+	fmt.Printf(
+		"\nshop_id=%d checkouts=%d total_cpu_ms=%.1f total_redis_calls=%d polls_per_checkout=%.2f cpu_ms_per_checkout=%.2f redis_calls_per_checkout=%.2f",
+		shop.Id, numCheckouts, total.CpuMs, total.RedisCalls, pollsPerCheckout, cpuMsPerCheckout, redisCallsPerCheckout,
+	)
+	for _, entry := range entries {
+		fmt.Printf(
+			"\ncost_kind=%s operation=%s count=%d cpu_ms=%.1f redis_calls=%d",
+			entry.Kind, entry.Operation, entry.Count, entry.Cost.CpuMs, entry.Cost.RedisCalls,
+		)
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,36 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Compares the first wait estimate each admitted client received against its actual queue duration.
+// Positive errors mean clients waited longer than they were told.
+func (d *SimulationDriver) aggregateEtaAccuracy(shop *Shop) {
+	numEstimates := 0
+	totalErrorSecs, totalAbsErrorSecs := 0.0, 0.0
+	for _, c := range shop.Clients {
+		if !c.ReachedCheckout() {
+			continue
+		}
+		estimatedWait, ok := c.InitialEstimatedWait()
+		if !ok {
+			continue
+		}
+		errorSecs := (c.QueueDuration() - estimatedWait).Seconds()
+		totalErrorSecs += errorSecs
+		totalAbsErrorSecs += math.Abs(errorSecs)
+		numEstimates++
+	}
+	fmt.Printf("\n\nshop_id=%d\neta_num_estimates=%d", shop.Id, numEstimates)
+	if numEstimates == 0 {
+		fmt.Printf("\n")
+		return
+	}
+	meanErrorSecs := totalErrorSecs / float64(numEstimates)
+	meanAbsErrorSecs := totalAbsErrorSecs / float64(numEstimates)
+	d.Metrics.Gauge("eta.mean_error_secs", meanErrorSecs, []string{shop.MetricTag()})
+	d.Metrics.Gauge("eta.mean_abs_error_secs", meanAbsErrorSecs, []string{shop.MetricTag()})
+	fmt.Printf("\neta_mean_error_secs=%.2f\neta_mean_abs_error_secs=%.2f\n", meanErrorSecs, meanAbsErrorSecs)
+}
@@ -0,0 +1,43 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+	"github.com/rs/zerolog/log"
+)
+
+// Applies the throttle state & cookie an external server responded with to the client (which must be locked), as
+// in-process throttles do by mutating clients directly.
+func (d *SimulationDriver) adoptServerState(c client.Client, resp *network_mock.MockResponse) {
+	state, ok := client.ParseThrottleState(resp.ClientData.ThrottleState)
+	if !ok {
+		log.Debug().Int("client_id", c.ID()).Msg(fmt.Sprintf("Unknown throttle state '%s'", resp.ClientData.ThrottleState))
+		return
+	}
+	if c.HasExited() {
+		return
+	}
+	for key, value := range resp.ClientData.ThrottleCookie {
+		_ = c.SetThrottleCookieVal(key, value)
+	}
+	switch state {
+	case client.Queued:
+		if c.ThrottleState() == client.Initial {
+			_ = c.MarkQueued()
+		}
+	case client.InCheckout:
+		if c.ThrottleState() == client.Initial {
+			_ = c.MarkQueued()
+		}
+		if c.IsQueued() {
+			_ = c.MarkInCheckout()
+		}
+	case client.Exited:
+		// Turned away (e.g. sold out).
+		_ = c.MarkExited()
+		c.StopPolling()
+		d.Metrics.Incr("server.checkout", []string{"operation:turned_away", fmt.Sprintf("client_label:%s", c.Label())})
+	}
+}
@@ -0,0 +1,103 @@
+package simulator
+
+import (
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// Decides whether an earlier arriving cx overtaken by cy should count towards unfairness.
+type fairnessPairFilter func(cx, cy client.Client) bool
+
+func anyClientPair(cx, cy client.Client) bool {
+	return true
+}
+
+func samePriorityTier(cx, cy client.Client) bool {
+	return cx.PriorityTier() == cy.PriorityTier()
+}
+
+func differentPriorityTier(cx, cy client.Client) bool {
+	return cx.PriorityTier() != cy.PriorityTier()
+}
+
+type clientUnfairness struct {
+	CheatedClient          client.Client
+	MaxUnfairClient        client.Client
+	LocalMaxUnfairnessSecs float64
+}
+
+type fairnessReport struct {
+	NumUnfairEvents          int
+	UniqueCheatedClients     map[int]bool
+	UniqueUnfairClients      map[int]bool
+	NumCheatedClientsByLabel map[string]int
+	NumUnfairClientsByLabel  map[string]int
+	SummedUnfairnessSecs     float64
+	GlobalMaxUnfairnessSecs  float64
+	GlobalMaxCheatedClient   client.Client
+	GlobalMaxUnfairClient    client.Client
+
+	// Local max unfairness of every considered client (in input order).
+	PerClient []clientUnfairness
+}
+
+// Zero when there were no unfair events.
+func (r fairnessReport) AvgUnfairnessSecs() float64 {
+	if r.NumUnfairEvents == 0 {
+		return 0
+	}
+	return r.SummedUnfairnessSecs / float64(r.NumUnfairEvents)
+}
+
+// We will calculate fairness tolerance for each client. For every client X, find client Y such that:
+// -> entryTime(X) < entryTime(Y)
+// -> exitTime(X) > exitTime(Y)
+// -> entryTime(Y) - entryTime(X) is maximized
+// If no such client exists, then max unfairness is 0 (very fair).
+func computeFairness(clientsReachedCheckout []client.Client, isComparable fairnessPairFilter) fairnessReport {
+	report := fairnessReport{
+		UniqueCheatedClients:     make(map[int]bool),
+		UniqueUnfairClients:      make(map[int]bool),
+		NumCheatedClientsByLabel: make(map[string]int),
+		NumUnfairClientsByLabel:  make(map[string]int),
+		PerClient:                make([]clientUnfairness, 0, len(clientsReachedCheckout)),
+	}
+	for _, cx := range clientsReachedCheckout {
+		localMaxUnfairnessSecs := float64(0)
+		maxCy := cx
+		for _, cy := range clientsReachedCheckout {
+			if !isComparable(cx, cy) {
+				continue
+			}
+			lateArrivingClient := cy.QueueEntryTime().After(cx.QueueEntryTime())
+			if lateArrivingClient && cy.QueueExitTime().Before(cx.QueueExitTime()) {
+				report.NumUnfairEvents++
+				if !report.UniqueCheatedClients[cx.ID()] {
+					report.NumCheatedClientsByLabel[cx.Label()]++
+				}
+				if !report.UniqueUnfairClients[cy.ID()] {
+					report.NumUnfairClientsByLabel[cy.Label()]++
+				}
+				report.UniqueCheatedClients[cx.ID()] = true
+				report.UniqueUnfairClients[cy.ID()] = true
+				unfairDuration := cy.QueueEntryTime().Sub(cx.QueueEntryTime()).Seconds()
+				report.SummedUnfairnessSecs += unfairDuration
+				if unfairDuration > localMaxUnfairnessSecs {
+					maxCy = cy
+					localMaxUnfairnessSecs = unfairDuration
+				}
+			}
+		}
+
+		if localMaxUnfairnessSecs > report.GlobalMaxUnfairnessSecs {
+			report.GlobalMaxUnfairnessSecs = localMaxUnfairnessSecs
+			report.GlobalMaxCheatedClient = cx
+			report.GlobalMaxUnfairClient = maxCy
+		}
+		report.PerClient = append(report.PerClient, clientUnfairness{
+			CheatedClient:          cx,
+			MaxUnfairClient:        maxCy,
+			LocalMaxUnfairnessSecs: localMaxUnfairnessSecs,
+		})
+	}
+	return report
+}
@@ -0,0 +1,127 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FairnessSummary condenses a shop's fairnessReport so that runs can be compared with one another.
+type FairnessSummary struct {
+	NumCheckouts      int     `json:"num_checkouts"`
+	NumUnfairEvents   int     `json:"num_unfair_events"`
+	NumCheatedClients int     `json:"num_cheated_clients"`
+	MaxUnfairSecs     float64 `json:"max_unfair_secs"`
+	AvgUnfairSecs     float64 `json:"avg_unfair_secs"`
+}
+
+func summarizeFairness(numCheckouts int, report fairnessReport) FairnessSummary {
+	return FairnessSummary{
+		NumCheckouts:      numCheckouts,
+		NumUnfairEvents:   report.NumUnfairEvents,
+		NumCheatedClients: len(report.UniqueCheatedClients),
+		MaxUnfairSecs:     report.GlobalMaxUnfairnessSecs,
+		AvgUnfairSecs:     report.AvgUnfairnessSecs(),
+	}
+}
+
+// fairnessBaseline holds the summaries of a baseline run by shop id (as a string since json object keys must be
+// strings), along with the scenario it ran & the seed its client order was randomized with.
+type fairnessBaseline struct {
+	Scenario string                     `json:"scenario"`
+	Seed     int64                      `json:"seed"`
+	Shops    map[string]FairnessSummary `json:"shops"`
+}
+
+func writeFairnessBaseline(path string, scenario string, seed int64, summariesByShop map[int]FairnessSummary) error {
+	baseline := fairnessBaseline{
+		Scenario: scenario,
+		Seed:     seed,
+		Shops:    make(map[string]FairnessSummary, len(summariesByShop)),
+	}
+	for shopId, summary := range summariesByShop {
+		baseline.Shops[strconv.Itoa(shopId)] = summary
+	}
+	encoded, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func readFairnessBaseline(path string) (fairnessBaseline, map[int]FairnessSummary, error) {
+	var baseline fairnessBaseline
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return baseline, nil, err
+	}
+	if err := json.Unmarshal(encoded, &baseline); err != nil {
+		return baseline, nil, err
+	}
+	summariesByShop := make(map[int]FairnessSummary, len(baseline.Shops))
+	for shopIdStr, summary := range baseline.Shops {
+		shopId, err := strconv.Atoi(shopIdStr)
+		if err != nil {
+			return baseline, nil, fmt.Errorf("invalid shop id '%s' in fairness baseline", shopIdStr)
+		}
+		summariesByShop[shopId] = summary
+	}
+	return baseline, summariesByShop, nil
+}
+
+// Records this run as the baseline or reports its unfairness relative to the recorded (theoretical best) one,
+// provided the baseline ran the same scenario, was seeded alike & covers every shop.
+func (d *SimulationDriver) compareWithFairnessBaseline(summariesByShop map[int]FairnessSummary) error {
+	if d.FairnessBaselinePath == "" {
+		return nil
+	}
+	if d.RecordsFairnessBaseline {
+		if err := writeFairnessBaseline(d.FairnessBaselinePath, d.Scenario, d.Seed, summariesByShop); err != nil {
+			return fmt.Errorf("failed writing fairness baseline: %s", err.Error())
+		}
+		fmt.Printf("\nfairness baseline written to %s\n", d.FairnessBaselinePath)
+		return nil
+	}
+	recorded, baseline, err := readFairnessBaseline(d.FairnessBaselinePath)
+	if err != nil {
+		return fmt.Errorf("no fairness baseline to compare against (%s)", err.Error())
+	}
+	if recorded.Scenario != d.Scenario {
+		return fmt.Errorf(
+			"fairness baseline %s was recorded for scenario '%s' but this run is of scenario '%s'",
+			d.FairnessBaselinePath, recorded.Scenario, d.Scenario,
+		)
+	}
+	if recorded.Seed != d.Seed {
+		return fmt.Errorf(
+			"fairness baseline %s was recorded with seed %d but this run was seeded with %d",
+			d.FairnessBaselinePath, recorded.Seed, d.Seed,
+		)
+	}
+	for _, shop := range d.Shops {
+		if _, found := baseline[shop.Id]; !found {
+			return fmt.Errorf("fairness baseline %s has no results for shop %d", d.FairnessBaselinePath, shop.Id)
+		}
+	}
+	for _, shop := range d.Shops {
+		summary, baselineSummary := summariesByShop[shop.Id], baseline[shop.Id]
+		excessUnfairEvents := summary.NumUnfairEvents - baselineSummary.NumUnfairEvents
+		excessMaxUnfairSecs := summary.MaxUnfairSecs - baselineSummary.MaxUnfairSecs
+		excessAvgUnfairSecs := summary.AvgUnfairSecs - baselineSummary.AvgUnfairSecs
+		tags := []string{shop.MetricTag()}
+		d.Metrics.Gauge("baseline.excess_unfair_events", float64(excessUnfairEvents), tags)
+		d.Metrics.Gauge("baseline.excess_max_unfair_seconds", excessMaxUnfairSecs, tags)
+		fmt.Printf(
+			"\nshop_id=%d vs_fifo_baseline excess_unfair_events=%d excess_max_unfair_secs=%.2f"+
+				" excess_avg_unfair_secs=%.2f\n",
+			shop.Id, excessUnfairEvents, excessMaxUnfairSecs, excessAvgUnfairSecs,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+	"github.com/rs/zerolog/log"
+)
+
+// Serves the checkout & poll endpoints (see network.NewHTTPRequest) until the simulation drains. Requests queue
+// for the workers of the node picked by the load balancer like any other, with the throttle state carried in
+// cookies.
+func (d *SimulationDriver) startHTTPServer() {
+	listener, err := net.Listen("tcp", d.HTTPListenAddr)
+	if err != nil {
+		panic(fmt.Errorf("failed to listen on %s: %s", d.HTTPListenAddr, err.Error()))
+	}
+	inFlight := make(chan struct{}, d.MaxInFlightHTTPRequests)
+	d.httpServer = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+		default:
+			d.Metrics.Incr("server.http_rejected", []string{"reason:overloaded"})
+			writeShedHTTPResponse(w)
+			return
+		}
+		d.handleHTTPRequest(w, r)
+	})}
+	go d.httpServer.Serve(listener)
+	fmt.Printf("Serving throttle over HTTP at: http://%s\n", listener.Addr().String())
+}
+
+func (d *SimulationDriver) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	req, err := network.ReadHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ArrivalTime = start
+	if _, found := d.shopsById[req.ClientData.ShopId]; !found {
+		http.Error(w, fmt.Sprintf("unknown shop %d", req.ClientData.ShopId), http.StatusNotFound)
+		return
+	}
+	c := d.ClientRepo.FetchClientById(req.ClientData.Id)
+	if c == nil || c.ShopID() != req.ClientData.ShopId {
+		http.Error(w, fmt.Sprintf("unknown session %d", req.ClientData.Id), http.StatusNotFound)
+		return
+	}
+	// Buffered so that workers never block on handlers which gave up waiting.
+	req.HTTPResponseChannel = make(chan *network_mock.MockResponse, 1)
+	node := d.routeToNode(req)
+	select {
+	case node.RequestChannel <- req:
+	default:
+		tags := []string{"reason:node_accept_queue_full", fmt.Sprintf("endpoint:%s", network.EndpointName(req))}
+		d.Metrics.Incr("server.shed_requests", append(tags, node.MetricTag()))
+		d.serverLatencies.recordShed(req)
+		writeShedHTTPResponse(w)
+		return
+	}
+	var resp *network_mock.MockResponse
+	select {
+	case <-d.Ctx.Done():
+	case <-r.Context().Done():
+	case resp = <-req.HTTPResponseChannel:
+	}
+	if resp == nil {
+		writeShedHTTPResponse(w)
+		return
+	}
+	if err := network.WriteHTTPResponse(w, resp); err != nil {
+		log.Debug().Msg(fmt.Sprintf("Failed to write HTTP response: %s", err.Error()))
+	}
+}
+
+func writeShedHTTPResponse(w http.ResponseWriter) {
+	w.Header().Set(network.RetryAfterHeader, "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
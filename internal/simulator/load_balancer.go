@@ -0,0 +1,47 @@
+package simulator
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// LoadBalancer picks which of the server nodes serves each request.
+type LoadBalancer interface {
+	// Index (in [0, numNodes)) of the node to serve req.
+	Route(req *network_mock.MockRequest, numNodes int) int
+}
+
+type RandomLoadBalancer struct{}
+
+func MakeRandomLoadBalancer() *RandomLoadBalancer {
+	return &RandomLoadBalancer{}
+}
+
+func (lb *RandomLoadBalancer) Route(req *network_mock.MockRequest, numNodes int) int {
+	return rand.Intn(numNodes)
+}
+
+type RoundRobinLoadBalancer struct {
+	numRouted uint64
+}
+
+func MakeRoundRobinLoadBalancer() *RoundRobinLoadBalancer {
+	return &RoundRobinLoadBalancer{}
+}
+
+func (lb *RoundRobinLoadBalancer) Route(req *network_mock.MockRequest, numNodes int) int {
+	return int((atomic.AddUint64(&lb.numRouted, 1) - 1) % uint64(numNodes))
+}
+
+// StickyLoadBalancer always routes a client's requests to the same node (by session id).
+type StickyLoadBalancer struct{}
+
+func MakeStickyLoadBalancer() *StickyLoadBalancer {
+	return &StickyLoadBalancer{}
+}
+
+func (lb *StickyLoadBalancer) Route(req *network_mock.MockRequest, numNodes int) int {
+	return req.ClientData.Id % numNodes
+}
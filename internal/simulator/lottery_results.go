@@ -0,0 +1,69 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/throttle/queue"
+)
+
+// Win rate of each label relative to the overall win rate among entrants (1.0 = no advantage).
+// Bots polling harder or entering more reliably show up as labels with an advantage above 1.
+// Clients entering after the draw could never win it => they are reported apart as late entrants.
+func (d *SimulationDriver) aggregateLotteryResults(shop *Shop) {
+	var drawTime time.Time
+	for _, driver := range shop.NodeThrottleDrivers {
+		if nodeDrawTime := queue.LatestDrawTime(driver.ThrottleQueue); nodeDrawTime.After(drawTime) {
+			drawTime = nodeDrawTime
+		}
+	}
+	entrantsByLabel := make(map[string]int)
+	winnersByLabel := make(map[string]int)
+	totalEntrants, totalWinners := 0, 0
+	lateEntrants, lateWinners := 0, 0
+	for _, c := range shop.Clients {
+		if c.QueueEntryTime().IsZero() {
+			continue
+		}
+		if !drawTime.IsZero() && c.QueueEntryTime().After(drawTime) {
+			lateEntrants++
+			if c.ReachedCheckout() {
+				lateWinners++
+			}
+			continue
+		}
+		entrantsByLabel[c.Label()]++
+		totalEntrants++
+		if c.ReachedCheckout() {
+			winnersByLabel[c.Label()]++
+			totalWinners++
+		}
+	}
+	fmt.Printf(
+		"\n\nshop_id=%d\nnum_entrants=%d\nnum_winners=%d\nnum_late_entrants=%d\nnum_late_winners=%d\n",
+		shop.Id, totalEntrants, totalWinners, lateEntrants, lateWinners,
+	)
+	if totalEntrants == 0 || totalWinners == 0 {
+		return
+	}
+	overallWinRate := float64(totalWinners) / float64(totalEntrants)
+
+	labels := make([]string, 0, len(entrantsByLabel))
+	for label := range entrantsByLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		winRate := float64(winnersByLabel[label]) / float64(entrantsByLabel[label])
+		advantage := winRate / overallWinRate
+		tags := []string{fmt.Sprintf("client_label:%s", label), shop.MetricTag()}
+		d.Metrics.Gauge("lottery.win_rate", winRate, tags)
+		d.Metrics.Gauge("lottery.advantage", advantage, tags)
+		fmt.Printf(
+			"\nclient_label=%s entrants=%d winners=%d win_rate=%.3f advantage=%.2f",
+			label, entrantsByLabel[label], winnersByLabel[label], winRate, advantage,
+		)
+	}
+	fmt.Printf("\n")
+}
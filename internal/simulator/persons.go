@@ -0,0 +1,77 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// personView presents every session of one person as a single client to fairness computations:
+// the person entered the queue with their earliest session & left it with their first admitted one.
+type personView struct {
+	client.Client
+	entryTime time.Time
+}
+
+func (p *personView) ID() int {
+	return p.PersonID()
+}
+
+func (p *personView) QueueEntryTime() time.Time {
+	return p.entryTime
+}
+
+type personsSummary struct {
+	NumMultiSessionPersons int
+	NumExtraSessions       int
+	NumDuplicateCheckouts  int
+}
+
+// Collapses sessions to the persons who reached checkout (in order of their first session).
+// Persons holding a single session are returned as is.
+func personsReachedCheckout(clients []client.Client) ([]client.Client, personsSummary) {
+	summary := personsSummary{}
+	sessionsByPerson := make(map[int][]client.Client)
+	personOrder := make([]int, 0)
+	for _, c := range clients {
+		if _, ok := sessionsByPerson[c.PersonID()]; !ok {
+			personOrder = append(personOrder, c.PersonID())
+		}
+		sessionsByPerson[c.PersonID()] = append(sessionsByPerson[c.PersonID()], c)
+	}
+
+	persons := make([]client.Client, 0, len(personOrder))
+	for _, personId := range personOrder {
+		sessions := sessionsByPerson[personId]
+		if len(sessions) == 1 {
+			if sessions[0].ReachedCheckout() {
+				persons = append(persons, sessions[0])
+			}
+			continue
+		}
+		summary.NumMultiSessionPersons++
+		summary.NumExtraSessions += len(sessions) - 1
+
+		var firstAdmitted client.Client
+		entryTime := time.Time{}
+		numAdmitted := 0
+		for _, s := range sessions {
+			if !s.QueueEntryTime().IsZero() && (entryTime.IsZero() || s.QueueEntryTime().Before(entryTime)) {
+				entryTime = s.QueueEntryTime()
+			}
+			if !s.ReachedCheckout() {
+				continue
+			}
+			numAdmitted++
+			if firstAdmitted == nil || s.QueueExitTime().Before(firstAdmitted.QueueExitTime()) {
+				firstAdmitted = s
+			}
+		}
+		if firstAdmitted == nil {
+			continue
+		}
+		summary.NumDuplicateCheckouts += numAdmitted - 1
+		persons = append(persons, &personView{Client: firstAdmitted, entryTime: entryTime})
+	}
+	return persons, summary
+}
@@ -0,0 +1,50 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Average time in queue of each label relative to the overall average (below 1.0 = faster than average).
+// Labels exploiting the queue (e.g. tampering with their throttle cookie) show up well below 1.
+func (d *SimulationDriver) aggregateQueueTimeResults(shop *Shop) {
+	summedSecsByLabel := make(map[string]float64)
+	reachedByLabel := make(map[string]int)
+	summedNoticeMsByLabel := make(map[string]float64)
+	summedSecs, numReached := 0.0, 0
+	for _, c := range shop.Clients {
+		if !c.ReachedCheckout() {
+			continue
+		}
+		queueSecs := c.QueueDuration().Seconds()
+		summedSecsByLabel[c.Label()] += queueSecs
+		reachedByLabel[c.Label()]++
+		summedNoticeMsByLabel[c.Label()] += float64(c.AdmissionNoticeDelay().Milliseconds())
+		summedSecs += queueSecs
+		numReached++
+	}
+	if numReached == 0 || summedSecs == 0 {
+		return
+	}
+	avgSecs := summedSecs / float64(numReached)
+
+	labels := make([]string, 0, len(reachedByLabel))
+	for label := range reachedByLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	fmt.Printf("\n\nshop_id=%d\navg_queue_secs=%.2f", shop.Id, avgSecs)
+	for _, label := range labels {
+		labelAvgSecs := summedSecsByLabel[label] / float64(reachedByLabel[label])
+		relativeQueueTime := labelAvgSecs / avgSecs
+		tags := []string{fmt.Sprintf("client_label:%s", label), shop.MetricTag()}
+		// Clients learn of their admission a network latency (or more, over lossy links) after the server admits them.
+		avgNoticeMs := summedNoticeMsByLabel[label] / float64(reachedByLabel[label])
+		d.Metrics.Gauge("queue_time.relative_to_avg", relativeQueueTime, tags)
+		fmt.Printf(
+			"\nclient_label=%s reached_checkout=%d avg_queue_secs=%.2f relative_queue_time=%.2f avg_admission_notice_ms=%.0f",
+			label, reachedByLabel[label], labelAvgSecs, relativeQueueTime, avgNoticeMs,
+		)
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,44 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Reports how often dropped clients reloaded with a fresh session (i.e. how much load retries amplified).
+func (d *SimulationDriver) aggregateRetryResults(shop *Shop) {
+	reloadsByLabel := make(map[string]int)
+	reloadedClientsByLabel := make(map[string]int)
+	reloadedReachedByLabel := make(map[string]int)
+	totalReloads := 0
+	for _, c := range shop.Clients {
+		if c.NumReloads() == 0 {
+			continue
+		}
+		reloadsByLabel[c.Label()] += c.NumReloads()
+		reloadedClientsByLabel[c.Label()]++
+		if c.ReachedCheckout() {
+			reloadedReachedByLabel[c.Label()]++
+		}
+		totalReloads += c.NumReloads()
+	}
+	if totalReloads == 0 {
+		return
+	}
+	fmt.Printf("\n\nshop_id=%d\nnum_reloads=%d", shop.Id, totalReloads)
+
+	labels := make([]string, 0, len(reloadsByLabel))
+	for label := range reloadsByLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		tags := []string{fmt.Sprintf("client_label:%s", label), shop.MetricTag()}
+		d.Metrics.Gauge("retry.reloads", float64(reloadsByLabel[label]), tags)
+		fmt.Printf(
+			"\nclient_label=%s reloaded_clients=%d reloads=%d reloaded_reached_checkout=%d",
+			label, reloadedClientsByLabel[label], reloadsByLabel[label], reloadedReachedByLabel[label],
+		)
+	}
+	fmt.Printf("\n")
+}
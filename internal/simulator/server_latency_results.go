@@ -0,0 +1,80 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+var reportedLatencyPercentiles = []float64{50, 95, 99}
+
+// Server side latency (from arrival in the accept queue to responding) of every request served & count of those
+// shed, per endpoint.
+type serverLatencies struct {
+	metrics        *metrics.Recorder
+	mutex          sync.Mutex
+	msByEndpoint   map[string][]float64
+	shedByEndpoint map[string]int
+}
+
+func (l *serverLatencies) record(req *network_mock.MockRequest, latency time.Duration) {
+	endpoint := network.EndpointName(req)
+	latencyMs := float64(latency) / float64(time.Millisecond)
+	l.metrics.Distribution("server.latency_ms", latencyMs, []string{fmt.Sprintf("endpoint:%s", endpoint)})
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.msByEndpoint == nil {
+		l.msByEndpoint = make(map[string][]float64)
+	}
+	l.msByEndpoint[endpoint] = append(l.msByEndpoint[endpoint], latencyMs)
+}
+
+func (l *serverLatencies) recordShed(req *network_mock.MockRequest) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.shedByEndpoint == nil {
+		l.shedByEndpoint = make(map[string]int)
+	}
+	l.shedByEndpoint[network.EndpointName(req)]++
+}
+
+// Latency percentiles of requests served by server workers (shed requests are left out but counted).
+func (d *SimulationDriver) aggregateServerLatencyResults() {
+	l := &d.serverLatencies
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	endpoints := make([]string, 0, len(l.msByEndpoint))
+	for endpoint := range l.msByEndpoint {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		latenciesMs := l.msByEndpoint[endpoint]
+		sort.Float64s(latenciesMs)
+		fmt.Printf("\nendpoint=%s served=%d shed=%d", endpoint, len(latenciesMs), l.shedByEndpoint[endpoint])
+		for _, pct := range reportedLatencyPercentiles {
+			latencyMs := percentile(latenciesMs, pct)
+			tags := []string{fmt.Sprintf("endpoint:%s", endpoint), fmt.Sprintf("percentile:p%.0f", pct)}
+			d.Metrics.Gauge("server.latency_percentile_ms", latencyMs, tags)
+			fmt.Printf(" p%.0f_latency_ms=%.1f", pct, latencyMs)
+		}
+	}
+	if len(endpoints) > 0 {
+		fmt.Printf("\n")
+	}
+}
+
+// Nearest rank percentile of sorted values.
+func percentile(sorted []float64, pct float64) float64 {
+	rank := int(math.Ceil(pct/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,95 @@
+package simulator
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+)
+
+// ServerNode is one app node behind the load balancer: it has its own accept queue & workers, and serves each
+// shop through the shop's throttle of the same node id (see Shop.NodeThrottleDrivers).
+type ServerNode struct {
+	Id             int
+	RequestChannel chan *network_mock.MockRequest
+
+	numServedRequests int64
+	numAdmissions     int64
+
+	// Clients removed from the node's queues as they left without being admitted.
+	numDepartures int64
+}
+
+func (n *ServerNode) MetricTag() string {
+	return fmt.Sprintf("node_id:%d", n.Id)
+}
+
+// A single node serves straight from the simulator's accept queue, else the load balancer spreads requests over
+// per node accept queues of the same capacity.
+func (d *SimulationDriver) startServerNodes() {
+	numNodes := d.NumServerNodes
+	if numNodes < 1 {
+		numNodes = 1
+	}
+	d.nodes = make([]*ServerNode, numNodes)
+	for i := range d.nodes {
+		requests := d.RequestTargetChannel
+		if numNodes > 1 {
+			requests = make(chan *network_mock.MockRequest, cap(d.RequestTargetChannel))
+		}
+		d.nodes[i] = &ServerNode{Id: i, RequestChannel: requests}
+		d.workers.Add(d.NumServerWorkers)
+		for w := 0; w < d.NumServerWorkers; w++ {
+			go d.runServerWorker(d.nodes[i])
+		}
+	}
+	if numNodes > 1 {
+		d.workers.Add(1)
+		go d.runLoadBalancer()
+	}
+}
+
+func (d *SimulationDriver) routeToNode(req *network_mock.MockRequest) *ServerNode {
+	if len(d.nodes) == 1 {
+		return d.nodes[0]
+	}
+	return d.nodes[d.LoadBalancer.Route(req, len(d.nodes))]
+}
+
+// Blocking routine forwarding requests to the node picked by the load balancer (shedding those finding its
+// accept queue full, which their clients only learn of by timing out).
+func (d *SimulationDriver) runLoadBalancer() {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.Ctx.Done():
+			return
+		case req := <-d.RequestTargetChannel:
+			node := d.routeToNode(req)
+			select {
+			case node.RequestChannel <- req:
+			default:
+				tags := []string{"reason:node_accept_queue_full", fmt.Sprintf("endpoint:%s", network.EndpointName(req))}
+				d.Metrics.Incr("server.shed_requests", append(tags, node.MetricTag()))
+				d.serverLatencies.recordShed(req)
+			}
+		}
+	}
+}
+
+// Share of requests, admissions & departures handled by each node (only reported when there are several).
+func (d *SimulationDriver) aggregateServerNodeResults() {
+	if len(d.nodes) < 2 {
+		return
+	}
+	for _, node := range d.nodes {
+		served, admissions := atomic.LoadInt64(&node.numServedRequests), atomic.LoadInt64(&node.numAdmissions)
+		departures := atomic.LoadInt64(&node.numDepartures)
+		d.Metrics.Gauge("node.served_requests", float64(served), []string{node.MetricTag()})
+		d.Metrics.Gauge("node.admissions", float64(admissions), []string{node.MetricTag()})
+		d.Metrics.Gauge("node.queue_departures", float64(departures), []string{node.MetricTag()})
+		fmt.Printf("\nnode_id=%d served_requests=%d admissions=%d queue_departures=%d", node.Id, served, admissions, departures)
+	}
+	fmt.Printf("\n")
+}
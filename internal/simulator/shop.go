@@ -0,0 +1,34 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/cost"
+	"github.com/Shopify/goqueuesim/internal/throttle"
+)
+
+// Shop groups everything simulated per storefront: its clients, inventory, queue & tracker.
+// Shops share the simulator's server nodes (and optionally Redis) with one another.
+type Shop struct {
+	Id int
+
+	// Cancelled once the shop's inventory is depleted (derived from the simulation context).
+	Ctx           context.Context
+	CtxCancelFunc context.CancelFunc
+
+	// The shop's throttle on each server node (indexed by node id, none if throttled externally). In-memory
+	// queues & trackers are local to their node whereas Redis-backed queues share their state across nodes.
+	NodeThrottleDrivers []*throttle.CheckoutThrottleDriver
+
+	// Cost of the work done for the shop on every node.
+	Costs *cost.Ledger
+
+	Clients             []client.Client
+	InventoryStockTotal int
+}
+
+func (s *Shop) MetricTag() string {
+	return fmt.Sprintf("shop_id:%d", s.Id)
+}
@@ -3,25 +3,41 @@ package simulator
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/cost"
 	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network"
 	"github.com/Shopify/goqueuesim/internal/network_mock"
-	"github.com/Shopify/goqueuesim/internal/throttle"
+	"github.com/rs/zerolog/log"
 )
 
 type SimulationDriver struct {
-	Ctx                    context.Context
-	CtxCancelFunc          context.CancelFunc
-	CheckoutThrottleDriver *throttle.CheckoutThrottleDriver
+	Ctx           context.Context
+	CtxCancelFunc context.CancelFunc
 
-	Clients             []client.Client
-	NumServerWorkers    int
-	InventoryStockTotal int
+	// Shops simulated concurrently against the same server nodes, each running NumServerWorkers workers.
+	// Requests are spread over nodes by the LoadBalancer (see server_node.go).
+	Shops            []*Shop
+	NumServerNodes   int
+	NumServerWorkers int
+	LoadBalancer     LoadBalancer
 
+	// Time a worker spends serving a request of either endpoint, in ms. Requests having waited in the accept
+	// queue for longer than MaxQueueingDelay (if set) are shed unanswered, as their clients likely gave up.
+	CheckoutServiceTimeMs client.Distribution
+	PollServiceTimeMs     client.Distribution
+	MaxQueueingDelay      time.Duration
+
+	// Accept queue of the server workers (bounded by its capacity: requests finding it full are shed).
 	RequestTargetChannel chan *network_mock.MockRequest
 	ResponseChannelsMap  map[int]chan *network_mock.MockResponse
+	Network              network.Model
 
 	// Listener channel awaiting message to notify that simulation has ended.
 	SimulationCompletedListenerChannel chan struct{}
@@ -33,152 +49,385 @@ type SimulationDriver struct {
 	ClientRepo ClientRepo
 
 	MaxUnfairnessToleranceSeconds float64
+
+	// Fairness results of the strict FIFO baseline, recorded by baseline runs and compared against otherwise.
+	FairnessBaselinePath    string
+	RecordsFairnessBaseline bool
+
+	// Seed the client order of shops was randomized with (runs are only compared with baselines seeded alike).
+	Seed int64
+
+	// Identifies the clients, inventory & throttling budget simulated (runs are only compared with baselines of the
+	// same scenario).
+	Scenario string
+
+	// Arrival order is irrelevant to raffles => report per label advantage instead of temporal unfairness.
+	ReportsLotteryAdvantage bool
+
+	// When set, the throttle is also served over HTTP on this address (see http_server.go), rejecting requests
+	// beyond MaxInFlightHTTPRequests as overloaded.
+	HTTPListenAddr          string
+	MaxInFlightHTTPRequests int
+
+	// Clients are throttled by an external server (see external_throttle.go) => shops have no throttle of their own.
+	ThrottledExternally bool
+
+	// Receives every metric of the simulation.
+	Metrics *metrics.Recorder
+
+	// Invoked (if set) once the simulation is stopped by RequestCheckpoint, before it drains, so as to snapshot
+	// the simulation as it stood.
+	CheckpointListener func()
+
+	// Once the simulation completes, every routine is cancelled & given up to DrainTimeout to return.
+	DrainTimeout time.Duration
+
+	// Fairness of each shop (by id), set once StartSimulation returns (left empty by lottery runs).
+	FairnessSummaries map[int]FairnessSummary
+
+	shopsById map[int]*Shop
+
+	// Id of the node whose queue each client (by id) joined, so that clients leaving unadmitted are removed from it.
+	queueNodeIds    sync.Map
+	nodes           []*ServerNode
+	httpServer      *http.Server
+	serverLatencies serverLatencies
+
+	// Server, load balancer & client workers (awaited when draining).
+	workers        sync.WaitGroup
+	completionOnce sync.Once
+
+	// Whether RequestCheckpoint (rather than natural completion) stopped the simulation.
+	checkpointRequested bool
 }
 
 func (d *SimulationDriver) StartSimulation() {
+	d.serverLatencies.metrics = d.Metrics
+	d.shopsById = make(map[int]*Shop, len(d.Shops))
+	for _, shop := range d.Shops {
+		d.shopsById[shop.Id] = shop
+	}
 	go d.monitorSimulationCompletion()
+	go d.monitorShopsSoldOut()
 
-	for w := 0; w < d.NumServerWorkers; w++ {
-		go d.runServerWorker()
+	d.startServerNodes()
+	if d.HTTPListenAddr != "" {
+		d.startHTTPServer()
 	}
 	d.startClients()
 	d.StartSignalWaitGroup.Done()
 
 	// Block on postprocessing until simulation completes.
 	<-d.SimulationCompletedListenerChannel
-	d.aggregateFairnessResults()
+	if d.checkpointRequested && d.CheckpointListener != nil {
+		d.CheckpointListener()
+	}
+	d.drain()
+	defer d.Metrics.Flush()
+	for _, shop := range d.Shops {
+		d.aggregateEtaAccuracy(shop)
+		d.aggregateAbandonmentResults(shop)
+		d.aggregateQueueTimeResults(shop)
+		d.aggregateThroughputResults(shop)
+		d.aggregateCostResults(shop)
+		d.aggregateRetryResults(shop)
+	}
+	d.aggregateServerLatencyResults()
+	d.aggregateServerNodeResults()
+	if d.ReportsLotteryAdvantage {
+		for _, shop := range d.Shops {
+			d.aggregateLotteryResults(shop)
+		}
+		return
+	}
+	d.FairnessSummaries = make(map[int]FairnessSummary, len(d.Shops))
+	for _, shop := range d.Shops {
+		d.FairnessSummaries[shop.Id] = d.aggregateFairnessResults(shop)
+	}
+	if err := d.compareWithFairnessBaseline(d.FairnessSummaries); err != nil {
+		fmt.Printf("\nnot compared with the fairness baseline: %s\n", err.Error())
+	}
 }
 
 // Blocking routine to monitor simulation completion (either context cancelled or clients completed).
 func (d *SimulationDriver) monitorSimulationCompletion() {
-	go func() { <-d.Ctx.Done(); close(d.SimulationCompletedListenerChannel); return }()
+	go func() { <-d.Ctx.Done(); d.completeSimulation() }()
 	d.ClientsFinishedWaitGroup.Wait()
-	close(d.SimulationCompletedListenerChannel)
+	d.completeSimulation()
+}
+
+func (d *SimulationDriver) completeSimulation() {
+	d.completionOnce.Do(func() { close(d.SimulationCompletedListenerChannel) })
+}
+
+// Stops the simulation to checkpoint it (see CheckpointListener), unless it is already completing. Returns
+// whether the simulation will be checkpointed.
+func (d *SimulationDriver) RequestCheckpoint() bool {
+	requested := false
+	d.completionOnce.Do(func() {
+		requested = true
+		d.checkpointRequested = true
+		close(d.SimulationCompletedListenerChannel)
+	})
+	return requested
+}
+
+// Cancels every routine of the simulation, then waits (at most DrainTimeout) for the workers to return &
+// the HTTP server to answer the requests in flight.
+func (d *SimulationDriver) drain() {
+	d.CtxCancelFunc()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), d.DrainTimeout)
+	defer cancelDrain()
+	if d.httpServer != nil {
+		if err := d.httpServer.Shutdown(drainCtx); err != nil {
+			log.Warn().Msg(fmt.Sprintf("HTTP server did not shut down cleanly: %s", err.Error()))
+		}
+	}
+	drained := make(chan struct{})
+	go func() { d.workers.Wait(); close(drained) }()
+	select {
+	case <-drained:
+	case <-drainCtx.Done():
+		log.Warn().Msg(fmt.Sprintf("workers still running after draining for %s", d.DrainTimeout))
+	}
 }
 
-func (d *SimulationDriver) runServerWorker() {
-	for { // loop infinitely
+// Blocking routine cancelling the simulation once every shop has depleted its inventory.
+func (d *SimulationDriver) monitorShopsSoldOut() {
+	for _, shop := range d.Shops {
+		<-shop.Ctx.Done()
+	}
+	d.CtxCancelFunc()
+}
+
+func (d *SimulationDriver) runServerWorker(node *ServerNode) {
+	defer d.workers.Done()
+	for { // loop until cancelled
 		select { // block waiting to handle client request
-		case req := <-d.RequestTargetChannel:
+		case <-d.Ctx.Done():
+			return
+		case req := <-node.RequestChannel:
+			queueingDelay := time.Since(req.ArrivalTime)
+			endpointTag := fmt.Sprintf("endpoint:%s", network.EndpointName(req))
+			d.Metrics.Distribution("server.queueing_delay_ms", float64(queueingDelay.Milliseconds()), []string{endpointTag})
+			if d.MaxQueueingDelay > 0 && queueingDelay > d.MaxQueueingDelay {
+				d.Metrics.Incr("server.shed_requests", []string{"reason:queueing_delay", endpointTag})
+				d.serverLatencies.recordShed(req)
+				if req.HTTPResponseChannel != nil {
+					req.HTTPResponseChannel <- nil
+				}
+				continue
+			}
+			d.spendServiceTime(req)
 			c := d.ClientRepo.FetchClientById(req.ClientData.Id)
 			c.Lock()
-			switch req.Endpoint {
-			case network_mock.CheckoutEndpoint:
-				metrics.Incr("server.requests", []string{"endpoint:checkout"})
-			case network_mock.PollingEndpoint:
-				metrics.Incr("server.requests", []string{"endpoint:poll"})
+			if req.HTTPResponseChannel != nil {
+				// The cookie received is what the throttle gets to see of the client's state.
+				for key, value := range req.ClientData.ThrottleCookie {
+					_ = c.SetThrottleCookieVal(key, value)
+				}
+			}
+			d.serveRequest(node, c, req)
+			resp := network_mock.MakeServerResponseTo(req, c.SessionData())
+			if req.HTTPResponseChannel != nil {
+				req.HTTPResponseChannel <- resp
+			} else {
+				d.Network.SendResponse(resp, d.ResponseChannelsMap[c.ID()])
 			}
-			d.CheckoutThrottleDriver.TryThrottleStateTransition(c)
-			d.ResponseChannelsMap[c.ID()] <- network_mock.MakeServerResponse(c.SessionData())
 			c.Unlock()
+			d.serverLatencies.record(req, time.Since(req.ArrivalTime))
 		}
 	}
 }
 
+// Keeps the worker busy for as long as serving req takes.
+func (d *SimulationDriver) spendServiceTime(req *network_mock.MockRequest) {
+	serviceTimeMs := d.CheckoutServiceTimeMs
+	if req.Endpoint == network_mock.PollingEndpoint {
+		serviceTimeMs = d.PollServiceTimeMs
+	}
+	if serviceTime := time.Duration(serviceTimeMs.Sample() * float64(time.Millisecond)); serviceTime > 0 {
+		time.Sleep(serviceTime)
+	}
+}
+
+// Runs req through its shop's throttle on node (the client must be locked).
+func (d *SimulationDriver) serveRequest(node *ServerNode, c client.Client, req *network_mock.MockRequest) {
+	shop := d.shopsById[req.ClientData.ShopId]
+	switch req.Endpoint {
+	case network_mock.CheckoutEndpoint:
+		d.Metrics.Incr("server.requests", []string{"endpoint:checkout", shop.MetricTag(), node.MetricTag()})
+		shop.Costs.Charge(cost.CheckoutEndpoint, "request")
+	case network_mock.PollingEndpoint:
+		d.Metrics.Incr("server.requests", []string{"endpoint:poll", shop.MetricTag(), node.MetricTag()})
+		shop.Costs.Charge(cost.PollEndpoint, "request")
+	}
+	atomic.AddInt64(&node.numServedRequests, 1)
+	priorState := c.ThrottleState()
+	state, changed := shop.NodeThrottleDrivers[node.Id].TryThrottleStateTransition(c)
+	if changed && priorState == client.Initial {
+		// Sessions resumed from a surviving cookie stay in the queue of the node they first joined.
+		d.queueNodeIds.LoadOrStore(c.ID(), node.Id)
+	}
+	if changed && state == client.InCheckout {
+		atomic.AddInt64(&node.numAdmissions, 1)
+		d.queueNodeIds.Delete(c.ID())
+	}
+}
+
+// Removes c (locked) from the queue of the node it joined as it leaves without being admitted.
+func (d *SimulationDriver) removeDepartedClient(c client.Client) {
+	nodeId, found := d.queueNodeIds.Load(c.ID())
+	if !found {
+		return
+	}
+	d.queueNodeIds.Delete(c.ID())
+	shop := d.shopsById[c.ShopID()]
+	if shop == nil || nodeId.(int) >= len(shop.NodeThrottleDrivers) {
+		return
+	}
+	shop.NodeThrottleDrivers[nodeId.(int)].RemoveDeparted(c)
+	atomic.AddInt64(&d.nodes[nodeId.(int)].numDepartures, 1)
+}
+
+// Id of the node whose queue each queued client (by id) joined.
+func (d *SimulationDriver) QueueNodeIds() map[int]int {
+	nodeIds := make(map[int]int)
+	d.queueNodeIds.Range(func(clientId, nodeId interface{}) bool {
+		nodeIds[clientId.(int)] = nodeId.(int)
+		return true
+	})
+	return nodeIds
+}
+
+// Records that the client joined the queue of nodeId (e.g. when restored from a checkpoint).
+func (d *SimulationDriver) RecordQueueNode(clientId int, nodeId int) {
+	d.queueNodeIds.Store(clientId, nodeId)
+}
+
 func (d *SimulationDriver) startClients() {
-	for i := 0; i < len(d.Clients); i++ {
-		c := d.Clients[i]
-		d.ClientRepo.WriteClient(c)
-		// Provide initial server handshake -> then kickstart client worker.
-		d.ResponseChannelsMap[c.ID()] <- network_mock.MakeServerResponse(c.SessionData())
-		go d.runClientWorker(c)
+	for _, shop := range d.Shops {
+		for _, c := range shop.Clients {
+			d.ClientRepo.WriteClient(c)
+			c.SetQueueLeaveListener(d.removeDepartedClient)
+			// Provide initial server handshake -> then kickstart client worker.
+			d.ResponseChannelsMap[c.ID()] <- network_mock.MakeServerResponse(c.SessionData())
+			d.workers.Add(1)
+			go d.runClientWorker(c)
+		}
 	}
 }
 
 func (d *SimulationDriver) runClientWorker(c client.Client) {
+	defer d.workers.Done()
 	serverRespChannel := d.ResponseChannelsMap[c.ID()]
 	d.StartSignalWaitGroup.Wait()
-	for { // loop infinitely
+	for { // loop until cancelled
 		select { // block waiting to handle server response
+		case <-d.Ctx.Done():
+			return
 		case resp := <-serverRespChannel:
 			c.Lock()
+			if d.ThrottledExternally {
+				d.adoptServerState(c, resp)
+			}
 			_ = c.HandleResponse(resp)
 			c.Unlock()
 		}
 	}
 }
 
-func (d *SimulationDriver) aggregateFairnessResults() {
-	clientsSubsetReachedCheckout := make([]client.Client, 0)
-	for _, c := range d.Clients {
-		if c.ReachedCheckout() {
-			clientsSubsetReachedCheckout = append(clientsSubsetReachedCheckout, c)
-		}
-	}
-
-	// We will calculate fairness tolerance for each client. For every client X, find client Y such that:
-	// -> entryTime(X) < entryTime(Y)
-	// -> exitTime(X) > exitTime(Y)
-	// -> entryTime(Y) - entryTime(X) is maximized
-	// If no such client exists, then max unfairness is 0 (very fair).
-	numUnfairEvents := 0
-	uniqueCheatedClients := make(map[int]bool)
-	uniqueUnfairClients := make(map[int]bool)
-	numCheatedClientsByLabel := make(map[string]int)
-	numUnfairClientsByLabel := make(map[string]int)
-	summedUnfairnessSecs := 0.0
-	globalMaxUnfairnessSecs := 0.0
-	var globalMaxCheatedClient, globalMaxUnfairClient client.Client
-	for _, cx := range clientsSubsetReachedCheckout {
-		localMaxUnfairnessSecs := float64(0)
-		maxCy := cx
-		for _, cy := range clientsSubsetReachedCheckout {
-			lateArrivingClient := cy.QueueEntryTime().After(cx.QueueEntryTime())
-			if lateArrivingClient && cy.QueueExitTime().Before(cx.QueueExitTime()) {
-				numUnfairEvents++
-				if !uniqueCheatedClients[cx.ID()] {
-					numCheatedClientsByLabel[cx.Label()]++
-				}
-				if !uniqueUnfairClients[cy.ID()] {
-					numUnfairClientsByLabel[cy.Label()]++
-				}
-				uniqueCheatedClients[cx.ID()] = true
-				uniqueUnfairClients[cy.ID()] = true
-				unfairDuration := cy.QueueEntryTime().Sub(cx.QueueEntryTime()).Seconds()
-				summedUnfairnessSecs += unfairDuration
-				if unfairDuration > localMaxUnfairnessSecs {
-					maxCy = cy
-					localMaxUnfairnessSecs = unfairDuration
-				}
-			}
-		}
+// Fairness is only meaningful among clients competing for the same shop's queue.
+// Persons holding several sessions count once, as if they had queued from their earliest session.
+func (d *SimulationDriver) aggregateFairnessResults(shop *Shop) FairnessSummary {
+	shopTag := shop.MetricTag()
+	clientsSubsetReachedCheckout, persons := personsReachedCheckout(shop.Clients)
 
+	report := computeFairness(clientsSubsetReachedCheckout, anyClientPair)
+	for _, unfairness := range report.PerClient {
 		// TODO: should we gauge something with max unfairness threshold?
-
-		if localMaxUnfairnessSecs > globalMaxUnfairnessSecs {
-			globalMaxUnfairnessSecs = localMaxUnfairnessSecs
-			globalMaxCheatedClient = cx
-			globalMaxUnfairClient = maxCy
-		}
-		cheatedClientLabel := fmt.Sprintf("cheated_client_label:%s", cx.Label())
-		unfairClientLabel := fmt.Sprintf("unfair_client_label:%s", maxCy.Label())
-		metrics.Distribution(
+		cheatedClientLabel := fmt.Sprintf("cheated_client_label:%s", unfairness.CheatedClient.Label())
+		unfairClientLabel := fmt.Sprintf("unfair_client_label:%s", unfairness.MaxUnfairClient.Label())
+		d.Metrics.Distribution(
 			"cheated_client.local_max_unfairness_seconds",
-			localMaxUnfairnessSecs,
-			[]string{cheatedClientLabel, unfairClientLabel},
+			unfairness.LocalMaxUnfairnessSecs,
+			[]string{cheatedClientLabel, unfairClientLabel, shopTag},
 		)
 	}
-	for label, count := range numCheatedClientsByLabel {
-		metrics.Gauge(
+	for label, count := range report.NumCheatedClientsByLabel {
+		d.Metrics.Gauge(
 			"total_cheated_clients",
 			float64(count),
-			[]string{fmt.Sprintf("cheated_client_label:%s", label)},
+			[]string{fmt.Sprintf("cheated_client_label:%s", label), shopTag},
 		)
 	}
-	for label, count := range numUnfairClientsByLabel {
-		metrics.Gauge(
+	for label, count := range report.NumUnfairClientsByLabel {
+		d.Metrics.Gauge(
 			"total_unfair_clients",
 			float64(count),
-			[]string{fmt.Sprintf("unfair_client_label:%s", label)},
+			[]string{fmt.Sprintf("unfair_client_label:%s", label), shopTag},
 		)
 	}
 	for i := 0; i < 50; i++ { // Ensure this message is notified.
-		metrics.Gauge("global_max_unfair_seconds", globalMaxUnfairnessSecs, nil)
-	}
-	avgUnfairnessSecs := summedUnfairnessSecs / float64(numUnfairEvents)
-	fmt.Printf("\nnum_unfair_events=%d\nnum_cheated_clients=%d", numUnfairEvents, len(uniqueCheatedClients))
-	fmt.Printf("\nnum_unfair_clients=%d\n", len(uniqueUnfairClients))
-	fmt.Printf("\nmax_unfair_secs=%.2f\navg_unfair_secs=%.2f\n", globalMaxUnfairnessSecs, avgUnfairnessSecs)
-	labelX, labelY := globalMaxCheatedClient.Label(), globalMaxUnfairClient.Label()
-	fmt.Printf("\nmax_unfair_client_type=%s\nmax_cheated_client_type=%s", labelX, labelY)
+		d.Metrics.Gauge("global_max_unfair_seconds", report.GlobalMaxUnfairnessSecs, []string{shopTag})
+	}
+	fmt.Printf("\n\nshop_id=%d", shop.Id)
+	fmt.Printf(
+		"\nnum_unfair_events=%d\nnum_cheated_clients=%d",
+		report.NumUnfairEvents, len(report.UniqueCheatedClients),
+	)
+	fmt.Printf("\nnum_unfair_clients=%d\n", len(report.UniqueUnfairClients))
+	if persons.NumMultiSessionPersons > 0 {
+		d.Metrics.Gauge("multi_session.duplicate_checkouts", float64(persons.NumDuplicateCheckouts), []string{shopTag})
+		fmt.Printf(
+			"\nnum_multi_session_persons=%d\nnum_extra_sessions=%d\nnum_duplicate_checkouts=%d\n",
+			persons.NumMultiSessionPersons, persons.NumExtraSessions, persons.NumDuplicateCheckouts,
+		)
+	}
+	fmt.Printf(
+		"\nmax_unfair_secs=%.2f\navg_unfair_secs=%.2f\n",
+		report.GlobalMaxUnfairnessSecs, report.AvgUnfairnessSecs(),
+	)
+	if report.GlobalMaxCheatedClient != nil {
+		labelX, labelY := report.GlobalMaxCheatedClient.Label(), report.GlobalMaxUnfairClient.Label()
+		fmt.Printf("\nmax_unfair_client_type=%s\nmax_cheated_client_type=%s\n", labelX, labelY)
+	}
+	d.aggregateTierFairnessResults(shop, clientsSubsetReachedCheckout)
+	return summarizeFairness(len(clientsSubsetReachedCheckout), report)
+}
+
+// Reports unfairness within each priority tier and across tiers (the latter being priority by design).
+func (d *SimulationDriver) aggregateTierFairnessResults(shop *Shop, clientsReachedCheckout []client.Client) {
+	clientsByTier := make(map[int][]client.Client)
+	for _, c := range clientsReachedCheckout {
+		clientsByTier[c.PriorityTier()] = append(clientsByTier[c.PriorityTier()], c)
+	}
+	if len(clientsByTier) < 2 {
+		return
+	}
+	tiers := make([]int, 0, len(clientsByTier))
+	for tier := range clientsByTier {
+		tiers = append(tiers, tier)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(tiers)))
+	for _, tier := range tiers {
+		tierClients := clientsByTier[tier]
+		tierReport := computeFairness(tierClients, samePriorityTier)
+		tags := []string{shop.MetricTag(), fmt.Sprintf("priority_tier:%d", tier)}
+		d.Metrics.Gauge("tier.num_unfair_events", float64(tierReport.NumUnfairEvents), tags)
+		d.Metrics.Gauge("tier.max_unfair_seconds", tierReport.GlobalMaxUnfairnessSecs, tags)
+		fmt.Printf(
+			"\npriority_tier=%d num_checkouts=%d num_unfair_events=%d max_unfair_secs=%.2f avg_unfair_secs=%.2f",
+			tier, len(tierClients), tierReport.NumUnfairEvents,
+			tierReport.GlobalMaxUnfairnessSecs, tierReport.AvgUnfairnessSecs(),
+		)
+	}
+	crossTierReport := computeFairness(clientsReachedCheckout, differentPriorityTier)
+	shopTags := []string{shop.MetricTag()}
+	d.Metrics.Gauge("cross_tier.num_unfair_events", float64(crossTierReport.NumUnfairEvents), shopTags)
+	d.Metrics.Gauge("cross_tier.max_unfair_seconds", crossTierReport.GlobalMaxUnfairnessSecs, shopTags)
+	fmt.Printf(
+		"\ncross_tier num_unfair_events=%d max_unfair_secs=%.2f avg_unfair_secs=%.2f\n",
+		crossTierReport.NumUnfairEvents, crossTierReport.GlobalMaxUnfairnessSecs, crossTierReport.AvgUnfairnessSecs(),
+	)
 }
@@ -0,0 +1,49 @@
+package simulator
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rate at which the shop's queue admitted clients, from its first arrival to its last admission (as observed by
+// clients when throttled externally).
+func (d *SimulationDriver) aggregateThroughputResults(shop *Shop) {
+	var firstEntry, lastExit time.Time
+	admissionsBySecond := make(map[int64]int)
+	numAdmitted := 0
+	for _, c := range shop.Clients {
+		if !c.QueueEntryTime().IsZero() && (firstEntry.IsZero() || c.QueueEntryTime().Before(firstEntry)) {
+			firstEntry = c.QueueEntryTime()
+		}
+		if !c.ReachedCheckout() {
+			continue
+		}
+		numAdmitted++
+		admissionsBySecond[c.QueueExitTime().Unix()]++
+		if c.QueueExitTime().After(lastExit) {
+			lastExit = c.QueueExitTime()
+		}
+	}
+	if numAdmitted == 0 {
+		return
+	}
+	spanSecs := lastExit.Sub(firstEntry).Seconds()
+	// Zero when every admission happened at the instant of the first arrival.
+	avgPerSec := 0.0
+	if spanSecs > 0 {
+		avgPerSec = float64(numAdmitted) / spanSecs
+	}
+	peakPerSec := 0
+	for _, admissions := range admissionsBySecond {
+		if admissions > peakPerSec {
+			peakPerSec = admissions
+		}
+	}
+	shopTags := []string{shop.MetricTag()}
+	d.Metrics.Gauge("throughput.avg_admissions_per_sec", avgPerSec, shopTags)
+	d.Metrics.Gauge("throughput.peak_admissions_per_sec", float64(peakPerSec), shopTags)
+	fmt.Printf(
+		"\nshop_id=%d admitted=%d span_secs=%.2f avg_admissions_per_sec=%.2f peak_admissions_per_sec=%d\n",
+		shop.Id, numAdmitted, spanSecs, avgPerSec, peakPerSec,
+	)
+}
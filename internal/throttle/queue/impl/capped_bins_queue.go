@@ -1,11 +1,14 @@
 package impl
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
@@ -16,9 +19,12 @@ const (
 )
 
 type CappedBinsQueue struct {
+	metrics *metrics.Recorder
+
 	bins               map[int64]map[int]bool
 	curWindowDequeues  map[int64]int64
 	binSize            int64
+	windowDur          time.Duration
 	queueBin           int64
 	workingBin         int64
 	totalQueuedClients int64
@@ -26,7 +32,7 @@ type CappedBinsQueue struct {
 }
 
 func (cbq *CappedBinsQueue) Add(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
+	defer cbq.metrics.BenchmarkMethod(time.Now(), "add", nil)
 	cbq.mu.Lock()
 	defer cbq.mu.Unlock()
 
@@ -44,10 +50,11 @@ func (cbq *CappedBinsQueue) Add(c client.Client) {
 	bins[cbq.queueBin][c.ID()] = true
 	cbq.totalQueuedClients++
 	c.SetThrottleCookieVal(cappedUserBinKey, strconv.FormatInt(cbq.queueBin, 10))
+	cbq.adviseQueuePosition(c, cbq.queueBin)
 }
 
 func (cbq *CappedBinsQueue) Remove(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	defer cbq.metrics.BenchmarkMethod(time.Now(), "remove", nil)
 	cbq.mu.Lock()
 	defer cbq.mu.Unlock()
 	cbq.totalQueuedClients--
@@ -55,11 +62,16 @@ func (cbq *CappedBinsQueue) Remove(c client.Client) {
 }
 
 func (cbq *CappedBinsQueue) Size() int64 {
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
 	return cbq.totalQueuedClients
 }
 
 func (cbq *CappedBinsQueue) IsCandidateToProceed(c client.Client) bool {
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
 	if ub, e := cbq.getUserBin(c); !e {
+		cbq.adviseQueuePosition(c, ub)
 		return ub <= cbq.workingBin
 	}
 	return false
@@ -67,6 +79,8 @@ func (cbq *CappedBinsQueue) IsCandidateToProceed(c client.Client) bool {
 
 // Received once per window => working bin incremented at most once per time window.
 func (cbq *CappedBinsQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
 	if earlyExit, ok := feedback.CustomFeedback["should_ignore_poll_util"]; ok && earlyExit == true {
 		return
 	}
@@ -81,9 +95,9 @@ func (cbq *CappedBinsQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 		"workingBin=%d checkoutUtil=%.2f consideredClientUtil=%.2f allowedWindowDrainUtil=%.2f\n",
 		cbq.workingBin, checkoutUtil, consideredClientUtil, allowedWindowDrainUtil,
 	)
-	metrics.Gauge("cbq.working_bin", float64(cbq.workingBin), nil)
-	metrics.Gauge("cbq.considered_client_util", consideredClientUtil, nil)
-	metrics.Gauge("cbq.allowed_window_drain_util", allowedWindowDrainUtil, nil)
+	cbq.metrics.Gauge("cbq.working_bin", float64(cbq.workingBin), nil)
+	cbq.metrics.Gauge("cbq.considered_client_util", consideredClientUtil, nil)
+	cbq.metrics.Gauge("cbq.allowed_window_drain_util", allowedWindowDrainUtil, nil)
 
 	if checkoutUtil <= 0.2 {
 		cbq.workingBin += cbq.workingBin + 2
@@ -100,6 +114,8 @@ func (cbq *CappedBinsQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 }
 
 func (cbq *CappedBinsQueue) Clear() {
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
 	cbq.bins = make(map[int64]map[int]bool)
 	cbq.curWindowDequeues = make(map[int64]int64)
 	cbq.workingBin = 1
@@ -107,6 +123,24 @@ func (cbq *CappedBinsQueue) Clear() {
 	cbq.totalQueuedClients = 0
 }
 
+// Estimates (lock required) the clients binned ahead of userBin yet to be dequeued, admitted as the working bin
+// reaches userBin (at most one bin per window).
+func (cbq *CappedBinsQueue) adviseQueuePosition(c client.Client, userBin int64) {
+	if userBin <= cbq.workingBin {
+		_ = c.AdviseQueuePosition(0, time.Now())
+		return
+	}
+	clientsAhead := -cbq.curWindowDequeues[cbq.workingBin]
+	for bin := cbq.workingBin; bin < userBin; bin++ {
+		clientsAhead += int64(len(cbq.bins[bin]))
+	}
+	if clientsAhead < 0 {
+		clientsAhead = 0
+	}
+	remDurToAdmission := time.Duration(userBin-cbq.workingBin) * cbq.windowDur
+	_ = c.AdviseQueuePosition(clientsAhead, time.Now().Add(remDurToAdmission))
+}
+
 func (cbq *CappedBinsQueue) getUserBin(c client.Client) (ubin int64, ok bool) {
 	if r, ok := c.GetThrottleCookieVal(cappedUserBinKey); ok {
 		if ub, err := strconv.ParseInt(r, 10, 64); err == nil {
@@ -116,3 +150,52 @@ func (cbq *CappedBinsQueue) getUserBin(c client.Client) (ubin int64, ok bool) {
 	}
 	return
 }
+
+type cappedBinsQueueState struct {
+	Bins               map[int64][]int
+	CurWindowDequeues  map[int64]int64
+	QueueBin           int64
+	WorkingBin         int64
+	TotalQueuedClients int64
+}
+
+func (cbq *CappedBinsQueue) Checkpoint(_ checkpoint.Clock) (json.RawMessage, error) {
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
+	s := cappedBinsQueueState{
+		Bins:               make(map[int64][]int, len(cbq.bins)),
+		CurWindowDequeues:  cbq.curWindowDequeues,
+		QueueBin:           cbq.queueBin,
+		WorkingBin:         cbq.workingBin,
+		TotalQueuedClients: cbq.totalQueuedClients,
+	}
+	for bin, clientIds := range cbq.bins {
+		for clientId := range clientIds {
+			s.Bins[bin] = append(s.Bins[bin], clientId)
+		}
+		sort.Ints(s.Bins[bin])
+	}
+	return json.Marshal(s)
+}
+
+func (cbq *CappedBinsQueue) Restore(state json.RawMessage, _ checkpoint.Clock) error {
+	var s cappedBinsQueueState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	cbq.mu.Lock()
+	defer cbq.mu.Unlock()
+	cbq.bins = make(map[int64]map[int]bool, len(s.Bins))
+	for bin, clientIds := range s.Bins {
+		cbq.bins[bin] = make(map[int]bool, cbq.binSize)
+		for _, clientId := range clientIds {
+			cbq.bins[bin][clientId] = true
+		}
+	}
+	cbq.curWindowDequeues = s.CurWindowDequeues
+	if cbq.curWindowDequeues == nil {
+		cbq.curWindowDequeues = make(map[int64]int64)
+	}
+	cbq.queueBin, cbq.workingBin, cbq.totalQueuedClients = s.QueueBin, s.WorkingBin, s.TotalQueuedClients
+	return nil
+}
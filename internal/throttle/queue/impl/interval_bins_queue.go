@@ -20,6 +20,8 @@ const (
 )
 
 type IntervalBinsQueue struct {
+	metrics *metrics.Recorder
+
 	ctx                  context.Context
 	startSignalWaitGroup *sync.WaitGroup
 
@@ -39,7 +41,7 @@ type IntervalBinsQueue struct {
 }
 
 func (ibq *IntervalBinsQueue) Add(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
+	defer ibq.metrics.BenchmarkMethod(time.Now(), "add", nil)
 	ibq.binMutex.Lock()
 	defer ibq.binMutex.Unlock()
 
@@ -57,10 +59,11 @@ func (ibq *IntervalBinsQueue) Add(c client.Client) {
 	remMillisecsToPoll := relativeQueuePosition * float64(ibq.windowDur.Milliseconds())
 	remDurToPoll := time.Duration(remMillisecsToPoll) * time.Millisecond
 	c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+	c.AdviseQueuePosition(ibq.totalQueuedClients-1, time.Now().Add(remDurToPoll))
 }
 
 func (ibq *IntervalBinsQueue) Remove(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	defer ibq.metrics.BenchmarkMethod(time.Now(), "remove", nil)
 	ibq.binMutex.Lock()
 	defer ibq.binMutex.Unlock()
 	if binIdx, ok := ibq.getUserBinIdx(c); ok {
@@ -76,21 +79,41 @@ func (ibq *IntervalBinsQueue) Size() int64 {
 func (ibq *IntervalBinsQueue) IsCandidateToProceed(c client.Client) bool {
 	isCandidate := false
 	if binIdx, ok := ibq.getUserBinIdx(c); ok {
+		ibq.binMutex.Lock()
+		defer ibq.binMutex.Unlock()
 		isCandidate = binIdx <= ibq.maxConsideredBinIdx
 		if (binIdx + 1) == ibq.maxConsideredBinIdx {
 			c.AdvisePollAfter(ibq.nextScheduledBinUpdate)
 		}
+		ibq.adviseQueuePosition(c, binIdx)
 	}
 	return isCandidate
 }
 
+// Estimates (lock required) admission once the clients still queued in bins ahead of binIdx have checked out at
+// the max checkout rate (clients of considered bins may proceed now).
+func (ibq *IntervalBinsQueue) adviseQueuePosition(c client.Client, binIdx int64) {
+	clientsAhead := int64(0)
+	for bin := int64(0); bin < binIdx; bin++ {
+		clientsAhead += ibq.binCounts[bin]
+	}
+	if binIdx <= ibq.maxConsideredBinIdx {
+		c.AdviseQueuePosition(clientsAhead, time.Now())
+		return
+	}
+	relativeQueuePosition := float64(clientsAhead) / float64(ibq.maxCheckoutsPerWindow)
+	remMillisecsToAdmission := relativeQueuePosition * float64(ibq.windowDur.Milliseconds())
+	remDurToAdmission := time.Duration(remMillisecsToAdmission) * time.Millisecond
+	c.AdviseQueuePosition(clientsAhead, time.Now().Add(remDurToAdmission))
+}
+
 func (ibq *IntervalBinsQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	// Method can effectively no-op since this queue does not rely on tracker feedback.
 	fmt.Printf(
 		"latestBinIdx=%d workingBin=%d checkoutUtil=%.2f queuedClients=%d\n",
 		ibq.latestBinIdx, ibq.maxConsideredBinIdx, feedback.CheckoutUtil, ibq.Size(),
 	)
-	metrics.Gauge("ibq.working_bin", float64(ibq.maxConsideredBinIdx), nil)
+	ibq.metrics.Gauge("ibq.working_bin", float64(ibq.maxConsideredBinIdx), nil)
 }
 
 func (ibq *IntervalBinsQueue) RoutinelyUpdateLatestBin() {
@@ -110,13 +133,17 @@ func (ibq *IntervalBinsQueue) RoutinelyUpdateLatestBin() {
 
 func (ibq *IntervalBinsQueue) RoutinelyMaximizeFairThroughput() {
 	ibq.startSignalWaitGroup.Wait()
-	time.Sleep(ibq.maxUnfairMilliseconds)
+	if !ibq.sleepUnlessDone(ibq.maxUnfairMilliseconds) {
+		return
+	}
 	for {
 		ibq.binMutex.Lock()
 		if ibq.maxConsideredBinIdx >= ibq.latestBinIdx {
 			// Never increment beyond latest queueing bin.
 			ibq.binMutex.Unlock()
-			time.Sleep(ibq.maxUnfairMilliseconds)
+			if !ibq.sleepUnlessDone(ibq.maxUnfairMilliseconds) {
+				return
+			}
 			continue
 		}
 		// Compute allowedInactiveMillisecs = (clients in bin / max checkouts per window) * window duration
@@ -131,13 +158,30 @@ func (ibq *IntervalBinsQueue) RoutinelyMaximizeFairThroughput() {
 			ibq.maxConsideredBinIdx++
 			ibq.consideredBinLastUpdated = time.Now()
 			ibq.binMutex.Unlock()
-		} else {
-			ibq.binMutex.Unlock()
-			time.Sleep(remAllowedInactivity)
+			if ibq.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		ibq.binMutex.Unlock()
+		if !ibq.sleepUnlessDone(remAllowedInactivity) {
+			return
 		}
 	}
 }
 
+// Returns false (early) if cancelled while sleeping.
+func (ibq *IntervalBinsQueue) sleepUnlessDone(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ibq.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 func (ibq *IntervalBinsQueue) Clear() {
 	ibq.binCounts = make(map[int64]int64)
 	ibq.latestBinIdx = 0
@@ -0,0 +1,239 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+)
+
+type lotteryEntrant struct {
+	clientId int
+	weight   float64
+}
+
+// LotteryQueue models a raffle: clients entering during the entry period are randomly ordered
+// (optionally weighted by priority tier) once it closes, then admitted first-come-first-served
+// in drawn order. Late entrants queue behind every drawn entrant.
+type LotteryQueue struct {
+	metrics *metrics.Recorder
+
+	ctx                  context.Context
+	startSignalWaitGroup *sync.WaitGroup
+	mu                   sync.Mutex
+
+	entryPeriod time.Duration
+
+	// Relative odds per priority tier (tiers missing default to 1).
+	tierWeights map[int]float64
+
+	drawTime   time.Time
+	drawn      bool
+	entrants   []lotteryEntrant
+	entrantIds map[int]bool
+
+	// Admits drawn entrants in order once the draw has happened.
+	drawnOrder *StrictFifoQueue
+}
+
+func (lq *LotteryQueue) Add(c client.Client) {
+	defer lq.metrics.BenchmarkMethod(time.Now(), "add", nil)
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+
+	if lq.drawn {
+		lq.drawnOrder.Add(c)
+		return
+	}
+	if !lq.entrantIds[c.ID()] {
+		lq.entrantIds[c.ID()] = true
+		lq.entrants = append(lq.entrants, lotteryEntrant{clientId: c.ID(), weight: lq.tierWeight(c.PriorityTier())})
+	}
+	if !lq.drawTime.IsZero() {
+		_ = c.AdvisePollAfter(lq.drawTime)
+	}
+}
+
+func (lq *LotteryQueue) Remove(c client.Client) {
+	defer lq.metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	if lq.drawn {
+		lq.drawnOrder.Remove(c)
+		return
+	}
+	// Entrants leaving before the draw forfeit their ticket.
+	if lq.entrantIds[c.ID()] {
+		delete(lq.entrantIds, c.ID())
+		for i, entrant := range lq.entrants {
+			if entrant.clientId == c.ID() {
+				lq.entrants = append(lq.entrants[:i], lq.entrants[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (lq *LotteryQueue) Size() int64 {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	if lq.drawn {
+		return lq.drawnOrder.Size()
+	}
+	return int64(len(lq.entrants))
+}
+
+// Nobody proceeds before the draw => entrants are advised to come back once it has happened.
+func (lq *LotteryQueue) IsCandidateToProceed(c client.Client) bool {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	if !lq.drawn {
+		if !lq.drawTime.IsZero() {
+			_ = c.AdvisePollAfter(lq.drawTime)
+		}
+		return false
+	}
+	return lq.drawnOrder.IsCandidateToProceed(c)
+}
+
+func (lq *LotteryQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	if !lq.drawn {
+		fmt.Printf("lottery entrants=%d remainingEntryPeriod=%s\n", len(lq.entrants), time.Until(lq.drawTime))
+		lq.metrics.Gauge("lottery.entrants", float64(len(lq.entrants)), nil)
+		return
+	}
+	lq.drawnOrder.ReceiveTrackerFeedback(feedback)
+}
+
+func (lq *LotteryQueue) Clear() {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	lq.drawn = false
+	lq.entrants = make([]lotteryEntrant, 0)
+	lq.entrantIds = make(map[int]bool)
+	lq.drawnOrder.Clear()
+}
+
+func (lq *LotteryQueue) DrawTime() time.Time {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	return lq.drawTime
+}
+
+// Blocking routine closing entries once the entry period (counted from simulation start) has elapsed.
+func (lq *LotteryQueue) DrawAfterEntryPeriod() {
+	lq.startSignalWaitGroup.Wait()
+	lq.mu.Lock()
+	if lq.drawn {
+		lq.mu.Unlock()
+		return
+	}
+	// Entry periods restored from a checkpoint keep their original draw time.
+	if lq.drawTime.IsZero() {
+		lq.drawTime = time.Now().Add(lq.entryPeriod)
+	}
+	remainingEntryPeriod := time.Until(lq.drawTime)
+	lq.mu.Unlock()
+
+	drawTimer := time.NewTimer(remainingEntryPeriod)
+	select {
+	case <-lq.ctx.Done():
+		drawTimer.Stop()
+	case <-drawTimer.C:
+		lq.draw()
+	}
+}
+
+// Weighted random permutation: each entrant draws key = Exp(1) / weight and entrants are ordered by key.
+func (lq *LotteryQueue) draw() {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+
+	keys := make(map[int]float64, len(lq.entrants))
+	for _, entrant := range lq.entrants {
+		keys[entrant.clientId] = -math.Log(1-rand.Float64()) / entrant.weight
+	}
+	drawnEntrants := append([]lotteryEntrant(nil), lq.entrants...)
+	sort.SliceStable(drawnEntrants, func(i, j int) bool {
+		return keys[drawnEntrants[i].clientId] < keys[drawnEntrants[j].clientId]
+	})
+
+	lq.drawnOrder.mu.Lock()
+	for _, entrant := range drawnEntrants {
+		lq.drawnOrder.pushBack(entrant.clientId)
+	}
+	lq.drawnOrder.mu.Unlock()
+	lq.drawn = true
+
+	fmt.Printf("\nlottery drawn among %d entrants\n\n", len(drawnEntrants))
+	lq.metrics.Gauge("lottery.entrants", float64(len(drawnEntrants)), nil)
+}
+
+func (lq *LotteryQueue) tierWeight(tier int) float64 {
+	if w, ok := lq.tierWeights[tier]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+type lotteryEntrantState struct {
+	ClientId int
+	Weight   float64
+}
+
+type lotteryQueueState struct {
+	DrawTime   *time.Duration `json:",omitempty"`
+	Drawn      bool
+	Entrants   []lotteryEntrantState
+	DrawnOrder strictFifoQueueState
+}
+
+func (lq *LotteryQueue) Checkpoint(clock checkpoint.Clock) (json.RawMessage, error) {
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	s := lotteryQueueState{
+		DrawTime: clock.Offset(lq.drawTime),
+		Drawn:    lq.drawn,
+		Entrants: make([]lotteryEntrantState, 0, len(lq.entrants)),
+	}
+	for _, entrant := range lq.entrants {
+		s.Entrants = append(s.Entrants, lotteryEntrantState{ClientId: entrant.clientId, Weight: entrant.weight})
+	}
+	lq.drawnOrder.mu.Lock()
+	s.DrawnOrder = lq.drawnOrder.checkpointState(clock)
+	lq.drawnOrder.mu.Unlock()
+	return json.Marshal(s)
+}
+
+// The draw of a restored entry period happens at its original time.
+func (lq *LotteryQueue) Restore(state json.RawMessage, clock checkpoint.Clock) error {
+	var s lotteryQueueState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	lq.mu.Lock()
+	defer lq.mu.Unlock()
+	lq.drawTime = clock.Time(s.DrawTime)
+	lq.drawn = s.Drawn
+	lq.entrants = make([]lotteryEntrant, 0, len(s.Entrants))
+	lq.entrantIds = make(map[int]bool, len(s.Entrants))
+	for _, entrant := range s.Entrants {
+		lq.entrants = append(lq.entrants, lotteryEntrant{clientId: entrant.ClientId, weight: entrant.Weight})
+		lq.entrantIds[entrant.ClientId] = true
+	}
+	lq.drawnOrder.mu.Lock()
+	lq.drawnOrder.restoreState(s.DrawnOrder, clock)
+	lq.drawnOrder.mu.Unlock()
+	return nil
+}
@@ -1,26 +1,30 @@
 package impl
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 )
 
 type NoopQueue struct {
+	metrics *metrics.Recorder
+
 	totalQueuedClients int64
 	totalPollsCount    int64
 }
 
 func (nopq *NoopQueue) Add(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
+	defer nopq.metrics.BenchmarkMethod(time.Now(), "add", nil)
 	nopq.totalQueuedClients++
 }
 
 func (nopq *NoopQueue) Remove(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	defer nopq.metrics.BenchmarkMethod(time.Now(), "remove", nil)
 	nopq.totalQueuedClients--
 }
 
@@ -44,3 +48,21 @@ func (nopq *NoopQueue) Clear() {
 	nopq.totalQueuedClients = 0
 	nopq.totalPollsCount = 0
 }
+
+type noopQueueState struct {
+	TotalQueuedClients int64
+	TotalPollsCount    int64
+}
+
+func (nopq *NoopQueue) Checkpoint(_ checkpoint.Clock) (json.RawMessage, error) {
+	return json.Marshal(noopQueueState{TotalQueuedClients: nopq.totalQueuedClients, TotalPollsCount: nopq.totalPollsCount})
+}
+
+func (nopq *NoopQueue) Restore(state json.RawMessage, _ checkpoint.Clock) error {
+	var s noopQueueState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	nopq.totalQueuedClients, nopq.totalPollsCount = s.TotalQueuedClients, s.TotalPollsCount
+	return nil
+}
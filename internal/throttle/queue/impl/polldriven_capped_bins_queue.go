@@ -1,6 +1,7 @@
 package impl
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
@@ -43,6 +44,9 @@ func (ppst *pollsPerSecondTracker) CountPollingEvent() {
 }
 
 type PollDrivenCappedBinsQueue struct {
+	metrics *metrics.Recorder
+
+	ctx                  context.Context
 	startSignalWaitGroup *sync.WaitGroup
 	lock                 sync.Locker
 	pollsPerSecTracker   *pollsPerSecondTracker
@@ -65,7 +69,7 @@ type PollDrivenCappedBinsQueue struct {
 }
 
 func (polldriven *PollDrivenCappedBinsQueue) Add(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
+	defer polldriven.metrics.BenchmarkMethod(time.Now(), "add", nil)
 	polldriven.lock.Lock()
 	defer polldriven.lock.Unlock()
 
@@ -83,10 +87,13 @@ func (polldriven *PollDrivenCappedBinsQueue) Add(c client.Client) {
 	remMillisecsToPoll := relativeQueuePosition * 1000
 	remDurToPoll := time.Duration(remMillisecsToPoll) * time.Millisecond
 	c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+	c.AdviseQueuePosition(int64(polldriven.totalClients-1), time.Now().Add(remDurToPoll))
 }
 
 func (polldriven *PollDrivenCappedBinsQueue) Remove(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	defer polldriven.metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	polldriven.lock.Lock()
+	defer polldriven.lock.Unlock()
 	polldriven.totalClients--
 }
 
@@ -94,6 +101,7 @@ func (polldriven *PollDrivenCappedBinsQueue) IsCandidateToProceed(c client.Clien
 	binIdx, _ := polldriven.getUserBinIdx(c)
 
 	polldriven.totalPollsCount++
+	polldriven.adviseQueuePosition(c, int(binIdx))
 	if int(binIdx) > polldriven.workingBin {
 		return false
 	}
@@ -102,6 +110,19 @@ func (polldriven *PollDrivenCappedBinsQueue) IsCandidateToProceed(c client.Clien
 	return true
 }
 
+// Estimates admission once the working bin (advancing at most once per update interval) reaches binIdx.
+func (polldriven *PollDrivenCappedBinsQueue) adviseQueuePosition(c client.Client, binIdx int) {
+	polldriven.lock.Lock()
+	binsAhead := binIdx - polldriven.workingBin
+	polldriven.lock.Unlock()
+	if binsAhead <= 0 {
+		_ = c.AdviseQueuePosition(0, time.Now())
+		return
+	}
+	remDurToAdmission := time.Duration(binsAhead) * polldriven.workingBinUpdateInterval
+	_ = c.AdviseQueuePosition(int64(binsAhead*polldriven.binSize), time.Now().Add(remDurToAdmission))
+}
+
 func (polldriven *PollDrivenCappedBinsQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	fmt.Printf(
 		"workingBin=%d queueBin=%d lastWindowUniquePollersUtil=%.2f lastSecondRawPollingUtil=%.2f"+
@@ -122,15 +143,20 @@ func (polldriven *PollDrivenCappedBinsQueue) Clear() {
 }
 
 func (polldriven *PollDrivenCappedBinsQueue) RoutinelyUpdatePollingUtil() {
-
+	ticker := time.NewTicker(polldriven.utilUpdateInterval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-time.After(polldriven.utilUpdateInterval):
+		case <-polldriven.ctx.Done():
+			return
+		case <-ticker.C:
 			polldriven.pollingUtil = polldriven.weightedPollingUtil()
 
 			if time.Now().Sub(polldriven.workingBinUpdated) >= polldriven.workingBinUpdateInterval {
 				if polldriven.shouldUpdateWorkingBin() {
+					polldriven.lock.Lock()
 					polldriven.workingBin += 1
+					polldriven.lock.Unlock()
 					polldriven.workingBinUpdated = time.Now()
 				} else {
 					fmt.Printf(
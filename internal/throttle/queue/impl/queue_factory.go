@@ -2,11 +2,13 @@ package impl
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"sync"
 	"time"
 
 	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/queue"
 	"github.com/Shopify/goqueuesim/internal/throttle/queue/impl/redis_queue"
@@ -14,40 +16,104 @@ import (
 	"github.com/go-redis/redis/v7"
 )
 
-func MakeNoopQueue() queue.Queue {
-	return &NoopQueue{}
+func MakeNoopQueue(recorder *metrics.Recorder) queue.Queue {
+	return &NoopQueue{metrics: recorder}
 }
 
-// Returns SortedSetQueue backed by Redis Sorted Sets with fixed windowSize.
+// Returns SortedSetQueue backed by Redis Sorted Sets with initial windowSize.
 func MakeSortedSetQueue(
 	redisClient *redis.Client,
+	shopScopePrefix string,
+	windowDur time.Duration,
 	windowSize int64,
+	costs *cost.Ledger,
+	recorder *metrics.Recorder,
 ) queue.Queue {
 	ssq := &redis_queue.SortedSetQueue{
-		RedisClient:   redisClient,
-		MinWindowSize: windowSize,
-		WindowSize:    windowSize,
+		RedisClient:     redisClient,
+		ShopScopePrefix: shopScopePrefix,
+		MinWindowSize:   windowSize,
+		WindowSize:      windowSize,
+		WindowDur:       windowDur,
+		MaxCheckouts:    windowSize,
+		Costs:           costs,
+		Metrics:         recorder,
 	}
-	metrics.Gauge("ssq.window_size", float64(ssq.WindowSize), nil)
+	recorder.Gauge("ssq.window_size", float64(ssq.WindowSize), nil)
 	ssq.Clear()
 	return ssq
 }
 
 func MakeCappedBinsQueue(
+	windowDur time.Duration,
 	windowSize int64,
+	recorder *metrics.Recorder,
 ) queue.Queue {
-	metrics.Gauge("cbq.working_bin", 1, nil)
+	recorder.Gauge("cbq.working_bin", 1, nil)
 	return &CappedBinsQueue{
+		metrics:            recorder,
 		bins:               make(map[int64]map[int]bool),
 		curWindowDequeues:  make(map[int64]int64),
 		binSize:            int64(math.Ceil(float64(windowSize) * 1.50)),
+		windowDur:          windowDur,
 		queueBin:           1,
 		workingBin:         1,
 		totalQueuedClients: 0,
 	}
 }
 
+// Returns StrictFifoQueue admitting exactly the oldest waiting clients (ideal fairness baseline).
+func MakeStrictFifoQueue(
+	windowDur time.Duration,
+	maxCheckoutsPerWindow int64,
+	abandonTimeout time.Duration,
+	recorder *metrics.Recorder,
+) queue.Queue {
+	return newStrictFifoQueue(windowDur, maxCheckoutsPerWindow, abandonTimeout, recorder)
+}
+
+func newStrictFifoQueue(
+	windowDur time.Duration,
+	maxCheckoutsPerWindow int64,
+	abandonTimeout time.Duration,
+	recorder *metrics.Recorder,
+) *StrictFifoQueue {
+	fq := &StrictFifoQueue{
+		metrics:               recorder,
+		windowDur:             windowDur,
+		maxCheckoutsPerWindow: maxCheckoutsPerWindow,
+		abandonTimeout:        abandonTimeout,
+	}
+	fq.Clear()
+	return fq
+}
+
+// Returns LotteryQueue drawing its admission order (weighted by tierWeights) after entryPeriod.
+func MakeLotteryQueue(
+	ctx context.Context,
+	startSignalWaitGroup *sync.WaitGroup,
+	entryPeriod time.Duration,
+	tierWeights map[int]float64,
+	windowDur time.Duration,
+	maxCheckoutsPerWindow int64,
+	abandonTimeout time.Duration,
+	recorder *metrics.Recorder,
+) queue.Queue {
+	lq := &LotteryQueue{
+		metrics:              recorder,
+		ctx:                  ctx,
+		startSignalWaitGroup: startSignalWaitGroup,
+		entryPeriod:          entryPeriod,
+		tierWeights:          tierWeights,
+		drawnOrder:           newStrictFifoQueue(windowDur, maxCheckoutsPerWindow, abandonTimeout, recorder),
+	}
+	lq.Clear()
+	go lq.DrawAfterEntryPeriod()
+	return lq
+}
+
 func MakePollDrivenCappedBinsQueue(
+	ctx context.Context,
 	startSignalWaitGroup *sync.WaitGroup,
 	maxCheckoutsPerWindow int64,
 	windowDur time.Duration,
@@ -55,9 +121,12 @@ func MakePollDrivenCappedBinsQueue(
 	utilUpdateInterval time.Duration,
 	workingBinUpdateInterval time.Duration,
 	latestPollingUtilWeight float64,
+	recorder *metrics.Recorder,
 ) queue.Queue {
 	cps := maxCheckoutsPerWindow / int64(windowDur.Seconds())
 	sbq := &PollDrivenCappedBinsQueue{
+		metrics:              recorder,
+		ctx:                  ctx,
 		startSignalWaitGroup: startSignalWaitGroup,
 		lock:                 &sync.Mutex{},
 
@@ -81,9 +150,11 @@ func MakeIntervalBinsQueue(
 	windowDur time.Duration,
 	maxCheckoutsPerWindow int64,
 	maxUnfairMilliseconds time.Duration,
+	recorder *metrics.Recorder,
 ) queue.Queue {
-	metrics.Gauge("ibq.max_considered_bin_idx", 1, nil)
+	recorder.Gauge("ibq.max_considered_bin_idx", 1, nil)
 	ibq := &IntervalBinsQueue{
+		metrics:                  recorder,
 		ctx:                      ctx,
 		startSignalWaitGroup:     startSignalWaitGroup,
 		consideredBinLastUpdated: time.Now(),
@@ -111,6 +182,8 @@ func MakeLuaDrivenBinsQueue(
 	argsBuilder redis_queue.ArgsPreprocessor,
 	postprocessor redis_queue.LuaResultPostprocessor,
 	globalInventoryCounter *common.AtomicCounter,
+	costs *cost.Ledger,
+	recorder *metrics.Recorder,
 ) queue.Queue {
 	ldbq := &redis_queue.LuaDrivenQueue{
 		RedisClient:                           client,
@@ -123,6 +196,55 @@ func MakeLuaDrivenBinsQueue(
 		ArgsBuilder:                           argsBuilder,
 		Postprocessor:                         postprocessor,
 		GlobalInventoryCounter:                globalInventoryCounter,
+		Costs:                                 costs,
+		Metrics:                               recorder,
 	}
 	return ldbq
 }
+
+// Returns TieredQueue holding one queue (built by makeTierQueue) per distinct priority tier.
+func MakeTieredQueue(
+	tiers []int,
+	makeTierQueue func(tier int) queue.Queue,
+	policy TierAdmissionPolicy,
+	maxCheckoutsPerWindow int64,
+	recorder *metrics.Recorder,
+) queue.Queue {
+	tierQueues := make(map[int]queue.Queue)
+	distinctTiers := make([]int, 0, len(tiers))
+	for _, tier := range tiers {
+		if _, ok := tierQueues[tier]; ok {
+			continue
+		}
+		tierQueues[tier] = makeTierQueue(tier)
+		distinctTiers = append(distinctTiers, tier)
+	}
+	if len(distinctTiers) == 0 {
+		panic(fmt.Errorf("Failed instantiating TieredQueue: at least one priority tier is required"))
+	}
+	tq := &TieredQueue{
+		metrics:               recorder,
+		tiers:                 sortedTiersByPriority(distinctTiers),
+		tierQueues:            tierQueues,
+		policy:                policy,
+		maxCheckoutsPerWindow: maxCheckoutsPerWindow,
+	}
+	tq.Clear()
+	return tq
+}
+
+// Returns innerQueue with its throttle cookie contents signed & verified on every poll.
+func MakeSignedCookieQueue(
+	innerQueue queue.Queue,
+	secret string,
+	maxCookieAge time.Duration,
+	recorder *metrics.Recorder,
+) queue.Queue {
+	return &SignedCookieQueue{
+		Queue:             innerQueue,
+		secret:            []byte(secret),
+		maxCookieAge:      maxCookieAge,
+		metrics:           recorder,
+		latestVerifiedSeq: make(map[int]int64),
+	}
+}
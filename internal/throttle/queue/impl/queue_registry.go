@@ -0,0 +1,237 @@
+package impl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
+	"github.com/Shopify/goqueuesim/internal/lua_config"
+	"github.com/Shopify/goqueuesim/internal/lua_config/lua_queue"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/registry"
+	"github.com/Shopify/goqueuesim/internal/throttle/queue"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// QueueParams are handed to the constructor of every queue type.
+type QueueParams struct {
+	Ctx                  context.Context
+	StartSignalWaitGroup *sync.WaitGroup
+
+	// Redis shared by every shop (nil unless the queue type requires it) & the key prefix scoping this queue.
+	RedisClient *redis.Client
+	ScopePrefix string
+
+	WindowDuration        time.Duration
+	MaxCheckoutsPerWindow int64
+
+	GlobalInventoryCounter *common.AtomicCounter
+	Costs                  *cost.Ledger
+	Metrics                *metrics.Recorder
+
+	// Options of the queue type, resolved against its schema.
+	Options registry.Options
+}
+
+// QueueType is a registered implementation of queue.Queue.
+type QueueType struct {
+	registry.Plugin
+	RequiresRedis bool
+	// Whether the queue refreshes the position & ETA of queued clients on every poll (required by client types
+	// acting upon them).
+	EstimatesAdmission bool
+	Make               func(params QueueParams) queue.Queue
+}
+
+var queueTypes = registry.MakeRegistry("queue type")
+
+// Makes a queue type available by name (typically from an init function of the file implementing it).
+func RegisterQueueType(queueType QueueType) {
+	queueTypes.Register(queueType.Plugin, queueType)
+}
+
+func LookupQueueType(name string) (QueueType, error) {
+	_, queueType, err := queueTypes.Lookup(name)
+	if err != nil {
+		return QueueType{}, err
+	}
+	return queueType.(QueueType), nil
+}
+
+// Options given to the named queue type resolved against its schema (defaults filled in).
+func ResolveQueueOptions(name string, options registry.Options) (registry.Options, error) {
+	return queueTypes.ResolveOptions(name, options)
+}
+
+// Registered queue types, sorted by name.
+func QueueTypes() []registry.Plugin {
+	return queueTypes.Plugins()
+}
+
+var (
+	abandonTimeoutSchema = registry.DurationSchema(
+		"abandon_timeout", 30*time.Second, "Time without polling after which a queued client is deemed gone.",
+	)
+	maxTargetPollingUtilSchema = registry.FloatSchema(
+		"max_target_polling_util", 2.5, 0.001, registry.Unbounded, "Polling utilization the working bin is sized for.",
+	)
+	utilUpdateIntervalSchema = registry.DurationSchema(
+		"util_update_interval", 100*time.Millisecond, "Interval between polling utilization updates.",
+	)
+	workingBinUpdateIntervalSchema = registry.DurationSchema(
+		"working_bin_update_interval", 1*time.Second, "Interval between working bin updates.",
+	)
+	latestPollingUtilWeightSchema = registry.FloatSchema(
+		"latest_polling_util_weight", 0.2, 0, 1, "Weight of the latest sample in the polling utilization average.",
+	)
+)
+
+func init() {
+	RegisterQueueType(QueueType{
+		Plugin:             registry.Plugin{Name: "noop_queue", Description: "Admits every client as soon as it polls."},
+		EstimatesAdmission: true,
+		Make:               func(p QueueParams) queue.Queue { return MakeNoopQueue(p.Metrics) },
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "sorted_set",
+			Description: "Orders clients by arrival in a Redis sorted set, admitting a window's worth at a time.",
+		},
+		RequiresRedis:      true,
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakeSortedSetQueue(
+				p.RedisClient, p.ScopePrefix, p.WindowDuration, p.MaxCheckoutsPerWindow, p.Costs, p.Metrics,
+			)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "capped_bins_queue",
+			Description: "Assigns clients to bins of 1.5 windows' worth of checkouts, admitting bin after bin.",
+		},
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakeCappedBinsQueue(p.WindowDuration, p.MaxCheckoutsPerWindow, p.Metrics)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "strict_fifo_queue",
+			Description: "Admits exactly the oldest waiting clients (ideal fairness baseline).",
+			Options:     []registry.OptionSchema{abandonTimeoutSchema},
+		},
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakeStrictFifoQueue(
+				p.WindowDuration, p.MaxCheckoutsPerWindow, p.Options.Duration("abandon_timeout"), p.Metrics,
+			)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "lottery_queue",
+			Description: "Draws the admission order of clients entered during the entry period at random.",
+			Options: []registry.OptionSchema{
+				registry.DurationSchema("entry_period", 20*time.Second, "Period clients may enter before the draw."),
+				registry.TierWeightsSchema("tier_weights", "Relative odds per priority tier (tiers missing default to 1)."),
+				abandonTimeoutSchema,
+			},
+		},
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakeLotteryQueue(
+				p.Ctx, p.StartSignalWaitGroup, p.Options.Duration("entry_period"), p.Options.TierWeights("tier_weights"),
+				p.WindowDuration, p.MaxCheckoutsPerWindow, p.Options.Duration("abandon_timeout"), p.Metrics,
+			)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "interval_bins_queue",
+			Description: "Bins clients by arrival interval, admitting as many bins as fairness allows.",
+			Options: []registry.OptionSchema{
+				registry.DurationSchema("max_unfairness", 2*time.Second, "Arrival gap within which admissions may be out of order."),
+			},
+		},
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakeIntervalBinsQueue(
+				p.Ctx, p.StartSignalWaitGroup, p.WindowDuration, p.MaxCheckoutsPerWindow,
+				p.Options.Duration("max_unfairness"), p.Metrics,
+			)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "polldriven_capped_bins_queue",
+			Description: "Capped bins sized after the polling utilization observed.",
+			Options: []registry.OptionSchema{
+				maxTargetPollingUtilSchema,
+				utilUpdateIntervalSchema,
+				workingBinUpdateIntervalSchema,
+				latestPollingUtilWeightSchema,
+			},
+		},
+		EstimatesAdmission: true,
+		Make: func(p QueueParams) queue.Queue {
+			return MakePollDrivenCappedBinsQueue(
+				p.Ctx,
+				p.StartSignalWaitGroup,
+				p.MaxCheckoutsPerWindow,
+				p.WindowDuration,
+				p.Options.Float("max_target_polling_util"),
+				p.Options.Duration("util_update_interval"),
+				p.Options.Duration("working_bin_update_interval"),
+				p.Options.Float("latest_polling_util_weight"),
+				p.Metrics,
+			)
+		},
+	})
+	RegisterQueueType(QueueType{
+		Plugin: registry.Plugin{
+			Name:        "lua_driven_bins_queue",
+			Description: "Bins queue whose every action is a Lua script run in Redis.",
+			Options: []registry.OptionSchema{
+				registry.StringSchema("lua_queue_dir", "redis-lua/bins-queue/noop", "Dir with the action scripts."),
+				maxTargetPollingUtilSchema,
+				utilUpdateIntervalSchema,
+				workingBinUpdateIntervalSchema,
+				latestPollingUtilWeightSchema,
+			},
+		},
+		RequiresRedis: true,
+		Make:          makeLuaDrivenBinsQueueFromParams,
+	})
+}
+
+func makeLuaDrivenBinsQueueFromParams(p QueueParams) queue.Queue {
+	luaQueueParams := lua_config.ConfigureRedisLua(p.Options.String("lua_queue_dir"), p.RedisClient, lua_queue.LuaQueueConstants{
+		QueueType:                    "lua_driven_bins_queue",
+		ShopScopePrefix:              p.ScopePrefix,
+		MaxCheckoutsAllowedPerWindow: p.MaxCheckoutsPerWindow,
+		WindowDuration:               p.WindowDuration,
+
+		PollDrivenMaxTargetPollingUtil:     p.Options.Float("max_target_polling_util"),
+		PollDrivenUtilUpdateInterval:       p.Options.Duration("util_update_interval"),
+		PollDrivenWorkingBinUpdateInterval: p.Options.Duration("working_bin_update_interval"),
+		PollDrivenLatestPollingUtilWeight:  p.Options.Float("latest_polling_util_weight"),
+	})
+	return MakeLuaDrivenBinsQueue(
+		p.RedisClient,
+		luaQueueParams.LuaMethodToShaMap,
+		luaQueueParams.ShopScopePrefix,
+		luaQueueParams.LuaMethodToShopScopePrefixedKeys,
+		luaQueueParams.LuaMethodToClientScopeNonPrefixedKeys,
+		luaQueueParams.LuaMethodToConstantArgsMap,
+		luaQueueParams.KeysBuilder,
+		luaQueueParams.ArgsBuilder,
+		luaQueueParams.Postprocessor,
+		p.GlobalInventoryCounter,
+		p.Costs,
+		p.Metrics,
+	)
+}
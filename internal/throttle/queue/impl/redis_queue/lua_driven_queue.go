@@ -7,6 +7,7 @@ import (
 
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 
@@ -38,6 +39,10 @@ type LuaDrivenQueue struct {
 	// TODO: enforce this in our Lua scripts to ensure atomicity via Redis.
 	GlobalInventoryCounter *common.AtomicCounter
 
+	// Charged for every script evaluated.
+	Costs   *cost.Ledger
+	Metrics *metrics.Recorder
+
 	// Only to track & print local state.
 	totalPollsCount int64
 }
@@ -50,17 +55,17 @@ func (ldq *LuaDrivenQueue) Add(c client.Client) {
 		return
 	}
 	methodKey := "add"
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), "add", nil)
 
 	redisLuaSha := ldq.MethodToLuaShaMap[methodKey]
 
-	clientScopedKeys := ldq.withClientScopedKeys(methodKey, string(c.ID()))
+	clientScopedKeys := ldq.withClientScopedKeys(methodKey, strconv.Itoa(c.ID()))
 	keys := ldq.KeysBuilder.PreprocessKeys(methodKey, clientScopedKeys)
 
 	constantArgs := ldq.MethodToConstantArgsMap[methodKey]
 	args := ldq.ArgsBuilder.PreprocessArgs(methodKey, constantArgs)
 
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, args)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, args)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple, C: c}
 	ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams)
@@ -77,17 +82,17 @@ func (ldq *LuaDrivenQueue) Remove(c client.Client) {
 	}
 
 	methodKey := "remove"
-	defer metrics.BenchmarkMethod(time.Now(), methodKey, nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), methodKey, nil)
 
 	redisLuaSha := ldq.MethodToLuaShaMap[methodKey]
 
-	clientScopedKeys := ldq.withClientScopedKeys(methodKey, string(c.ID()))
+	clientScopedKeys := ldq.withClientScopedKeys(methodKey, strconv.Itoa(c.ID()))
 	keys := ldq.KeysBuilder.PreprocessKeys(methodKey, clientScopedKeys)
 
 	argsWithClientData := ldq.argsWithClientData(methodKey, c)
 	args := ldq.ArgsBuilder.PreprocessArgs(methodKey, argsWithClientData)
 
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, args)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, args)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple, C: c}
 	ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams)
@@ -103,17 +108,17 @@ func (ldq *LuaDrivenQueue) IsCandidateToProceed(c client.Client) bool {
 	}
 
 	methodKey := "poll"
-	defer metrics.BenchmarkMethod(time.Now(), methodKey, nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), methodKey, nil)
 
 	redisLuaSha := ldq.MethodToLuaShaMap[methodKey]
 
-	clientScopedKeys := ldq.withClientScopedKeys(methodKey, string(c.ID()))
+	clientScopedKeys := ldq.withClientScopedKeys(methodKey, strconv.Itoa(c.ID()))
 	keys := ldq.KeysBuilder.PreprocessKeys(methodKey, clientScopedKeys)
 
 	argsWithClientData := ldq.argsWithClientData(methodKey, c)
 	args := ldq.ArgsBuilder.PreprocessArgs(methodKey, argsWithClientData)
 
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, args)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, args)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple, C: c}
 	isCandidateToProceed := ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams).PollResult
@@ -122,7 +127,7 @@ func (ldq *LuaDrivenQueue) IsCandidateToProceed(c client.Client) bool {
 
 func (ldq *LuaDrivenQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	methodKey := "receive_feedback"
-	defer metrics.BenchmarkMethod(time.Now(), methodKey, nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), methodKey, nil)
 	fmt.Printf(
 		"checkoutUtil=%.2f pollingUtil=%.2f queuedClients=%d totalPolls=%d\n",
 		feedback.CheckoutUtil, feedback.PollingUtil, ldq.Size(), ldq.totalPollsCount,
@@ -138,7 +143,7 @@ func (ldq *LuaDrivenQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	feedbackArgs := append(ldq.MethodToConstantArgsMap[methodKey], checkoutUtilStr, pollingUtilStr)
 	args := ldq.ArgsBuilder.PreprocessArgs(methodKey, feedbackArgs)
 
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, args)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, args)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple, feedback: feedback}
 	ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams)
@@ -146,7 +151,7 @@ func (ldq *LuaDrivenQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 
 func (ldq *LuaDrivenQueue) Size() int64 {
 	methodKey := "size"
-	defer metrics.BenchmarkMethod(time.Now(), methodKey, nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), methodKey, nil)
 
 	redisLuaSha := ldq.MethodToLuaShaMap[methodKey]
 
@@ -156,7 +161,7 @@ func (ldq *LuaDrivenQueue) Size() int64 {
 	constantArgs := ldq.MethodToConstantArgsMap[methodKey]
 	args := ldq.ArgsBuilder.PreprocessArgs(methodKey, constantArgs)
 
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, args)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, args)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple}
 	totalClients := ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams).SizeResult
@@ -169,7 +174,7 @@ func (ldq *LuaDrivenQueue) Clear() {
 	defer ldq.GlobalInventoryCounter.Unlock()
 
 	methodKey := "clear"
-	defer metrics.BenchmarkMethod(time.Now(), methodKey, nil)
+	defer ldq.Metrics.BenchmarkMethod(time.Now(), methodKey, nil)
 
 	redisLuaSha := ldq.MethodToLuaShaMap[methodKey]
 
@@ -183,7 +188,7 @@ func (ldq *LuaDrivenQueue) Clear() {
 	argsWithKeysCount := append(args, numKeysToClear)
 
 	ldq.totalPollsCount = 0
-	resultTuple := ldq.redisLuaResultOrDie(redisLuaSha, keys, argsWithKeysCount)
+	resultTuple := ldq.redisLuaResultOrDie(methodKey, redisLuaSha, keys, argsWithKeysCount)
 
 	resultParams := PostprocessParams{ResultTuple: resultTuple}
 	ldq.Postprocessor.PostprocessLuaResult(methodKey, resultParams)
@@ -192,7 +197,8 @@ func (ldq *LuaDrivenQueue) Clear() {
 	//fmt.Printf("%s", testVal)
 }
 
-func (ldq *LuaDrivenQueue) redisLuaResultOrDie(luaSha1 string, keys []string, args []string) []interface{} {
+func (ldq *LuaDrivenQueue) redisLuaResultOrDie(methodKey string, luaSha1 string, keys []string, args []string) []interface{} {
+	ldq.Costs.Charge(cost.RedisEvalSha, methodKey)
 	var result interface{}
 	var err error
 	if result, err = ldq.RedisClient.EvalSha(luaSha1, keys, args).Result(); err != nil {
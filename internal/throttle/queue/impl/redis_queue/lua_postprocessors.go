@@ -44,6 +44,7 @@ func (p *DefaultLuaResultPostprocessor) PostprocessLuaResult(
 		c.SetThrottleCookieVal(LuaUserBinKey, strconv.FormatInt(binIdx, 10))
 		c.SetThrottleCookieVal(LuaUserPosKey, strconv.FormatInt(queuePos, 10))
 		c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+		c.AdviseQueuePosition(queuePos, time.Now().Add(remDurToPoll))
 
 		log.Debug().Msg(fmt.Sprintf(resultTuple[1].(string)))
 		return PostprocessResult{}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/cost"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 
@@ -15,14 +16,24 @@ import (
 
 // TODO: How do we handle expiry? What about shrinking the window from the left?
 type SortedSetQueue struct {
-	RedisClient   *redis.Client
-	MinWindowSize int64
-	WindowSize    int64
+	RedisClient     *redis.Client
+	ShopScopePrefix string
+	MinWindowSize   int64
+	WindowSize      int64
+
+	// Checkouts allowed per tracker window, the rate clients ranked beyond the window are estimated to advance at.
+	WindowDur    time.Duration
+	MaxCheckouts int64
+
+	// Charged for every Redis command issued.
+	Costs   *cost.Ledger
+	Metrics *metrics.Recorder
 }
 
 func (ssq *SortedSetQueue) Add(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "add", nil)
-	if _, err := ssq.RedisClient.ZAddNX(buildSortedSetKey(), &redis.Z{
+	defer ssq.Metrics.BenchmarkMethod(time.Now(), "add", nil)
+	ssq.Costs.Charge(cost.RedisCommand, "zaddnx")
+	if _, err := ssq.RedisClient.ZAddNX(ssq.sortedSetKey(), &redis.Z{
 		Score:  float64(c.QueueEntryTime().UnixNano()),
 		Member: strconv.Itoa(c.ID()),
 	}).Result(); err != nil {
@@ -31,9 +42,10 @@ func (ssq *SortedSetQueue) Add(c client.Client) {
 }
 
 func (ssq *SortedSetQueue) Remove(c client.Client) {
-	defer metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	defer ssq.Metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	ssq.Costs.Charge(cost.RedisCommand, "zrem")
 	if _, err := ssq.RedisClient.ZRem(
-		buildSortedSetKey(), strconv.Itoa(c.ID()),
+		ssq.sortedSetKey(), strconv.Itoa(c.ID()),
 	).Result(); err != nil {
 		panic(err)
 	}
@@ -46,16 +58,30 @@ func (ssq *SortedSetQueue) Remove(c client.Client) {
 // lesser than the window size, we should set lastEntryTime to current time so
 // that we don't skip new entries.
 func (ssq *SortedSetQueue) IsCandidateToProceed(c client.Client) bool {
+	ssq.Costs.Charge(cost.RedisCommand, "zrank")
 	clientRank, err := ssq.RedisClient.ZRank(
-		buildSortedSetKey(), strconv.Itoa(c.ID()),
+		ssq.sortedSetKey(), strconv.Itoa(c.ID()),
 	).Result()
 	if err != nil {
 		panic(err)
 	}
 
+	ssq.adviseQueuePosition(c, clientRank)
 	return clientRank < ssq.WindowSize
 }
 
+// Clients ranked within the window may proceed now, the others once those ranked ahead have checked out.
+func (ssq *SortedSetQueue) adviseQueuePosition(c client.Client, clientRank int64) {
+	clientsAheadOfWindow := clientRank - ssq.WindowSize + 1
+	if clientsAheadOfWindow <= 0 {
+		_ = c.AdviseQueuePosition(clientRank, time.Now())
+		return
+	}
+	windowsToAdmission := float64(clientsAheadOfWindow) / float64(ssq.MaxCheckouts)
+	remDurToAdmission := time.Duration(windowsToAdmission * float64(ssq.WindowDur))
+	_ = c.AdviseQueuePosition(clientRank, time.Now().Add(remDurToAdmission))
+}
+
 // Not bothering with mutex since only 1 goroutine will invoke this.
 func (ssq *SortedSetQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	if earlyExit, ok := feedback.CustomFeedback["should_ignore_poll_util"]; ok && earlyExit == true {
@@ -99,26 +125,29 @@ func (ssq *SortedSetQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
 	}
 
 	windowSizeFlt := float64(ssq.WindowSize)
-	metrics.Gauge("ssq.window_size", windowSizeFlt, nil)
-	metrics.Gauge("ssq.considered_client_util", windowSizeFlt/float64(ssq.Size()), nil)
+	ssq.Metrics.Gauge("ssq.window_size", windowSizeFlt, nil)
+	ssq.Metrics.Gauge("ssq.considered_client_util", windowSizeFlt/float64(ssq.Size()), nil)
 }
 
 func (ssq *SortedSetQueue) Clear() {
+	ssq.Costs.Charge(cost.RedisCommand, "zremrangebyrank")
 	if _, err := ssq.RedisClient.ZRemRangeByRank(
-		buildSortedSetKey(), int64(0), int64(-1),
+		ssq.sortedSetKey(), int64(0), int64(-1),
 	).Result(); err != nil {
 		panic(err)
 	}
 }
 
 func (ssq *SortedSetQueue) Size() int64 {
-	val, err := ssq.RedisClient.ZCard(buildSortedSetKey()).Result()
+	ssq.Costs.Charge(cost.RedisCommand, "zcard")
+	val, err := ssq.RedisClient.ZCard(ssq.sortedSetKey()).Result()
 	if err != nil {
 		panic(err)
 	}
 	return val
 }
 
-func buildSortedSetKey() string {
-	return "sorted_set_queue:shop_id_0"
+// Namespaced by shop so that concurrently simulated shops sharing Redis never collide.
+func (ssq *SortedSetQueue) sortedSetKey() string {
+	return ssq.ShopScopePrefix + ":sorted_set_queue"
 }
@@ -0,0 +1,155 @@
+package impl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/throttle/queue"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+)
+
+const (
+	throttleCookieSignatureKey = "throttleCookieSig"
+)
+
+// SignedCookieQueue wraps a queue trusting the throttle cookie & signs whatever the queue stores there.
+// Signatures bind the cookie contents to the client id, to when they were issued & to their issue sequence, so
+// rewritten or copied cookies fail verification, as do ones older than maxCookieAge or replayed after a later cookie
+// of the client verified (cookies whose successor got lost on its way still verify). Clients failing verification
+// have their queue state discarded & rejoin at the back of the line.
+type SignedCookieQueue struct {
+	queue.Queue
+	secret       []byte
+	maxCookieAge time.Duration
+	metrics      *metrics.Recorder
+
+	mu                  sync.Mutex
+	windowVerifications int64
+	windowRejections    int64
+	issueSeq            int64
+	latestVerifiedSeq   map[int]int64
+}
+
+func (scq *SignedCookieQueue) Add(c client.Client) {
+	scq.Queue.Add(c)
+	scq.sign(c)
+}
+
+func (scq *SignedCookieQueue) IsCandidateToProceed(c client.Client) bool {
+	if !scq.verify(c) {
+		labelTag := fmt.Sprintf("client_label:%s", c.Label())
+		scq.metrics.Incr("signed_cookie.rejected", []string{labelTag})
+		scq.Queue.Remove(c)
+		scq.discardQueueState(c)
+		scq.Add(c)
+		return false
+	}
+	isCandidate := scq.Queue.IsCandidateToProceed(c)
+	scq.sign(c)
+	return isCandidate
+}
+
+func (scq *SignedCookieQueue) ReleaseCandidate(c client.Client) {
+	queue.ReleaseCandidate(scq.Queue, c)
+}
+
+func (scq *SignedCookieQueue) DrawTime() time.Time {
+	return queue.LatestDrawTime(scq.Queue)
+}
+
+func (scq *SignedCookieQueue) Clear() {
+	scq.mu.Lock()
+	scq.latestVerifiedSeq = make(map[int]int64)
+	scq.mu.Unlock()
+	scq.Queue.Clear()
+}
+
+func (scq *SignedCookieQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
+	scq.mu.Lock()
+	fmt.Printf("signedCookies verified=%d rejected=%d\n", scq.windowVerifications, scq.windowRejections)
+	scq.metrics.Gauge("signed_cookie.window_verifications", float64(scq.windowVerifications), nil)
+	scq.metrics.Gauge("signed_cookie.window_rejections", float64(scq.windowRejections), nil)
+	scq.windowVerifications, scq.windowRejections = 0, 0
+	scq.mu.Unlock()
+	scq.Queue.ReceiveTrackerFeedback(feedback)
+}
+
+func (scq *SignedCookieQueue) sign(c client.Client) {
+	defer scq.metrics.BenchmarkMethod(time.Now(), "sign_cookie", nil)
+	scq.mu.Lock()
+	scq.issueSeq++
+	seq := strconv.FormatInt(scq.issueSeq, 10)
+	scq.mu.Unlock()
+	issuedAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+	_ = c.SetThrottleCookieVal(throttleCookieSignatureKey, seq+"."+issuedAt+"."+scq.mac(c, seq, issuedAt))
+}
+
+func (scq *SignedCookieQueue) verify(c client.Client) bool {
+	defer scq.metrics.BenchmarkMethod(time.Now(), "verify_cookie", nil)
+	valid, seq := false, int64(0)
+	if signature, ok := c.GetThrottleCookieVal(throttleCookieSignatureKey); ok {
+		if parts := strings.SplitN(signature, ".", 3); len(parts) == 3 {
+			var seqErr error
+			seq, seqErr = strconv.ParseInt(parts[0], 10, 64)
+			issuedAtNanos, err := strconv.ParseInt(parts[1], 10, 64)
+			fresh := seqErr == nil && err == nil && time.Since(time.Unix(0, issuedAtNanos)) <= scq.maxCookieAge
+			valid = fresh && hmac.Equal([]byte(parts[2]), []byte(scq.mac(c, parts[0], parts[1])))
+		}
+	}
+	scq.mu.Lock()
+	scq.windowVerifications++
+	if valid && seq < scq.latestVerifiedSeq[c.ID()] {
+		valid = false
+	}
+	if valid {
+		scq.latestVerifiedSeq[c.ID()] = seq
+	} else {
+		scq.windowRejections++
+	}
+	scq.mu.Unlock()
+	return valid
+}
+
+// HMAC over client id, issue sequence & time & every queue-issued cookie value (in key order).
+func (scq *SignedCookieQueue) mac(c client.Client, seq string, issuedAt string) string {
+	values := scq.queueCookieVals(c)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := hmac.New(sha256.New, scq.secret)
+	fmt.Fprintf(h, "%d|%s|%s", c.ID(), seq, issuedAt)
+	for _, key := range keys {
+		fmt.Fprintf(h, "|%s=%s", key, values[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (scq *SignedCookieQueue) discardQueueState(c client.Client) {
+	for key := range scq.queueCookieVals(c) {
+		_ = c.RemoveThrottleCookieVal(key)
+	}
+	_ = c.RemoveThrottleCookieVal(throttleCookieSignatureKey)
+}
+
+// Cookie values owned by the wrapped queue (the throttle state & signature excluded).
+func (scq *SignedCookieQueue) queueCookieVals(c client.Client) map[string]string {
+	values := make(map[string]string)
+	for key, value := range c.SessionData().ThrottleCookie {
+		if key != client.ThrottleStateKey && key != throttleCookieSignatureKey {
+			values[key] = value
+		}
+	}
+	return values
+}
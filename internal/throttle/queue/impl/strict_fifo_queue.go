@@ -0,0 +1,301 @@
+package impl
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+)
+
+type fifoEntry struct {
+	clientId int
+	lastSeen time.Time
+
+	// Increases from the front of the line to its back (see ticketCounts).
+	ticket int
+
+	// Clients advised to poll later are not deemed gone before then.
+	advisedPollAfter time.Time
+}
+
+// Whether the client has neither polled nor been expected to poll for longer than abandonTimeout.
+func (e *fifoEntry) isAbandoned(now time.Time, abandonTimeout time.Duration) bool {
+	lastExpected := e.lastSeen
+	if e.advisedPollAfter.After(lastExpected) {
+		lastExpected = e.advisedPollAfter
+	}
+	return now.Sub(lastExpected) > abandonTimeout
+}
+
+// StrictFifoQueue is an exact (non-approximate) first-come-first-served queue serving as fairness baseline:
+// only the oldest admissionWindowSize waiting clients are candidates to proceed.
+type StrictFifoQueue struct {
+	metrics *metrics.Recorder
+
+	mu sync.Mutex
+
+	// Waiting clients ordered from oldest to newest.
+	waiting *list.List
+	entries map[int]*list.Element
+
+	// Fenwick tree counting waiting clients by ticket, so that positions are counted in O(log n) per poll.
+	ticketCounts []int64
+	nextTicket   int
+
+	windowDur             time.Duration
+	maxCheckoutsPerWindow int64
+
+	// Number of oldest waiting clients allowed to race into the rate tracker (adjusted by tracker feedback).
+	admissionWindowSize int64
+
+	// Clients who have not polled for this long are deemed gone and stop holding their place.
+	abandonTimeout time.Duration
+
+	totalPollsCount int64
+}
+
+func (fq *StrictFifoQueue) Add(c client.Client) {
+	defer fq.metrics.BenchmarkMethod(time.Now(), "add", nil)
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	queuePos := fq.pushBack(c.ID())
+	fq.advisePollAfter(c, queuePos)
+}
+
+func (fq *StrictFifoQueue) Remove(c client.Client) {
+	defer fq.metrics.BenchmarkMethod(time.Now(), "remove", nil)
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	if e, ok := fq.entries[c.ID()]; ok {
+		fq.remove(e)
+	}
+}
+
+func (fq *StrictFifoQueue) Size() int64 {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return int64(fq.waiting.Len())
+}
+
+func (fq *StrictFifoQueue) IsCandidateToProceed(c client.Client) bool {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	fq.totalPollsCount++
+	e, ok := fq.entries[c.ID()]
+	if !ok {
+		// Evicted as abandoned yet still polling => back of the line (as with an expired session).
+		fq.advisePollAfter(c, fq.pushBack(c.ID()))
+		return false
+	}
+	entry := e.Value.(*fifoEntry)
+	entry.lastSeen = time.Now()
+
+	queuePos := fq.queuePosition(entry)
+	if queuePos < fq.admissionWindowSize {
+		_ = c.AdviseQueuePosition(queuePos, time.Now())
+		return true
+	}
+	fq.advisePollAfter(c, queuePos)
+	return false
+}
+
+// Received once per window => widen admissions while checkout capacity goes unused, else narrow back.
+func (fq *StrictFifoQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	fq.evictAbandoned()
+	fmt.Printf(
+		"admissionWindowSize=%d checkoutUtil=%.2f queuedClients=%d totalPolls=%d\n",
+		fq.admissionWindowSize, feedback.CheckoutUtil, fq.waiting.Len(), fq.totalPollsCount,
+	)
+	fq.metrics.Gauge("fifo.admission_window_size", float64(fq.admissionWindowSize), nil)
+
+	if earlyExit, ok := feedback.CustomFeedback["should_ignore_poll_util"]; ok && earlyExit == true {
+		return
+	}
+	if feedback.CheckoutUtil < 1.0 {
+		unusedCheckouts := math.Ceil((1.0 - feedback.CheckoutUtil) * float64(fq.maxCheckoutsPerWindow))
+		fq.admissionWindowSize += int64(unusedCheckouts)
+		if maxUseful := int64(fq.waiting.Len()); fq.admissionWindowSize > maxUseful {
+			fq.admissionWindowSize = maxUseful
+		}
+	} else {
+		fq.admissionWindowSize -= (fq.admissionWindowSize - fq.maxCheckoutsPerWindow) / 2
+	}
+	if fq.admissionWindowSize < fq.maxCheckoutsPerWindow {
+		fq.admissionWindowSize = fq.maxCheckoutsPerWindow
+	}
+}
+
+func (fq *StrictFifoQueue) Clear() {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.resetWaiting()
+	fq.admissionWindowSize = fq.maxCheckoutsPerWindow
+	fq.totalPollsCount = 0
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) resetWaiting() {
+	fq.waiting = list.New()
+	fq.entries = make(map[int]*list.Element)
+	fq.ticketCounts = make([]int64, 1)
+	fq.nextTicket = 1
+}
+
+// Enqueues clientId behind every waiting client (lock required) & returns its queue position.
+func (fq *StrictFifoQueue) pushBack(clientId int) int64 {
+	return fq.pushBackEntry(&fifoEntry{clientId: clientId, lastSeen: time.Now()})
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) pushBackEntry(entry *fifoEntry) int64 {
+	if e, ok := fq.entries[entry.clientId]; ok {
+		fq.remove(e)
+	}
+	if fq.nextTicket >= len(fq.ticketCounts) {
+		fq.renumberTickets()
+	}
+	entry.ticket = fq.nextTicket
+	fq.nextTicket++
+	fq.countTicket(entry.ticket, 1)
+	fq.entries[entry.clientId] = fq.waiting.PushBack(entry)
+	return int64(fq.waiting.Len() - 1)
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) remove(e *list.Element) {
+	entry := e.Value.(*fifoEntry)
+	fq.countTicket(entry.ticket, -1)
+	fq.waiting.Remove(e)
+	delete(fq.entries, entry.clientId)
+}
+
+// Returns the number of clients ahead of entry (lock required).
+func (fq *StrictFifoQueue) queuePosition(entry *fifoEntry) int64 {
+	pos := int64(0)
+	for ticket := entry.ticket - 1; ticket > 0; ticket -= ticket & -ticket {
+		pos += fq.ticketCounts[ticket]
+	}
+	return pos
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) countTicket(ticket int, delta int64) {
+	for ; ticket < len(fq.ticketCounts); ticket += ticket & -ticket {
+		fq.ticketCounts[ticket] += delta
+	}
+}
+
+// Tickets run out => waiting clients are ticketed afresh from 1, leaving room for as many newcomers (lock required).
+func (fq *StrictFifoQueue) renumberTickets() {
+	fq.ticketCounts = make([]int64, 2*fq.waiting.Len()+64)
+	fq.nextTicket = 1
+	for e := fq.waiting.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*fifoEntry)
+		entry.ticket = fq.nextTicket
+		fq.nextTicket++
+		fq.countTicket(entry.ticket, 1)
+	}
+}
+
+// Clients deemed gone stop holding their place (lock required).
+func (fq *StrictFifoQueue) evictAbandoned() {
+	now := time.Now()
+	for e := fq.waiting.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*fifoEntry).isAbandoned(now, fq.abandonTimeout) {
+			fq.remove(e)
+		}
+		e = next
+	}
+}
+
+// Advises polling (& estimates admission) once enough clients ahead should have been admitted
+// at the max checkout rate.
+func (fq *StrictFifoQueue) advisePollAfter(c client.Client, queuePos int64) {
+	clientsAheadOfWindow := queuePos - fq.admissionWindowSize + 1
+	if clientsAheadOfWindow <= 0 {
+		_ = c.AdviseQueuePosition(queuePos, time.Now())
+		return
+	}
+	relativeQueuePosition := float64(clientsAheadOfWindow) / float64(fq.maxCheckoutsPerWindow)
+	remMillisecsToPoll := relativeQueuePosition * float64(fq.windowDur.Milliseconds())
+	remDurToPoll := time.Duration(remMillisecsToPoll) * time.Millisecond
+	fq.entries[c.ID()].Value.(*fifoEntry).advisedPollAfter = time.Now().Add(remDurToPoll)
+	_ = c.AdvisePollAfter(time.Now().Add(remDurToPoll))
+	_ = c.AdviseQueuePosition(queuePos, time.Now().Add(remDurToPoll))
+}
+
+type fifoEntryState struct {
+	ClientId         int
+	LastSeen         *time.Duration `json:",omitempty"`
+	AdvisedPollAfter *time.Duration `json:",omitempty"`
+}
+
+type strictFifoQueueState struct {
+	// Waiting clients ordered from oldest to newest.
+	Waiting             []fifoEntryState
+	AdmissionWindowSize int64
+	TotalPollsCount     int64
+}
+
+func (fq *StrictFifoQueue) Checkpoint(clock checkpoint.Clock) (json.RawMessage, error) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return json.Marshal(fq.checkpointState(clock))
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) checkpointState(clock checkpoint.Clock) strictFifoQueueState {
+	s := strictFifoQueueState{
+		Waiting:             make([]fifoEntryState, 0, fq.waiting.Len()),
+		AdmissionWindowSize: fq.admissionWindowSize,
+		TotalPollsCount:     fq.totalPollsCount,
+	}
+	for e := fq.waiting.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*fifoEntry)
+		s.Waiting = append(s.Waiting, fifoEntryState{
+			ClientId:         entry.clientId,
+			LastSeen:         clock.Offset(entry.lastSeen),
+			AdvisedPollAfter: clock.Offset(entry.advisedPollAfter),
+		})
+	}
+	return s
+}
+
+func (fq *StrictFifoQueue) Restore(state json.RawMessage, clock checkpoint.Clock) error {
+	var s strictFifoQueueState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.restoreState(s, clock)
+	return nil
+}
+
+// Lock required.
+func (fq *StrictFifoQueue) restoreState(s strictFifoQueueState, clock checkpoint.Clock) {
+	fq.resetWaiting()
+	for _, entry := range s.Waiting {
+		fq.pushBackEntry(&fifoEntry{
+			clientId:         entry.ClientId,
+			lastSeen:         clock.Time(entry.LastSeen),
+			advisedPollAfter: clock.Time(entry.AdvisedPollAfter),
+		})
+	}
+	fq.admissionWindowSize = s.AdmissionWindowSize
+	fq.totalPollsCount = s.TotalPollsCount
+}
@@ -0,0 +1,181 @@
+package impl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// fakeClient only implements what queues call upon polls (any other call panics).
+type fakeClient struct {
+	client.Client
+	id int
+}
+
+func (c *fakeClient) ID() int                                            { return c.id }
+func (c *fakeClient) AdvisePollAfter(t time.Time) error                  { return nil }
+func (c *fakeClient) AdviseQueuePosition(pos int64, est time.Time) error { return nil }
+
+func makeTestFifoQueue(maxCheckoutsPerWindow int64) *StrictFifoQueue {
+	return newStrictFifoQueue(time.Second, maxCheckoutsPerWindow, time.Minute, nil)
+}
+
+func addClients(fq *StrictFifoQueue, ids ...int) {
+	for _, id := range ids {
+		fq.Add(&fakeClient{id: id})
+	}
+}
+
+// Checks the queue position of every waiting client against the expected order (oldest first).
+func assertOrder(t *testing.T, fq *StrictFifoQueue, want []int) {
+	t.Helper()
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	if fq.waiting.Len() != len(want) {
+		t.Fatalf("got %d waiting clients, want %d", fq.waiting.Len(), len(want))
+	}
+	for wantPos, id := range want {
+		e, ok := fq.entries[id]
+		if !ok {
+			t.Fatalf("client %d is not waiting", id)
+		}
+		if pos := fq.queuePosition(e.Value.(*fifoEntry)); pos != int64(wantPos) {
+			t.Errorf("client %d: got position %d, want %d", id, pos, wantPos)
+		}
+	}
+}
+
+func TestStrictFifoQueuePositions(t *testing.T) {
+	fq := makeTestFifoQueue(2)
+	addClients(fq, 1, 2, 3, 4, 5)
+	assertOrder(t, fq, []int{1, 2, 3, 4, 5})
+
+	fq.Remove(&fakeClient{id: 1})
+	fq.Remove(&fakeClient{id: 4})
+	assertOrder(t, fq, []int{2, 3, 5})
+
+	// Re-adding a waiting client sends it to the back of the line.
+	addClients(fq, 2)
+	assertOrder(t, fq, []int{3, 5, 2})
+
+	// Removing a client which is not waiting is a no-op.
+	fq.Remove(&fakeClient{id: 42})
+	assertOrder(t, fq, []int{3, 5, 2})
+}
+
+func TestStrictFifoQueueCandidates(t *testing.T) {
+	fq := makeTestFifoQueue(2)
+	addClients(fq, 1, 2, 3)
+
+	tests := []struct {
+		id   int
+		want bool
+	}{
+		{1, true},
+		{2, true},
+		{3, false},
+	}
+	for _, tt := range tests {
+		if got := fq.IsCandidateToProceed(&fakeClient{id: tt.id}); got != tt.want {
+			t.Errorf("client %d: got candidate %t, want %t", tt.id, got, tt.want)
+		}
+	}
+
+	fq.Remove(&fakeClient{id: 1})
+	if !fq.IsCandidateToProceed(&fakeClient{id: 3}) {
+		t.Errorf("client 3 should become a candidate once client 1 left")
+	}
+}
+
+func TestStrictFifoQueueEviction(t *testing.T) {
+	fq := makeTestFifoQueue(1)
+	addClients(fq, 1, 2, 3, 4)
+
+	fq.mu.Lock()
+	longAgo := time.Now().Add(-time.Hour)
+	for _, id := range []int{1, 3} {
+		entry := fq.entries[id].Value.(*fifoEntry)
+		entry.lastSeen = longAgo
+		entry.advisedPollAfter = longAgo
+	}
+	// Advised to poll later => still expected back.
+	fq.entries[4].Value.(*fifoEntry).lastSeen = longAgo
+	fq.entries[4].Value.(*fifoEntry).advisedPollAfter = time.Now().Add(time.Hour)
+	fq.evictAbandoned()
+	fq.mu.Unlock()
+	assertOrder(t, fq, []int{2, 4})
+
+	// An evicted client polling again rejoins at the back of the line.
+	if fq.IsCandidateToProceed(&fakeClient{id: 1}) {
+		t.Errorf("evicted client 1 should not be a candidate")
+	}
+	assertOrder(t, fq, []int{2, 4, 1})
+}
+
+func TestStrictFifoQueueRenumberTickets(t *testing.T) {
+	fq := makeTestFifoQueue(1)
+	var want []int
+	// Churn through enough clients to run out of tickets several times over.
+	for id := 0; id < 500; id++ {
+		addClients(fq, id)
+		want = append(want, id)
+		if id%3 != 0 {
+			fq.Remove(&fakeClient{id: want[0]})
+			want = want[1:]
+		}
+	}
+	assertOrder(t, fq, want)
+
+	fq.mu.Lock()
+	fq.renumberTickets()
+	fq.mu.Unlock()
+	assertOrder(t, fq, want)
+
+	addClients(fq, 1000)
+	assertOrder(t, fq, append(want, 1000))
+}
+
+func TestStrictFifoQueueCheckpointRoundTrip(t *testing.T) {
+	fq := makeTestFifoQueue(2)
+	addClients(fq, 7, 3, 9, 1, 5)
+	fq.Remove(&fakeClient{id: 9})
+	fq.IsCandidateToProceed(&fakeClient{id: 7})
+
+	clock := checkpoint.Clock{Epoch: time.Now().Add(-time.Minute)}
+	state, err := fq.Checkpoint(clock)
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	restored := makeTestFifoQueue(2)
+	addClients(restored, 42)
+	resumedClock := checkpoint.Clock{Epoch: time.Now()}
+	if err := restored.Restore(state, resumedClock); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	assertOrder(t, restored, []int{7, 3, 1, 5})
+
+	if restored.admissionWindowSize != fq.admissionWindowSize || restored.totalPollsCount != fq.totalPollsCount {
+		t.Errorf(
+			"got admissionWindowSize=%d totalPolls=%d, want %d & %d",
+			restored.admissionWindowSize, restored.totalPollsCount, fq.admissionWindowSize, fq.totalPollsCount,
+		)
+	}
+	// Timestamps keep their offset on the simulation clock.
+	origSeen := fq.entries[7].Value.(*fifoEntry).lastSeen.Sub(clock.Epoch)
+	restoredSeen := restored.entries[7].Value.(*fifoEntry).lastSeen.Sub(resumedClock.Epoch)
+	if origSeen != restoredSeen {
+		t.Errorf("got lastSeen offset %s, want %s", restoredSeen, origSeen)
+	}
+
+	// Resaving the restored queue yields the same checkpoint.
+	resaved, err := restored.Checkpoint(resumedClock)
+	if err != nil {
+		t.Fatalf("checkpoint restored queue: %v", err)
+	}
+	if string(resaved) != string(state) {
+		t.Errorf("got checkpoint %s after restore, want %s", resaved, state)
+	}
+}
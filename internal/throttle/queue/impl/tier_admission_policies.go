@@ -0,0 +1,86 @@
+package impl
+
+import "math"
+
+// TierStats is the per-window bookkeeping a TierAdmissionPolicy decides upon.
+type TierStats struct {
+	// Tiers ordered from highest to lowest priority.
+	Tiers                 []int
+	ActiveTiers           map[int]bool
+	ReadyTiers            map[int]bool
+	WindowAdmissions      map[int]int64
+	MaxCheckoutsPerWindow int64
+}
+
+// TierAdmissionPolicy decides whether a candidate from some tier may be admitted this window.
+type TierAdmissionPolicy interface {
+	Admits(tier int, stats TierStats) bool
+}
+
+// StrictPriorityPolicy only admits a tier while no higher-priority tier has candidates ready to proceed.
+// Higher tiers whose queued clients are all still waiting for their turn do not hold back lower tiers.
+type StrictPriorityPolicy struct{}
+
+func (p *StrictPriorityPolicy) Admits(tier int, stats TierStats) bool {
+	for _, t := range stats.Tiers {
+		if t == tier {
+			return true
+		}
+		if stats.ReadyTiers[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// WeightedSharePolicy splits each window's checkouts among active tiers proportionally to their weight.
+type WeightedSharePolicy struct {
+	Weights map[int]float64
+}
+
+func (p *WeightedSharePolicy) Admits(tier int, stats TierStats) bool {
+	activeWeights := 0.0
+	for _, t := range stats.Tiers {
+		if stats.ActiveTiers[t] || t == tier {
+			activeWeights += p.weight(t)
+		}
+	}
+	if activeWeights <= 0 {
+		return true
+	}
+	share := p.weight(tier) / activeWeights
+	budget := int64(math.Ceil(share * float64(stats.MaxCheckoutsPerWindow)))
+	return stats.WindowAdmissions[tier] < budget
+}
+
+// Tiers missing from Weights default to a weight of 1.
+func (p *WeightedSharePolicy) weight(tier int) float64 {
+	if w, ok := p.Weights[tier]; ok {
+		return w
+	}
+	return 1
+}
+
+// ReservedCapacityPolicy guarantees each tier some checkouts per window and shares the remainder.
+// Reservations of tiers which are not currently competing are released to the shared pool.
+type ReservedCapacityPolicy struct {
+	ReservedCheckouts map[int]int64
+}
+
+func (p *ReservedCapacityPolicy) Admits(tier int, stats TierStats) bool {
+	if stats.WindowAdmissions[tier] < p.ReservedCheckouts[tier] {
+		return true
+	}
+	sharedCapacity := stats.MaxCheckoutsPerWindow
+	sharedAdmissions := int64(0)
+	for _, t := range stats.Tiers {
+		reserved := p.ReservedCheckouts[t]
+		if stats.ActiveTiers[t] || t == tier {
+			sharedCapacity -= reserved
+		}
+		if overflow := stats.WindowAdmissions[t] - reserved; overflow > 0 {
+			sharedAdmissions += overflow
+		}
+	}
+	return sharedAdmissions < sharedCapacity
+}
@@ -0,0 +1,177 @@
+package impl
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/throttle/queue"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+)
+
+// TieredQueue wraps one queue per client priority tier and admits candidates of each tier
+// according to a TierAdmissionPolicy (e.g. loyalty members ahead of regular buyers).
+type TieredQueue struct {
+	metrics *metrics.Recorder
+
+	mu sync.Mutex
+
+	// Tiers ordered from highest to lowest priority.
+	tiers      []int
+	tierQueues map[int]queue.Queue
+	policy     TierAdmissionPolicy
+
+	maxCheckoutsPerWindow int64
+
+	queuedClients      map[int]int64
+	curWindowPolls     map[int]int64
+	prevWindowPolls    map[int]int64
+	curWindowAdmission map[int]int64
+	curWindowReady     map[int]bool
+	prevWindowReady    map[int]bool
+
+	// Tier of each candidate (by id) holding an admission slot of the current window until removed or released.
+	reservedSlots map[int]int
+}
+
+func (tq *TieredQueue) Add(c client.Client) {
+	tier := tq.tierOf(c)
+	tq.tierQueues[tier].Add(c)
+	tq.mu.Lock()
+	tq.queuedClients[tier]++
+	tq.mu.Unlock()
+}
+
+func (tq *TieredQueue) Remove(c client.Client) {
+	tier := tq.tierOf(c)
+	tq.tierQueues[tier].Remove(c)
+	tq.mu.Lock()
+	tq.queuedClients[tier]--
+	// Admitted candidates keep their slot counted for the window.
+	delete(tq.reservedSlots, c.ID())
+	tq.mu.Unlock()
+}
+
+// Candidates reserve an admission slot of their tier in the same critical section as the policy checks its budget,
+// so that concurrent workers cannot overshoot it.
+func (tq *TieredQueue) IsCandidateToProceed(c client.Client) bool {
+	tier := tq.tierOf(c)
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	tq.curWindowPolls[tier]++
+	if !tq.policy.Admits(tier, tq.stats()) || !tq.tierQueues[tier].IsCandidateToProceed(c) {
+		return false
+	}
+	tq.curWindowReady[tier] = true
+	tq.reservedSlots[c.ID()] = tier
+	tq.curWindowAdmission[tier]++
+	return true
+}
+
+// Frees the slot reserved by a candidate refused further along (e.g. rate limited or sold out).
+func (tq *TieredQueue) ReleaseCandidate(c client.Client) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	tier, reserved := tq.reservedSlots[c.ID()]
+	if !reserved {
+		return
+	}
+	delete(tq.reservedSlots, c.ID())
+	if tq.curWindowAdmission[tier] > 0 {
+		tq.curWindowAdmission[tier]--
+	}
+}
+
+func (tq *TieredQueue) DrawTime() time.Time {
+	var latest time.Time
+	for _, tier := range tq.tiers {
+		if drawTime := queue.LatestDrawTime(tq.tierQueues[tier]); drawTime.After(latest) {
+			latest = drawTime
+		}
+	}
+	return latest
+}
+
+// Received once per window => resets the per-window admission accounting of every tier.
+func (tq *TieredQueue) ReceiveTrackerFeedback(feedback tracker.Feedback) {
+	tq.mu.Lock()
+	for _, tier := range tq.tiers {
+		tierTag := fmt.Sprintf("priority_tier:%d", tier)
+		tq.metrics.Gauge("tq.window_admissions", float64(tq.curWindowAdmission[tier]), []string{tierTag})
+		tq.metrics.Gauge("tq.queued_clients", float64(tq.queuedClients[tier]), []string{tierTag})
+		fmt.Printf(
+			"priorityTier=%d windowAdmissions=%d queuedClients=%d windowPolls=%d\n",
+			tier, tq.curWindowAdmission[tier], tq.queuedClients[tier], tq.curWindowPolls[tier],
+		)
+	}
+	tq.prevWindowPolls = tq.curWindowPolls
+	tq.curWindowPolls = make(map[int]int64)
+	tq.prevWindowReady = tq.curWindowReady
+	tq.curWindowReady = make(map[int]bool)
+	tq.curWindowAdmission = make(map[int]int64)
+	tq.reservedSlots = make(map[int]int)
+	tq.mu.Unlock()
+
+	for _, tier := range tq.tiers {
+		tq.tierQueues[tier].ReceiveTrackerFeedback(feedback)
+	}
+}
+
+func (tq *TieredQueue) Size() int64 {
+	total := int64(0)
+	for _, tier := range tq.tiers {
+		total += tq.tierQueues[tier].Size()
+	}
+	return total
+}
+
+func (tq *TieredQueue) Clear() {
+	tq.mu.Lock()
+	tq.queuedClients = make(map[int]int64)
+	tq.curWindowPolls = make(map[int]int64)
+	tq.prevWindowPolls = make(map[int]int64)
+	tq.curWindowAdmission = make(map[int]int64)
+	tq.curWindowReady = make(map[int]bool)
+	tq.prevWindowReady = make(map[int]bool)
+	tq.reservedSlots = make(map[int]int)
+	tq.mu.Unlock()
+	for _, tier := range tq.tiers {
+		tq.tierQueues[tier].Clear()
+	}
+}
+
+// Clients of an unconfigured tier are treated as members of the lowest priority tier.
+func (tq *TieredQueue) tierOf(c client.Client) int {
+	if _, ok := tq.tierQueues[c.PriorityTier()]; ok {
+		return c.PriorityTier()
+	}
+	return tq.tiers[len(tq.tiers)-1]
+}
+
+// A tier competes for admission while it has queued clients who polled recently, and is ready while its queue
+// recently let one of them proceed (the previous window counts too, as the current one may not have been polled yet).
+func (tq *TieredQueue) stats() TierStats {
+	activeTiers := make(map[int]bool, len(tq.tiers))
+	readyTiers := make(map[int]bool, len(tq.tiers))
+	for _, tier := range tq.tiers {
+		recentlyPolled := tq.curWindowPolls[tier] > 0 || tq.prevWindowPolls[tier] > 0
+		activeTiers[tier] = recentlyPolled && tq.queuedClients[tier] > 0
+		readyTiers[tier] = activeTiers[tier] && (tq.curWindowReady[tier] || tq.prevWindowReady[tier])
+	}
+	return TierStats{
+		Tiers:                 tq.tiers,
+		ActiveTiers:           activeTiers,
+		ReadyTiers:            readyTiers,
+		WindowAdmissions:      tq.curWindowAdmission,
+		MaxCheckoutsPerWindow: tq.maxCheckoutsPerWindow,
+	}
+}
+
+func sortedTiersByPriority(tiers []int) []int {
+	sorted := append([]int(nil), tiers...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	return sorted
+}
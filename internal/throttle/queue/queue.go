@@ -1,6 +1,8 @@
 package queue
 
 import (
+	"time"
+
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 )
@@ -13,3 +15,30 @@ type Queue interface {
 	Size() int64
 	Clear()
 }
+
+// CandidateReleaser is implemented by queues reserving admission capacity for the candidates they let proceed,
+// which must be released when a candidate is refused further along (e.g. rate limited or sold out).
+type CandidateReleaser interface {
+	ReleaseCandidate(client client.Client)
+}
+
+// Releases whatever admission capacity q reserved for candidate c, if any.
+func ReleaseCandidate(q Queue, c client.Client) {
+	if releaser, ok := q.(CandidateReleaser); ok {
+		releaser.ReleaseCandidate(c)
+	}
+}
+
+// Raffle is implemented by queues admitting the clients entered by DrawTime in a randomly drawn order (zero until
+// the draw is scheduled).
+type Raffle interface {
+	DrawTime() time.Time
+}
+
+// Latest draw time among the raffles q holds, if any (zero otherwise).
+func LatestDrawTime(q Queue) time.Time {
+	if raffle, ok := q.(Raffle); ok {
+		return raffle.DrawTime()
+	}
+	return time.Time{}
+}
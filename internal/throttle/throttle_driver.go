@@ -7,18 +7,26 @@ import (
 
 	"github.com/Shopify/goqueuesim/internal/client"
 	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
 	"github.com/Shopify/goqueuesim/internal/metrics"
 	"github.com/Shopify/goqueuesim/internal/throttle/queue"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 )
 
 type CheckoutThrottleDriver struct {
+	ShopId                 int
+	NodeId                 int
 	Ctx                    context.Context
 	CtxCancelFunc          context.CancelFunc
 	StartSignalWaitGroup   *sync.WaitGroup
 	ThrottleQueue          queue.Queue
 	RateTracker            tracker.Tracker
 	GlobalInventoryCounter *common.AtomicCounter
+
+	Metrics *metrics.Recorder
+
+	// Cost of the work done by the shop's queue & tracker (Redis backed queues charge their own Redis calls).
+	Costs *cost.Ledger
 }
 
 // Returns true if a state change has occurred, else false.
@@ -26,39 +34,56 @@ func (t *CheckoutThrottleDriver) TryThrottleStateTransition(c client.Client) (cl
 	state := c.ThrottleState()
 	switch state {
 	case client.Initial:
-		// Client first observed => track & enqueue.
+		// Client first observed => track & enqueue (unless resuming its place from a surviving cookie).
+		cookieState, _ := c.GetThrottleCookieVal(client.ThrottleStateKey)
+		resumed := cookieState == client.Queued.String()
 		err := c.MarkQueued()
 		if err != nil {
 			return state, false
 		}
-		t.ThrottleQueue.Add(c)
+		if c.NumReloads() > 0 {
+			t.Metrics.Incr("server.reentry", []string{fmt.Sprintf("resumed:%t", resumed), fmt.Sprintf("shop_id:%d", t.ShopId)})
+		}
+		if !resumed {
+			t.Costs.Charge(cost.QueueOperation, "add")
+			t.ThrottleQueue.Add(c)
+		}
 
 		// Optional: poll-free attempt to progress immediately.
 		latestTransitionState, _ := t.TryThrottleStateTransition(c)
 
 		return latestTransitionState, true
 	case client.Queued:
-		if t.ThrottleQueue.IsCandidateToProceed(c) && t.RateTracker.ShouldProceed(c.ID()) {
+		t.Costs.Charge(cost.QueueOperation, "is_candidate_to_proceed")
+		if !t.ThrottleQueue.IsCandidateToProceed(c) {
+			return client.Queued, false
+		}
+		if t.chargedShouldProceed(c.ID()) {
 			t.GlobalInventoryCounter.Lock()
 			remInventory, _ := t.GlobalInventoryCounter.AtomicRead()
 			if remInventory < 0 {
 				t.GlobalInventoryCounter.Unlock()
+				queue.ReleaseCandidate(t.ThrottleQueue, c)
 				return state, false
 			}
 			err := c.MarkInCheckout()
 			if err != nil {
 				t.GlobalInventoryCounter.Unlock()
+				queue.ReleaseCandidate(t.ThrottleQueue, c)
 				return state, false
 			}
 			remInventory, _ = t.GlobalInventoryCounter.AtomicAdd(-1)
 			t.GlobalInventoryCounter.Unlock()
+			t.Costs.Charge(cost.QueueOperation, "remove")
 			t.ThrottleQueue.Remove(c)
 			if remInventory <= 0 {
+				t.Costs.Charge(cost.QueueOperation, "clear")
 				t.ThrottleQueue.Clear()
 				t.CtxCancelFunc()
 			}
 			return client.InCheckout, true
 		}
+		queue.ReleaseCandidate(t.ThrottleQueue, c)
 		return client.Queued, false
 	case client.InCheckout:
 		fallthrough
@@ -69,6 +94,18 @@ func (t *CheckoutThrottleDriver) TryThrottleStateTransition(c client.Client) (cl
 	}
 }
 
+// Removes c (locked) from the queue as it leaves without being admitted, e.g. abandoning or vanishing.
+func (t *CheckoutThrottleDriver) RemoveDeparted(c client.Client) {
+	t.Costs.Charge(cost.QueueOperation, "remove")
+	t.ThrottleQueue.Remove(c)
+	t.Metrics.Incr("server.queue_departures", []string{fmt.Sprintf("shop_id:%d", t.ShopId), fmt.Sprintf("node_id:%d", t.NodeId)})
+}
+
+func (t *CheckoutThrottleDriver) chargedShouldProceed(clientId int) bool {
+	t.Costs.Charge(cost.TrackerOperation, "should_proceed")
+	return t.RateTracker.ShouldProceed(clientId)
+}
+
 // Blocking routine to monitor rateTrackerPollingUtil feedback -> emit to queue once per tracker window.
 func (t *CheckoutThrottleDriver) MonitorUtilAndNotifyQueue() {
 	trackerFeedbackChannel := t.RateTracker.GetFeedbackChannel()
@@ -78,9 +115,11 @@ func (t *CheckoutThrottleDriver) MonitorUtilAndNotifyQueue() {
 		case <-t.Ctx.Done():
 			return
 		case nextFeedbackMsg := <-trackerFeedbackChannel:
-			fmt.Printf("Checkout Util=%.2f \n", nextFeedbackMsg.CheckoutUtil)
-			metrics.Gauge("polling_util", nextFeedbackMsg.PollingUtil, nil)
-			metrics.Gauge("reached_checkout_util", nextFeedbackMsg.CheckoutUtil, nil)
+			fmt.Printf("shop_id=%d node_id=%d Checkout Util=%.2f \n", t.ShopId, t.NodeId, nextFeedbackMsg.CheckoutUtil)
+			shopTags := []string{fmt.Sprintf("shop_id:%d", t.ShopId), fmt.Sprintf("node_id:%d", t.NodeId)}
+			t.Metrics.Gauge("polling_util", nextFeedbackMsg.PollingUtil, shopTags)
+			t.Metrics.Gauge("reached_checkout_util", nextFeedbackMsg.CheckoutUtil, shopTags)
+			t.Costs.Charge(cost.QueueOperation, "receive_feedback")
 			t.ThrottleQueue.ReceiveTrackerFeedback(nextFeedbackMsg)
 		}
 	}
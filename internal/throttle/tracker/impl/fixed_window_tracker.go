@@ -2,9 +2,12 @@ package impl
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
 	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
 )
 
@@ -27,6 +30,9 @@ type FixedWindowTracker struct {
 
 	curWindowIndex int
 
+	// Consecutive windows of low polling utilization so far (guarded by windowedPollingUtil).
+	lowPollingUtilConsecCount int
+
 	// Mutex-guarded dict for { windowIndex -> { clientId -> hasPolled } }
 	windowedPollingUtil struct {
 		sync.Mutex
@@ -95,7 +101,6 @@ func (t *FixedWindowTracker) MonitorAndEmitFeedback(
 	lowUtilMaxThresholdPct float64,
 	maxSkpdLowPollingUtilCount int,
 ) {
-	lowPollingUtilConsecCount := 0
 	t.startSignalWaitGroup.Wait()
 	sleepTimer := time.NewTimer(t.fixedWindowDuration)
 	for { // loop infinitely
@@ -113,15 +118,16 @@ func (t *FixedWindowTracker) MonitorAndEmitFeedback(
 			trackerPollingUtil := t.PollingUtilization(t.curWindowIndex)
 			trackerCheckoutUtil := t.CheckoutUtilization(t.curWindowIndex)
 			t.curWindowIndex++
-			t.windowedCheckoutUtil.Unlock()
-			t.windowedPollingUtil.Unlock()
 
 			// Skip notifying queue (let clients poll) while (low util events seq.) <= (max skipped)
 			if trackerPollingUtil < lowUtilMaxThresholdPct {
-				lowPollingUtilConsecCount++
+				t.lowPollingUtilConsecCount++
 			} else {
-				lowPollingUtilConsecCount = 0
+				t.lowPollingUtilConsecCount = 0
 			}
+			lowPollingUtilConsecCount := t.lowPollingUtilConsecCount
+			t.windowedCheckoutUtil.Unlock()
+			t.windowedPollingUtil.Unlock()
 
 			feedback := tracker.Feedback{PollingUtil: trackerPollingUtil, CheckoutUtil: trackerCheckoutUtil}
 			feedback.CustomFeedback = make(map[string]interface{})
@@ -138,3 +144,58 @@ func (t *FixedWindowTracker) MonitorAndEmitFeedback(
 		}
 	}
 }
+
+// Only the current window is kept (past windows never matter again).
+type fixedWindowTrackerState struct {
+	CurWindowIndex            int
+	PolledClients             []int
+	CheckoutClients           []int
+	LowPollingUtilConsecCount int
+}
+
+func (t *FixedWindowTracker) Checkpoint(_ checkpoint.Clock) (json.RawMessage, error) {
+	t.windowedPollingUtil.Lock()
+	t.windowedCheckoutUtil.Lock()
+	defer t.windowedPollingUtil.Unlock()
+	defer t.windowedCheckoutUtil.Unlock()
+	return json.Marshal(fixedWindowTrackerState{
+		CurWindowIndex:            t.curWindowIndex,
+		PolledClients:             sortedClientIds(t.windowedPollingUtil.dict[t.curWindowIndex]),
+		CheckoutClients:           sortedClientIds(t.windowedCheckoutUtil.dict[t.curWindowIndex]),
+		LowPollingUtilConsecCount: t.lowPollingUtilConsecCount,
+	})
+}
+
+// The restored window lasts a full window duration from the start of the resumed simulation.
+func (t *FixedWindowTracker) Restore(state json.RawMessage, _ checkpoint.Clock) error {
+	var s fixedWindowTrackerState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	t.windowedPollingUtil.Lock()
+	t.windowedCheckoutUtil.Lock()
+	defer t.windowedPollingUtil.Unlock()
+	defer t.windowedCheckoutUtil.Unlock()
+	t.curWindowIndex = s.CurWindowIndex
+	t.lowPollingUtilConsecCount = s.LowPollingUtilConsecCount
+	t.windowedPollingUtil.dict = map[int]map[int]bool{s.CurWindowIndex: clientIdSet(s.PolledClients)}
+	t.windowedCheckoutUtil.dict = map[int]map[int]bool{s.CurWindowIndex: clientIdSet(s.CheckoutClients)}
+	return nil
+}
+
+func sortedClientIds(set map[int]bool) []int {
+	clientIds := make([]int, 0, len(set))
+	for clientId := range set {
+		clientIds = append(clientIds, clientId)
+	}
+	sort.Ints(clientIds)
+	return clientIds
+}
+
+func clientIdSet(clientIds []int) map[int]bool {
+	set := make(map[int]bool, len(clientIds))
+	for _, clientId := range clientIds {
+		set[clientId] = true
+	}
+	return set
+}
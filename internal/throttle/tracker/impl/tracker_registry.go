@@ -0,0 +1,77 @@
+package impl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/registry"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+)
+
+// TrackerParams are handed to the constructor of every tracker type.
+type TrackerParams struct {
+	Ctx                  context.Context
+	StartSignalWaitGroup *sync.WaitGroup
+
+	WindowDuration        time.Duration
+	MaxCheckoutsPerWindow uint64
+
+	// Options of the tracker type, resolved against its schema.
+	Options registry.Options
+}
+
+// TrackerType is a registered implementation of tracker.Tracker.
+type TrackerType struct {
+	registry.Plugin
+	Make func(params TrackerParams) tracker.Tracker
+}
+
+var trackerTypes = registry.MakeRegistry("tracker type")
+
+// Makes a tracker type available by name (typically from an init function of the file implementing it).
+func RegisterTrackerType(trackerType TrackerType) {
+	trackerTypes.Register(trackerType.Plugin, trackerType)
+}
+
+func LookupTrackerType(name string) (TrackerType, error) {
+	_, trackerType, err := trackerTypes.Lookup(name)
+	if err != nil {
+		return TrackerType{}, err
+	}
+	return trackerType.(TrackerType), nil
+}
+
+// Options given to the named tracker type resolved against its schema (defaults filled in).
+func ResolveTrackerOptions(name string, options registry.Options) (registry.Options, error) {
+	return trackerTypes.ResolveOptions(name, options)
+}
+
+// Registered tracker types, sorted by name.
+func TrackerTypes() []registry.Plugin {
+	return trackerTypes.Plugins()
+}
+
+func init() {
+	RegisterTrackerType(TrackerType{
+		Plugin: registry.Plugin{
+			Name:        "fixed_window",
+			Description: "Allows the max checkouts per window in each fixed window, notifying the queue of low utilization.",
+			Options: []registry.OptionSchema{
+				registry.FloatSchema(
+					"low_util_max_threshold_pct", 0.25, 0, 1, "Checkout utilization <= to which a window is deemed low.",
+				),
+				registry.IntSchema(
+					"max_skipped_low_util_windows", 15, 0, registry.Unbounded, "Low util windows skipped before notifying the queue.",
+				),
+			},
+		},
+		Make: func(p TrackerParams) tracker.Tracker {
+			t := MakeFixedWindowTracker(p.Ctx, p.StartSignalWaitGroup, p.WindowDuration, p.MaxCheckoutsPerWindow)
+			go t.MonitorAndEmitFeedback(
+				p.Options.Float("low_util_max_threshold_pct"), p.Options.Int("max_skipped_low_util_windows"),
+			)
+			return t
+		},
+	})
+}
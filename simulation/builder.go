@@ -0,0 +1,95 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+)
+
+// Builder assembles a Simulation from DefaultConfig, e.g.:
+//
+//	sim, err := simulation.New().
+//		Queue("strict_fifo_queue").
+//		Tracker("fixed_window", 2*time.Second, 100).
+//		ClientDistribution("config/simulation/client_distributions/plausible_very_unfair.json", 1000, 500).
+//		Build()
+type Builder struct {
+	cfg           Config
+	replacesShops bool
+	resume        *Checkpoint
+}
+
+func New() *Builder {
+	return FromConfig(DefaultConfig())
+}
+
+func FromConfig(cfg Config) *Builder {
+	return &Builder{cfg: cfg}
+}
+
+func (b *Builder) Queue(queueType string) *Builder {
+	b.cfg.QueueType = queueType
+	return b
+}
+
+func (b *Builder) Tracker(trackerType string, windowDuration time.Duration, maxCheckoutsPerWindow int64) *Builder {
+	b.cfg.TrackerType = trackerType
+	b.cfg.WindowDuration = windowDuration
+	b.cfg.MaxCheckoutsAllowedPerWindow = maxCheckoutsPerWindow
+	return b
+}
+
+// Simulates a single shop of numClients drawn from the distribution at jsonPath (arriving in random order).
+func (b *Builder) ClientDistribution(jsonPath string, numClients int, inventoryStockTotal int) *Builder {
+	b.replacesShops = false
+	return b.AddShop(ShopConfig{
+		ClientDistributionJsonPath: jsonPath,
+		NumClients:                 numClients,
+		InventoryStockTotal:        inventoryStockTotal,
+		RandomizeClientOrder:       true,
+	})
+}
+
+// Simulates one more shop concurrently (the first shop added replaces the default one).
+func (b *Builder) AddShop(shop ShopConfig) *Builder {
+	if !b.replacesShops {
+		b.cfg.Shops = nil
+		b.replacesShops = true
+	}
+	b.cfg.Shops = append(b.cfg.Shops, shop)
+	return b
+}
+
+func (b *Builder) ClientClock(clock ClockConfig) *Builder {
+	b.cfg.ClientClock = &clock
+	return b
+}
+
+func (b *Builder) MetricsSink(sink MetricsSink) *Builder {
+	b.cfg.MetricsSink = sink
+	return b
+}
+
+// Resumes the simulation from cp (taken by a simulation of the same shops & client distributions). Resuming with
+// other queue or tracker parameters forks the checkpointed simulation into a what-if continuation.
+func (b *Builder) ResumeFrom(cp *Checkpoint) *Builder {
+	b.resume = cp
+	return b
+}
+
+// Sets any other parameter of the simulation.
+func (b *Builder) Configure(configure func(cfg *Config)) *Builder {
+	configure(&b.cfg)
+	return b
+}
+
+func (b *Builder) Build() (*Simulation, error) {
+	cfg := b.cfg
+	cfg.Shops = append([]ShopConfig(nil), b.cfg.Shops...)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if b.resume != nil && cfg.throttledExternally() {
+		return nil, fmt.Errorf("simulations throttled externally cannot be checkpointed or resumed")
+	}
+	return &Simulation{cfg: cfg, resume: b.resume}, nil
+}
@@ -0,0 +1,283 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/cost"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+	"github.com/Shopify/goqueuesim/internal/simulator"
+	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
+	trackerfactory "github.com/Shopify/goqueuesim/internal/throttle/tracker/impl"
+)
+
+const checkpointVersion = 2
+
+// Checkpoint is the state of a simulation stopped after Config.CheckpointAfter. It may be resumed any number of
+// times (see Builder.ResumeFrom), e.g. forked into what-if continuations with other queue parameters.
+type Checkpoint struct {
+	Version int
+
+	// Time the simulation had run for when checkpointed (every time saved is an offset on this clock).
+	Elapsed time.Duration
+
+	// Configuration the queues & trackers were checkpointed under: their internals are only restored when resumed
+	// under the same, otherwise waiting clients are queued afresh in their original order.
+	Throttle string
+
+	Shops []ShopCheckpoint
+}
+
+// ClientCheckpoint is the session of one client (throttle cookie & pending request or poll included).
+type ClientCheckpoint = checkpoint.ClientState
+
+type ShopCheckpoint struct {
+	ShopId             int
+	RemainingInventory int
+	Costs              []cost.Entry
+	Clients            []ClientCheckpoint
+
+	// Internals of the queue & tracker of each server node (null for those unable to checkpoint, e.g. queues
+	// backed by Redis).
+	Queues   []json.RawMessage
+	Trackers []json.RawMessage
+
+	// Id of the node whose queue each queued client (by id) joined.
+	QueueNodes map[int]int `json:",omitempty"`
+}
+
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %s", path, err.Error())
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s is of version %d, expected %d", path, cp.Version, checkpointVersion)
+	}
+	return &cp, nil
+}
+
+func (cp *Checkpoint) Save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Property values each client (by id) had sampled from its property_distributions.
+func (cp *Checkpoint) sampledProperties() map[int]map[string]float64 {
+	sampled := make(map[int]map[string]float64)
+	for _, shopCheckpoint := range cp.Shops {
+		for _, clientState := range shopCheckpoint.Clients {
+			if len(clientState.SampledProperties) > 0 {
+				sampled[clientState.Id] = clientState.SampledProperties
+			}
+		}
+	}
+	return sampled
+}
+
+// Identifies the configuration queue & tracker internals depend upon.
+func (cfg *Config) throttleFingerprint() string {
+	queueOptions, _ := queuefactory.ResolveQueueOptions(cfg.QueueType, cfg.QueueOptions)
+	trackerOptions, _ := trackerfactory.ResolveTrackerOptions(cfg.TrackerType, cfg.TrackerOptions)
+	return fmt.Sprintf(
+		"queue=%s%v tracker=%s%v window=%s max_checkouts=%d nodes=%d priority_tier_policy=%s signed_cookies=%t",
+		cfg.QueueType, queueOptions, cfg.TrackerType, trackerOptions, cfg.WindowDuration,
+		cfg.MaxCheckoutsAllowedPerWindow, cfg.NumServerNodes, cfg.PriorityTierPolicy, cfg.SignsThrottleCookies,
+	)
+}
+
+// Snapshots every shop of a simulation stopped (yet to drain) for a checkpoint.
+func (cfg *Config) takeCheckpoint(
+	simDriver *simulator.SimulationDriver,
+	clock checkpoint.Clock,
+	elapsed time.Duration,
+) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		Version:  checkpointVersion,
+		Elapsed:  elapsed,
+		Throttle: cfg.throttleFingerprint(),
+		Shops:    make([]ShopCheckpoint, 0, len(simDriver.Shops)),
+	}
+	queueNodeIds := simDriver.QueueNodeIds()
+	for _, shop := range simDriver.Shops {
+		shopCheckpoint := ShopCheckpoint{
+			ShopId:     shop.Id,
+			Costs:      shop.Costs.Entries(),
+			Clients:    make([]ClientCheckpoint, 0, len(shop.Clients)),
+			QueueNodes: make(map[int]int),
+		}
+		for _, c := range shop.Clients {
+			checkpointer, ok := c.(checkpoint.ClientCheckpointer)
+			if !ok {
+				return nil, fmt.Errorf("client %d (%s) cannot be checkpointed", c.ID(), c.Label())
+			}
+			c.Lock()
+			shopCheckpoint.Clients = append(shopCheckpoint.Clients, checkpointer.CheckpointSession(clock))
+			c.Unlock()
+			if nodeId, found := queueNodeIds[c.ID()]; found {
+				shopCheckpoint.QueueNodes[c.ID()] = nodeId
+			}
+		}
+		sort.Slice(shopCheckpoint.Clients, func(i, j int) bool {
+			return shopCheckpoint.Clients[i].Id < shopCheckpoint.Clients[j].Id
+		})
+		for _, driver := range shop.NodeThrottleDrivers {
+			queueState, err := checkpointInternals(driver.ThrottleQueue, clock)
+			if err != nil {
+				return nil, err
+			}
+			trackerState, err := checkpointInternals(driver.RateTracker, clock)
+			if err != nil {
+				return nil, err
+			}
+			shopCheckpoint.Queues = append(shopCheckpoint.Queues, queueState)
+			shopCheckpoint.Trackers = append(shopCheckpoint.Trackers, trackerState)
+		}
+		if len(shop.NodeThrottleDrivers) > 0 {
+			inventoryCounter := shop.NodeThrottleDrivers[0].GlobalInventoryCounter
+			inventoryCounter.Lock()
+			remInventory, _ := inventoryCounter.AtomicRead()
+			inventoryCounter.Unlock()
+			shopCheckpoint.RemainingInventory = int(remInventory)
+		}
+		cp.Shops = append(cp.Shops, shopCheckpoint)
+	}
+	return cp, nil
+}
+
+// Null unless v is a checkpoint.Checkpointer.
+func checkpointInternals(v interface{}, clock checkpoint.Clock) (json.RawMessage, error) {
+	checkpointer, ok := v.(checkpoint.Checkpointer)
+	if !ok {
+		return nil, nil
+	}
+	return checkpointer.Checkpoint(clock)
+}
+
+func hasInternals(state json.RawMessage) bool {
+	return len(state) > 0 && string(state) != "null"
+}
+
+// Restores every shop of a simulation yet to start from cp. Queue & tracker internals are restored when resumed
+// under the configuration they were checkpointed under, otherwise waiting clients are queued afresh (in the order
+// they originally entered the queue) on the node the load balancer routes them to.
+func (cfg *Config) restoreCheckpoint(cp *Checkpoint, simDriver *simulator.SimulationDriver, clock checkpoint.Clock) error {
+	if len(cp.Shops) != len(simDriver.Shops) {
+		return fmt.Errorf("checkpoint has %d shops but the resumed simulation %d", len(cp.Shops), len(simDriver.Shops))
+	}
+	restoresInternals := cp.Throttle == cfg.throttleFingerprint()
+	for i, shop := range simDriver.Shops {
+		shopCheckpoint := cp.Shops[i]
+		if len(shopCheckpoint.Clients) != len(shop.Clients) {
+			return fmt.Errorf(
+				"shop %d checkpointed %d clients but the resumed simulation made %d",
+				shop.Id, len(shopCheckpoint.Clients), len(shop.Clients),
+			)
+		}
+		for _, queueState := range shopCheckpoint.Queues {
+			restoresInternals = restoresInternals && hasInternals(queueState)
+		}
+		restoresInternals = restoresInternals && len(shopCheckpoint.Queues) == len(shop.NodeThrottleDrivers)
+	}
+
+	for i, shop := range simDriver.Shops {
+		shopCheckpoint := cp.Shops[i]
+		queuedClients, err := restoreClients(shop, shopCheckpoint.Clients, restoresInternals, clock)
+		if err != nil {
+			return err
+		}
+		if len(shop.NodeThrottleDrivers) == 0 {
+			continue
+		}
+		shop.Costs.AddEntries(shopCheckpoint.Costs)
+		inventoryCounter := shop.NodeThrottleDrivers[0].GlobalInventoryCounter
+		inventoryCounter.Lock()
+		inventoryCounter.Count = int32(shopCheckpoint.RemainingInventory)
+		inventoryCounter.Unlock()
+		if shopCheckpoint.RemainingInventory <= 0 {
+			shop.CtxCancelFunc()
+			continue
+		}
+		// Whatever queues hold (e.g. Redis keys of earlier runs, in every tier) makes way for the checkpointed state.
+		for _, driver := range shop.NodeThrottleDrivers {
+			driver.ThrottleQueue.Clear()
+		}
+		if !restoresInternals {
+			fmt.Printf("\nshop_id=%d re-queueing %d waiting clients\n", shop.Id, len(queuedClients))
+			for _, c := range queuedClients {
+				nodeId := simDriver.LoadBalancer.Route(network_mock.MakeCheckoutRequest(c.SessionData()), len(shop.NodeThrottleDrivers))
+				c.Lock()
+				shop.NodeThrottleDrivers[nodeId].ThrottleQueue.Add(c)
+				c.Unlock()
+				simDriver.RecordQueueNode(c.ID(), nodeId)
+			}
+			continue
+		}
+		for clientId, nodeId := range shopCheckpoint.QueueNodes {
+			simDriver.RecordQueueNode(clientId, nodeId)
+		}
+		for nodeId, driver := range shop.NodeThrottleDrivers {
+			if err := driver.ThrottleQueue.(checkpoint.Checkpointer).Restore(shopCheckpoint.Queues[nodeId], clock); err != nil {
+				return fmt.Errorf("shop %d failed to restore its queue: %s", shop.Id, err.Error())
+			}
+			trackerCheckpointer, ok := driver.RateTracker.(checkpoint.Checkpointer)
+			if !ok || nodeId >= len(shopCheckpoint.Trackers) || !hasInternals(shopCheckpoint.Trackers[nodeId]) {
+				continue
+			}
+			if err := trackerCheckpointer.Restore(shopCheckpoint.Trackers[nodeId], clock); err != nil {
+				return fmt.Errorf("shop %d failed to restore its tracker: %s", shop.Id, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Restores the session of every client of shop, returning those queued by order of queue entry. Unless queue
+// internals are restored, the state queues keep in throttle cookies is dropped (the clients get queued afresh).
+func restoreClients(
+	shop *simulator.Shop,
+	clientStates []ClientCheckpoint,
+	restoresInternals bool,
+	clock checkpoint.Clock,
+) ([]client.Client, error) {
+	clientsById := make(map[int]client.Client, len(shop.Clients))
+	for _, c := range shop.Clients {
+		clientsById[c.ID()] = c
+	}
+	queuedClients := make([]client.Client, 0)
+	for _, state := range clientStates {
+		c, found := clientsById[state.Id]
+		if !found {
+			return nil, fmt.Errorf("checkpointed client %d is not part of shop %d in the resumed simulation", state.Id, shop.Id)
+		}
+		checkpointer, ok := c.(checkpoint.ClientCheckpointer)
+		if !ok {
+			return nil, fmt.Errorf("client %d (%s) cannot be restored", c.ID(), c.Label())
+		}
+		if !restoresInternals && state.ThrottleState == client.Queued.String() {
+			state.ThrottleCookie = map[string]string{client.ThrottleStateKey: client.Queued.String()}
+		}
+		if err := checkpointer.RestoreSession(state, clock); err != nil {
+			return nil, err
+		}
+		if c.IsQueued() {
+			queuedClients = append(queuedClients, c)
+		}
+	}
+	sort.SliceStable(queuedClients, func(i, j int) bool {
+		return queuedClients[i].QueueEntryTime().Before(queuedClients[j].QueueEntryTime())
+	})
+	return queuedClients, nil
+}
@@ -0,0 +1,130 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+)
+
+// Small seeded simulation whose queue keeps growing (admitting a few clients per window) when checkpointed.
+func makeCheckpointTestConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Shops = []ShopConfig{{
+		ClientDistributionJsonPath: "../config/simulation/client_distributions/device_mixture.json",
+		NumClients:                 200,
+		InventoryStockTotal:        1000,
+		RandomizeClientOrder:       true,
+	}}
+	cfg.QueueType = "strict_fifo_queue"
+	cfg.WindowDuration = 500 * time.Millisecond
+	cfg.MaxCheckoutsAllowedPerWindow = 5
+	cfg.NumServerWorkers = 50
+	cfg.FairnessBaselinePath = ""
+	cfg.Seed = 42
+	cfg.DrainTimeout = 2 * time.Second
+	return cfg
+}
+
+func marshalCheckpoint(t *testing.T, cp *Checkpoint) string {
+	t.Helper()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatalf("failed to marshal checkpoint: %v", err)
+	}
+	return string(data)
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goqueuesim-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	cfg := makeCheckpointTestConfig()
+	cfg.CheckpointAfter = 2 * time.Second
+	cfg.CheckpointPath = path
+	sim, err := FromConfig(cfg).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sim.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checkpoint == nil {
+		t.Fatalf("simulation completed before its checkpoint")
+	}
+
+	// Saved & loaded as is.
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := marshalCheckpoint(t, result.Checkpoint)
+	if loaded := marshalCheckpoint(t, cp); loaded != saved {
+		t.Fatalf("loaded checkpoint differs from the one saved:\n%s\nvs\n%s", loaded, saved)
+	}
+	numQueued := 0
+	for _, clientState := range cp.Shops[0].Clients {
+		if clientState.ThrottleState == client.Queued.String() {
+			numQueued++
+		}
+	}
+	if numQueued == 0 || !hasInternals(cp.Shops[0].Queues[0]) {
+		t.Fatalf("checkpoint has %d queued clients & queue internals %s, want a waiting line", numQueued, cp.Shops[0].Queues)
+	}
+
+	// Resumed clients, queue & tracker hold the state checkpointed (snapshotted afresh before the run starts).
+	resumeCfg := makeCheckpointTestConfig()
+	resumed, err := FromConfig(resumeCfg).ResumeFrom(cp).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run, err := resumed.prepare(ctx, cancel, cp)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	restored, err := resumeCfg.takeCheckpoint(run.simDriver, run.clock, cp.Elapsed)
+	cancel()
+	run.simDriver.StartSignalWaitGroup.Done()
+	run.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := marshalCheckpoint(t, restored); got != saved {
+		t.Errorf("resumed simulation holds another state than checkpointed:\n%s\nvs\n%s", got, saved)
+	}
+
+	// Resumed simulations carry on from the checkpoint.
+	resumeCfg.CheckpointAfter = time.Second
+	resumed, err = FromConfig(resumeCfg).ResumeFrom(cp).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumedResult, err := resumed.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumedResult.Checkpoint == nil {
+		t.Fatalf("resumed simulation completed before its checkpoint")
+	}
+	if elapsed := resumedResult.Checkpoint.Elapsed; elapsed < cp.Elapsed+time.Second {
+		t.Errorf("got resumed checkpoint after %s, want at least a second after %s", elapsed, cp.Elapsed)
+	}
+	if resumedResult.Shops[0].NumAdmitted < result.Shops[0].NumAdmitted {
+		t.Errorf(
+			"got %d clients admitted once resumed, want at least the %d admitted before",
+			resumedResult.Shops[0].NumAdmitted, result.Shops[0].NumAdmitted,
+		)
+	}
+}
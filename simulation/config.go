@@ -0,0 +1,325 @@
+package simulation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/registry"
+	"github.com/Shopify/goqueuesim/internal/simulator"
+	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
+	trackerfactory "github.com/Shopify/goqueuesim/internal/throttle/tracker/impl"
+)
+
+// Options of a registered plugin (e.g. a queue type) by name, see `go run ./cmd/plugins` for those of each.
+type Options = registry.Options
+
+// ShopConfig describes one of the concurrently simulated shops.
+type ShopConfig struct {
+	// Json file configuring distribution of Checkout clients for this shop.
+	ClientDistributionJsonPath string
+
+	// Number of Checkout clients generated for this shop.
+	NumClients int
+
+	// Max available inventory for this shop.
+	InventoryStockTotal int
+
+	// Whether clients start in random order rather than in the order of the distribution file.
+	RandomizeClientOrder bool
+}
+
+// ClockConfig sets the imperfect timekeeping of clients whose distribution does not configure their own
+// (see the clock_offset_* & *_visible/hidden_* client properties).
+type ClockConfig struct {
+	OffsetMeanMs           int
+	OffsetStddevMs         int
+	BackgroundTimerClampMs int
+	MeanVisibleSecs        float64
+	MeanHiddenSecs         float64
+}
+
+// Config holds every parameter of a simulation (see DefaultConfig & the README for what each does).
+type Config struct {
+	// Shops simulated concurrently: each has its own clients, inventory, queue & tracker while sharing server
+	// nodes and (for Redis-backed queues) a single Redis.
+	Shops []ShopConfig
+
+	// Registered type of UserQueue strategy to execute & its options (defaults of the type for those left out).
+	QueueType    string
+	QueueOptions Options
+
+	// Registered type of RateTracker strategy to execute & its options.
+	TrackerType    string
+	TrackerOptions Options
+
+	// Time window enacted by rate tracker & max checkouts allowed per window (shared by queue & tracker).
+	WindowDuration               time.Duration
+	MaxCheckoutsAllowedPerWindow int64
+
+	// Policy admitting client priority tiers (each tier gets its own queue of type QueueType) & its parameters.
+	// One of: {none, strict_priority, weighted_share, reserved_capacity}.
+	PriorityTierPolicy            string
+	PriorityTierWeights           map[int]float64
+	PriorityTierReservedCheckouts map[int]int64
+
+	// Whether shop queues sign the state they store in throttle cookies (rejecting tampered cookies).
+	SignsThrottleCookies bool
+	ThrottleCookieSecret string
+	ThrottleCookieMaxAge time.Duration
+
+	// Registered type of ClientRepo backing the simulator.
+	ClientRepoType string
+
+	// Threshold >= to which we deem intolerable unfairness.
+	MaxUnfairnessToleranceSeconds float64
+
+	// Json file with fairness results of the strict FIFO baseline: written by runs of the strict_fifo_queue and
+	// compared against by runs of any other queue type (none if empty).
+	FairnessBaselinePath string
+
+	// Seeds the randomized client order of shops (drawn from the clock if 0). Runs are only compared with a
+	// fairness baseline recorded under the same seed.
+	Seed int64
+
+	// The maximum number of queued requests or responses.
+	MaxNetworkIOBacklogSize int
+
+	// Network carrying requests & responses. One of: {instant, simulated, http, external}.
+	NetworkModelType           string
+	DefaultLinkProfile         string
+	ExternalThrottleBaseURL    string
+	StartsMockExternalThrottle bool
+	ServesThrottleOverHTTP     bool
+	HTTPListenAddr             string
+	HTTPRequestTimeout         time.Duration
+
+	// Server nodes behind a load balancer ({random, round_robin, sticky}), each running NumServerWorkers.
+	NumServerNodes     int
+	NumServerWorkers   int
+	LoadBalancerPolicy string
+
+	// Median time (lognormally distributed with ServiceTimeSigma) server workers spend on each request.
+	CheckoutServiceTimeMedianMs float64
+	PollServiceTimeMedianMs     float64
+	ServiceTimeSigma            float64
+	MaxQueueingDelay            time.Duration
+
+	// Cost model: notional server CPU ms per request served & per queue/tracker operation, plus per Redis call.
+	CheckoutEndpointCpuMs float64
+	PollEndpointCpuMs     float64
+	QueueOperationCpuMs   float64
+	RedisCallCpuMs        float64
+	LuaScriptCpuMs        float64
+
+	// Redis backing user queues. Keys are scoped by RedisKeyPrefix (if any) then shop, so that simulations
+	// running in parallel against the same Redis keep apart.
+	RedisAddr      string
+	RedisKeyPrefix string
+
+	// Time given to server & client workers to return once the simulation completes.
+	DrainTimeout time.Duration
+
+	// Stops the simulation once it has run for CheckpointAfter (never if 0) to checkpoint its state, saved to
+	// CheckpointPath (if set) & returned in Result.Checkpoint.
+	CheckpointAfter time.Duration
+	CheckpointPath  string
+
+	// Checkpoint file the simulation resumes from (starts afresh if empty, see also Builder.ResumeFrom).
+	ResumeFromPath string
+
+	// Clock of clients not configuring their own (perfect clocks if nil).
+	ClientClock *ClockConfig
+
+	// Receives every metric of the simulation (none recorded if nil).
+	MetricsSink MetricsSink
+}
+
+// Defaults of the goqueuesim command (a single shop of 2200 clients behind capped bins).
+func DefaultConfig() Config {
+	return Config{
+		Shops: []ShopConfig{{
+			ClientDistributionJsonPath: "config/simulation/client_distributions/plausible_best_case_scenario.json",
+			NumClients:                 2200,
+			InventoryStockTotal:        9200,
+			RandomizeClientOrder:       true,
+		}},
+
+		QueueType:                    "capped_bins_queue",
+		TrackerType:                  "fixed_window",
+		WindowDuration:               2 * time.Second,
+		MaxCheckoutsAllowedPerWindow: 200,
+
+		PriorityTierPolicy:            "none",
+		PriorityTierWeights:           map[int]float64{0: 1, 1: 3},
+		PriorityTierReservedCheckouts: map[int]int64{1: 50},
+
+		ThrottleCookieSecret: "goqueuesim-throttle-cookie-secret",
+		ThrottleCookieMaxAge: 2 * time.Minute,
+
+		ClientRepoType:                "simple_client_repo",
+		MaxUnfairnessToleranceSeconds: 15.0,
+		FairnessBaselinePath:          "results/strict_fifo_baseline.json",
+
+		MaxNetworkIOBacklogSize:    2000,
+		NetworkModelType:           "instant",
+		DefaultLinkProfile:         "broadband",
+		ExternalThrottleBaseURL:    "http://127.0.0.1:8090",
+		StartsMockExternalThrottle: true,
+		HTTPListenAddr:             "127.0.0.1:8089",
+		HTTPRequestTimeout:         30 * time.Second,
+
+		NumServerNodes:     1,
+		NumServerWorkers:   2000,
+		LoadBalancerPolicy: "round_robin",
+		ServiceTimeSigma:   0.5,
+
+		CheckoutEndpointCpuMs: 2.0,
+		PollEndpointCpuMs:     0.5,
+		QueueOperationCpuMs:   0.05,
+		RedisCallCpuMs:        0.2,
+		LuaScriptCpuMs:        0.3,
+
+		RedisAddr:    "localhost:6379",
+		DrainTimeout: 5 * time.Second,
+	}
+}
+
+func oneOf(name, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of: {%s}, got '%s'", name, strings.Join(allowed, ", "), value)
+}
+
+func (cfg *Config) validate() error {
+	if len(cfg.Shops) == 0 {
+		return fmt.Errorf("at least one shop must be configured")
+	}
+	for i, shop := range cfg.Shops {
+		if shop.NumClients <= 0 {
+			return fmt.Errorf("shop #%d should have > 0 clients but found %d", i+1, shop.NumClients)
+		}
+	}
+	if cfg.NumServerNodes < 1 {
+		return fmt.Errorf("NumServerNodes should be >= 1 but found %d", cfg.NumServerNodes)
+	}
+	if cfg.NumServerWorkers < 1 {
+		return fmt.Errorf("NumServerWorkers should be >= 1 but found %d", cfg.NumServerWorkers)
+	}
+	if cfg.MaxCheckoutsAllowedPerWindow < 1 || cfg.WindowDuration <= 0 {
+		return fmt.Errorf("MaxCheckoutsAllowedPerWindow & WindowDuration should be > 0")
+	}
+	if cfg.CheckpointAfter < 0 {
+		return fmt.Errorf("CheckpointAfter should be >= 0 but found %s", cfg.CheckpointAfter)
+	}
+	if (cfg.CheckpointAfter > 0 || cfg.ResumeFromPath != "") && cfg.throttledExternally() {
+		return fmt.Errorf("simulations throttled externally cannot be checkpointed or resumed")
+	}
+	if _, err := queuefactory.ResolveQueueOptions(cfg.QueueType, cfg.QueueOptions); err != nil {
+		return err
+	}
+	if _, err := trackerfactory.ResolveTrackerOptions(cfg.TrackerType, cfg.TrackerOptions); err != nil {
+		return err
+	}
+	if err := oneOf(
+		"priority tier policy", cfg.PriorityTierPolicy, "none", "strict_priority", "weighted_share", "reserved_capacity",
+	); err != nil {
+		return err
+	}
+	if _, err := simulator.LookupClientRepoType(cfg.ClientRepoType); err != nil {
+		return err
+	}
+	if err := oneOf("network model type", cfg.NetworkModelType, "instant", "simulated", "http", "external"); err != nil {
+		return err
+	}
+	if err := oneOf("default link profile", cfg.DefaultLinkProfile, network.LinkProfileNames()...); err != nil {
+		return err
+	}
+	return oneOf("load balancer policy", cfg.LoadBalancerPolicy, "random", "round_robin", "sticky")
+}
+
+func distributionFilename(distributionPath string) string {
+	distributionStrSlice := strings.Split(distributionPath, "/")
+	return strings.TrimSuffix(distributionStrSlice[len(distributionStrSlice)-1], ".json")
+}
+
+func (cfg *Config) totalTargetNumClients() int {
+	total := 0
+	for _, shopConfig := range cfg.Shops {
+		total += shopConfig.NumClients
+	}
+	return total
+}
+
+// Address the throttle is served over HTTP on (none if empty).
+func (cfg *Config) throttleHTTPListenAddr() string {
+	if cfg.NetworkModelType == "http" || cfg.ServesThrottleOverHTTP {
+		return cfg.HTTPListenAddr
+	}
+	return ""
+}
+
+func (cfg *Config) throttledExternally() bool {
+	return cfg.NetworkModelType == "external"
+}
+
+// Checkouts each node's tracker allows per window, so that nodes together allow MaxCheckoutsAllowedPerWindow.
+func (cfg *Config) nodeMaxCheckoutsAllowedPerWindow() uint64 {
+	numNodes := int64(cfg.NumServerNodes)
+	return uint64((cfg.MaxCheckoutsAllowedPerWindow + numNodes - 1) / numNodes)
+}
+
+// Identifies the scenario fairness baselines hold for: every shop's clients & inventory, the checkout budget & nodes.
+func (cfg *Config) scenarioFingerprint() string {
+	shops := make([]string, 0, len(cfg.Shops))
+	for _, shopConfig := range cfg.Shops {
+		shops = append(shops, fmt.Sprintf(
+			"%s(clients=%d inventory=%d randomized=%t)", shopConfig.ClientDistributionJsonPath,
+			shopConfig.NumClients, shopConfig.InventoryStockTotal, shopConfig.RandomizeClientOrder,
+		))
+	}
+	return fmt.Sprintf(
+		"shops=[%s] window=%s max_checkouts=%d nodes=%d",
+		strings.Join(shops, " "), cfg.WindowDuration, cfg.MaxCheckoutsAllowedPerWindow, cfg.NumServerNodes,
+	)
+}
+
+// Tags describing the experiment (added to every metric of the simulation), sorted by name.
+func (cfg *Config) ExperimentTags() []string {
+	distributionNames := make([]string, 0, len(cfg.Shops))
+	randomizedOrders := make([]string, 0, len(cfg.Shops))
+	for _, shopConfig := range cfg.Shops {
+		distributionNames = append(distributionNames, distributionFilename(shopConfig.ClientDistributionJsonPath))
+		randomizedOrders = append(randomizedOrders, fmt.Sprint(shopConfig.RandomizeClientOrder))
+	}
+	tags := []string{
+		fmt.Sprintf("client_distribution:%s", strings.Join(distributionNames, "+")),
+		fmt.Sprintf("queue_type:%s", cfg.QueueType),
+		fmt.Sprintf("priority_tier_policy:%s", cfg.PriorityTierPolicy),
+		fmt.Sprintf("signed_throttle_cookies:%t", cfg.SignsThrottleCookies),
+		fmt.Sprintf("drain_rate_tracker_type:%s", cfg.TrackerType),
+		fmt.Sprintf("client_repo_type:%s", cfg.ClientRepoType),
+		fmt.Sprintf("network_model_type:%s", cfg.NetworkModelType),
+		fmt.Sprintf("window_duration_seconds:%.2f", cfg.WindowDuration.Seconds()),
+		fmt.Sprintf("max_checkouts_per_window:%d", cfg.MaxCheckoutsAllowedPerWindow),
+		fmt.Sprintf("client_order_randomized:%s", strings.Join(randomizedOrders, "+")),
+		fmt.Sprintf("num_clients:%d", cfg.totalTargetNumClients()),
+		fmt.Sprintf("num_shops:%d", len(cfg.Shops)),
+		fmt.Sprintf("num_server_nodes:%d", cfg.NumServerNodes),
+		fmt.Sprintf("num_server_workers:%d", cfg.NumServerWorkers),
+		fmt.Sprintf("load_balancer_policy:%s", cfg.LoadBalancerPolicy),
+		fmt.Sprintf("unfairness_tolerance_seconds:%.2f", cfg.MaxUnfairnessToleranceSeconds),
+	}
+	queueOptions, _ := queuefactory.ResolveQueueOptions(cfg.QueueType, cfg.QueueOptions)
+	if luaQueueDir := queueOptions.String("lua_queue_dir"); luaQueueDir != "" {
+		luaStrSlice := strings.Split(luaQueueDir, "/")
+		tags = append(tags, fmt.Sprintf("lua_queue_dir:%s", luaStrSlice[len(luaStrSlice)-1]))
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,465 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/client"
+	clientfactory "github.com/Shopify/goqueuesim/internal/client/impl"
+	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/network"
+	"github.com/Shopify/goqueuesim/internal/network_mock"
+	"github.com/Shopify/goqueuesim/internal/simulator"
+	"github.com/Shopify/goqueuesim/internal/throttle"
+	"github.com/Shopify/goqueuesim/internal/throttle/queue"
+	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
+	"github.com/Shopify/goqueuesim/internal/throttle/tracker"
+	trackerfactory "github.com/Shopify/goqueuesim/internal/throttle/tracker/impl"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/rs/zerolog/log"
+)
+
+func loadClientDistributionConfig(filepath string, targetNumClients int) ([]client.ClientConfig, int, error) {
+	var clientDistributionConfig []client.ClientConfig
+	clientDistributionJson, err := os.Open(filepath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed opening client distribution json at path '%s'", filepath)
+	}
+	defer clientDistributionJson.Close()
+
+	jsonParser := json.NewDecoder(clientDistributionJson)
+	jsonParser.DisallowUnknownFields()
+	err = jsonParser.Decode(&clientDistributionConfig)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed parsing client distribution json with error '%s'", err.Error())
+	}
+	resolvedConfig, err := client.ResolveClientDistribution(clientDistributionConfig, targetNumClients)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid client distribution '%s': %s", filepath, err.Error())
+	}
+	actualNumClients := 0
+	for i, clientConfig := range resolvedConfig {
+		if err := clientfactory.ValidateClientConfig(clientConfig); err != nil {
+			return nil, 0, fmt.Errorf(
+				"invalid client distribution '%s' entry '%s' (#%d once mixtures are flattened): %s",
+				filepath, clientConfig.HumanizedLabel, i, err.Error(),
+			)
+		}
+		actualNumClients += clientConfig.Count
+	}
+	log.Debug().Msg(fmt.Sprintf("ClientDistributionConfig: %v", resolvedConfig))
+	return resolvedConfig, actualNumClients, nil
+}
+
+// Clients acting upon their ETA need a queue estimating it on every poll (unless throttled externally).
+func (cfg *Config) checkAdmissionEstimates(shopConfig ShopConfig, clientsConfig []client.ClientConfig) error {
+	queueType, _ := queuefactory.LookupQueueType(cfg.QueueType)
+	if queueType.EstimatesAdmission || cfg.throttledExternally() {
+		return nil
+	}
+	for _, clientConfig := range clientsConfig {
+		if clientfactory.ActsOnAdmissionEstimates(clientConfig) {
+			return fmt.Errorf(
+				"client distribution '%s' entry '%s' (%s) acts upon ETAs, which %s does not estimate",
+				shopConfig.ClientDistributionJsonPath, clientConfig.HumanizedLabel, clientConfig.ClientType, cfg.QueueType,
+			)
+		}
+	}
+	return nil
+}
+
+// Messages lost by the simulated network only get retried upon a request timeout => clients on lossy links need one.
+func (cfg *Config) checkLossyLinks(shopConfig ShopConfig, clientsConfig []client.ClientConfig) error {
+	if cfg.NetworkModelType != "simulated" {
+		return nil
+	}
+	for _, clientConfig := range clientsConfig {
+		linkProfile := clientConfig.LinkProfile
+		if linkProfile == "" {
+			linkProfile = cfg.DefaultLinkProfile
+		}
+		if network.LinkProfiles[linkProfile].LossProbability > 0 && clientfactory.AwaitsResponsesForever(clientConfig) {
+			return fmt.Errorf(
+				"client distribution '%s' entry '%s' waits forever for responses (request_timeout_ms of 0)"+
+					" over link profile %s, which loses messages",
+				shopConfig.ClientDistributionJsonPath, clientConfig.HumanizedLabel, linkProfile,
+			)
+		}
+	}
+	return nil
+}
+
+// Gives clients not configuring a clock of their own the configured one.
+func withClientClock(clientDistributionConfig []client.ClientConfig, clock *ClockConfig) []client.ClientConfig {
+	if clock == nil {
+		return clientDistributionConfig
+	}
+	intDefaults := map[string]int{
+		"clock_offset_mean_ms":      clock.OffsetMeanMs,
+		"clock_offset_stddev_ms":    clock.OffsetStddevMs,
+		"background_timer_clamp_ms": clock.BackgroundTimerClampMs,
+	}
+	floatDefaults := map[string]float64{
+		"mean_visible_secs": clock.MeanVisibleSecs,
+		"mean_hidden_secs":  clock.MeanHiddenSecs,
+	}
+	clocked := make([]client.ClientConfig, len(clientDistributionConfig))
+	for i, clientConfig := range clientDistributionConfig {
+		// Flattened mixtures may share property maps => copy before filling in.
+		intProperties := make(map[string]int, len(clientConfig.CustomIntProperties)+len(intDefaults))
+		for name, v := range clientConfig.CustomIntProperties {
+			intProperties[name] = v
+		}
+		for name, v := range intDefaults {
+			if _, found := clientConfig.PropertyDistributions[name]; !found && v != 0 {
+				if _, found := intProperties[name]; !found {
+					intProperties[name] = v
+				}
+			}
+		}
+		floatProperties := make(map[string]float64, len(clientConfig.CustomFloatProperties)+len(floatDefaults))
+		for name, v := range clientConfig.CustomFloatProperties {
+			floatProperties[name] = v
+		}
+		for name, v := range floatDefaults {
+			if _, found := clientConfig.PropertyDistributions[name]; !found && v != 0 {
+				if _, found := floatProperties[name]; !found {
+					floatProperties[name] = v
+				}
+			}
+		}
+		clientConfig.CustomIntProperties = intProperties
+		clientConfig.CustomFloatProperties = floatProperties
+		clocked[i] = clientConfig
+	}
+	return clocked
+}
+
+// Network params shared by every shop (shop-specific fields are filled in by withShop).
+func (cfg *Config) prepareNetworkParams(
+	ctx context.Context,
+	numClients int,
+	recorder *metrics.Recorder,
+) clientfactory.NetworkParams {
+	var clientsFinishedWaitGroup sync.WaitGroup
+	clientsFinishedWaitGroup.Add(numClients)
+	responseChannelsMap := make(map[int]chan *network_mock.MockResponse)
+	return clientfactory.NetworkParams{
+		Ctx:                      ctx,
+		ClientsFinishedWaitGroup: &clientsFinishedWaitGroup,
+		RequestTargetChannel:     make(chan *network_mock.MockRequest, cfg.MaxNetworkIOBacklogSize),
+		ResponseChannelsMap:      responseChannelsMap,
+		Network:                  cfg.makeNetworkModel(ctx, responseChannelsMap, recorder),
+		Metrics:                  recorder,
+		CookieJar:                clientfactory.MakeCookieJar(),
+	}
+}
+
+func withShop(
+	networkParams clientfactory.NetworkParams,
+	shopCtx context.Context,
+	shopId int,
+) clientfactory.NetworkParams {
+	networkParams.Ctx = shopCtx
+	networkParams.ShopId = shopId
+	return networkParams
+}
+
+// Client ids are generated from firstId onwards so that they remain unique across shops.
+func (cfg *Config) makeMockCheckoutClients(
+	clientDistributionConfig []client.ClientConfig,
+	networkParams clientfactory.NetworkParams,
+	shouldRandomizeOrder bool,
+	orderSeed int64,
+	targetNumClients int,
+	firstId int,
+) []client.Client {
+	checkoutClients := make([]client.Client, 0, targetNumClients)
+	id := firstId
+	for _, clientConfig := range clientDistributionConfig {
+		for j := 0; j < clientConfig.Count; j++ {
+			sessions := clientfactory.MakeSessionsFromConfig(clientConfig, networkParams, id)
+			// Clients finished wait group counts one per person => account for extra sessions.
+			networkParams.ClientsFinishedWaitGroup.Add(len(sessions) - 1)
+			for _, c := range sessions {
+				checkoutClients = append(checkoutClients, c)
+				networkParams.ResponseChannelsMap[c.ID()] =
+					make(chan *network_mock.MockResponse, cfg.MaxNetworkIOBacklogSize)
+				id++
+			}
+		}
+	}
+	if shouldRandomizeOrder {
+		shuffler := rand.New(rand.NewSource(orderSeed))
+		shuffler.Shuffle(len(checkoutClients), func(i, j int) {
+			checkoutClients[i], checkoutClients[j] = checkoutClients[j], checkoutClients[i]
+		})
+	}
+	return checkoutClients
+}
+
+func makeRedisClient(redisAddr string) (*redis.Client, error) {
+	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr})
+	_, pingErr := redisClient.Ping().Result()
+	return redisClient, pingErr
+}
+
+func (cfg *Config) shopScopePrefix(shopId int) string {
+	if cfg.RedisKeyPrefix != "" {
+		return fmt.Sprintf("%s:shop_id:%d", cfg.RedisKeyPrefix, shopId)
+	}
+	return fmt.Sprintf("shop_id:%d", shopId)
+}
+
+func distinctPriorityTiers(clientDistributionConfig []client.ClientConfig) []int {
+	seen := make(map[int]bool)
+	tiers := make([]int, 0)
+	for _, clientConfig := range clientDistributionConfig {
+		if !seen[clientConfig.PriorityTier] {
+			seen[clientConfig.PriorityTier] = true
+			tiers = append(tiers, clientConfig.PriorityTier)
+		}
+	}
+	return tiers
+}
+
+func (cfg *Config) makeTierAdmissionPolicy() queuefactory.TierAdmissionPolicy {
+	switch cfg.PriorityTierPolicy {
+	case "strict_priority":
+		return &queuefactory.StrictPriorityPolicy{}
+	case "weighted_share":
+		return &queuefactory.WeightedSharePolicy{Weights: cfg.PriorityTierWeights}
+	case "reserved_capacity":
+		// Each node's tiered queue admits its share of the window's checkouts => reserve its share of them too.
+		numNodes := int64(cfg.NumServerNodes)
+		nodeReservedCheckouts := make(map[int]int64, len(cfg.PriorityTierReservedCheckouts))
+		for tier, reserved := range cfg.PriorityTierReservedCheckouts {
+			nodeReservedCheckouts[tier] = (reserved + numNodes - 1) / numNodes
+		}
+		return &queuefactory.ReservedCapacityPolicy{ReservedCheckouts: nodeReservedCheckouts}
+	default:
+		panic(fmt.Errorf("priority tier policy must be one of: {none, strict_priority, weighted_share, reserved_capacity}"))
+	}
+}
+
+// Dependencies shared by the queues of one shop on one server node.
+type queueDeps struct {
+	ctx                    context.Context
+	startSignalWaitGroup   *sync.WaitGroup
+	redisClient            *redis.Client
+	globalInventoryCounter *common.AtomicCounter
+	costLedger             *cost.Ledger
+	recorder               *metrics.Recorder
+}
+
+// Returns the queue of a whole shop, wrapped so as to sign throttle cookies when enabled.
+func (cfg *Config) makeShopQueue(
+	deps queueDeps,
+	shopId int,
+	clientDistributionConfig []client.ClientConfig,
+) queue.Queue {
+	shopQueue := cfg.makeUnsignedShopQueue(deps, shopId, clientDistributionConfig)
+	if cfg.SignsThrottleCookies {
+		return queuefactory.MakeSignedCookieQueue(
+			shopQueue, cfg.ThrottleCookieSecret, cfg.ThrottleCookieMaxAge, deps.recorder,
+		)
+	}
+	return shopQueue
+}
+
+// One sub-queue per priority tier unless tiers are disabled.
+func (cfg *Config) makeUnsignedShopQueue(
+	deps queueDeps,
+	shopId int,
+	clientDistributionConfig []client.ClientConfig,
+) queue.Queue {
+	if cfg.PriorityTierPolicy == "none" {
+		userQueue := cfg.makeUserQueue(deps, cfg.shopScopePrefix(shopId))
+		userQueue.Clear()
+		return userQueue
+	}
+	makeTierQueue := func(tier int) queue.Queue {
+		// Redis-backed tier queues must not share keys => nest tier scope under the shop scope.
+		return cfg.makeUserQueue(deps, fmt.Sprintf("%s:priority_tier:%d", cfg.shopScopePrefix(shopId), tier))
+	}
+	return queuefactory.MakeTieredQueue(
+		distinctPriorityTiers(clientDistributionConfig),
+		makeTierQueue,
+		cfg.makeTierAdmissionPolicy(),
+		int64(cfg.nodeMaxCheckoutsAllowedPerWindow()),
+		deps.recorder,
+	)
+}
+
+// Config passed validate => the queue type is registered & its options valid.
+func (cfg *Config) makeUserQueue(deps queueDeps, scopePrefix string) queue.Queue {
+	queueType, err := queuefactory.LookupQueueType(cfg.QueueType)
+	if err != nil {
+		panic(err)
+	}
+	options, err := queuefactory.ResolveQueueOptions(cfg.QueueType, cfg.QueueOptions)
+	if err != nil {
+		panic(err)
+	}
+	return queueType.Make(queuefactory.QueueParams{
+		Ctx:                    deps.ctx,
+		StartSignalWaitGroup:   deps.startSignalWaitGroup,
+		RedisClient:            deps.redisClient,
+		ScopePrefix:            scopePrefix,
+		WindowDuration:         cfg.WindowDuration,
+		MaxCheckoutsPerWindow:  cfg.MaxCheckoutsAllowedPerWindow,
+		GlobalInventoryCounter: deps.globalInventoryCounter,
+		Costs:                  deps.costLedger,
+		Metrics:                deps.recorder,
+		Options:                options,
+	})
+}
+
+func (cfg *Config) makeRateTracker(
+	ctx context.Context,
+	startSignalWaitGroup *sync.WaitGroup,
+	maxAllowed uint64,
+) tracker.Tracker {
+	trackerType, err := trackerfactory.LookupTrackerType(cfg.TrackerType)
+	if err != nil {
+		panic(err)
+	}
+	options, err := trackerfactory.ResolveTrackerOptions(cfg.TrackerType, cfg.TrackerOptions)
+	if err != nil {
+		panic(err)
+	}
+	return trackerType.Make(trackerfactory.TrackerParams{
+		Ctx:                   ctx,
+		StartSignalWaitGroup:  startSignalWaitGroup,
+		WindowDuration:        cfg.WindowDuration,
+		MaxCheckoutsPerWindow: maxAllowed,
+		Options:               options,
+	})
+}
+
+func makeCheckoutThrottleDriver(
+	shopId int,
+	nodeId int,
+	ctx context.Context,
+	ctxCancelFunc context.CancelFunc,
+	startSignalWaitGroup *sync.WaitGroup,
+	userQueue queue.Queue,
+	rateTracker tracker.Tracker,
+	globalInventoryCounter *common.AtomicCounter,
+	costLedger *cost.Ledger,
+	recorder *metrics.Recorder,
+) *throttle.CheckoutThrottleDriver {
+	t := &throttle.CheckoutThrottleDriver{
+		ShopId:                 shopId,
+		NodeId:                 nodeId,
+		Ctx:                    ctx,
+		CtxCancelFunc:          ctxCancelFunc,
+		StartSignalWaitGroup:   startSignalWaitGroup,
+		ThrottleQueue:          userQueue,
+		RateTracker:            rateTracker,
+		GlobalInventoryCounter: globalInventoryCounter,
+		Metrics:                recorder,
+		Costs:                  costLedger,
+	}
+	// Drive utilization tracking via background goroutine rather than rely on client polling.
+	go t.MonitorUtilAndNotifyQueue()
+	return t
+}
+
+func makeClientRepo(clientRepoType string) simulator.ClientRepo {
+	repoType, err := simulator.LookupClientRepoType(clientRepoType)
+	if err != nil {
+		panic(err)
+	}
+	return repoType.Make()
+}
+
+func makeLoadBalancer(loadBalancerPolicy string) simulator.LoadBalancer {
+	switch loadBalancerPolicy {
+	case "random":
+		return simulator.MakeRandomLoadBalancer()
+	case "round_robin":
+		return simulator.MakeRoundRobinLoadBalancer()
+	case "sticky":
+		return simulator.MakeStickyLoadBalancer()
+	default:
+		panic(fmt.Errorf("load balancer policy must be one of: {random, round_robin, sticky}"))
+	}
+}
+
+func (cfg *Config) makeNetworkModel(
+	ctx context.Context,
+	responseChannelsMap map[int]chan *network_mock.MockResponse,
+	recorder *metrics.Recorder,
+) network.Model {
+	switch cfg.NetworkModelType {
+	case "instant":
+		return network.MakeInstantNetwork(recorder)
+	case "simulated":
+		return network.MakeSimulatedNetwork(ctx, network.LinkProfiles[cfg.DefaultLinkProfile], recorder)
+	case "http":
+		return network.MakeHTTPNetwork(
+			ctx, "http://"+cfg.HTTPListenAddr, cfg.MaxNetworkIOBacklogSize, cfg.HTTPRequestTimeout,
+			responseChannelsMap, recorder,
+		)
+	case "external":
+		return network.MakeHTTPNetwork(
+			ctx, cfg.ExternalThrottleBaseURL, cfg.MaxNetworkIOBacklogSize, cfg.HTTPRequestTimeout,
+			responseChannelsMap, recorder,
+		)
+	default:
+		panic(fmt.Errorf("network model type must be one of: {instant, simulated, http, external}"))
+	}
+}
+
+func (cfg *Config) serviceTimeDistribution(medianMs float64) client.Distribution {
+	if medianMs <= 0 {
+		return client.Distribution{Type: "constant", Value: 0}
+	}
+	return client.Distribution{Type: "lognormal", Median: medianMs, Sigma: cfg.ServiceTimeSigma}
+}
+
+func (cfg *Config) makeCostModel() cost.Model {
+	return cost.Model{
+		cost.CheckoutEndpoint: {CpuMs: cfg.CheckoutEndpointCpuMs},
+		cost.PollEndpoint:     {CpuMs: cfg.PollEndpointCpuMs},
+		cost.QueueOperation:   {CpuMs: cfg.QueueOperationCpuMs},
+		cost.TrackerOperation: {CpuMs: cfg.QueueOperationCpuMs},
+		cost.RedisCommand:     {CpuMs: cfg.RedisCallCpuMs, RedisCalls: 1},
+		cost.RedisEvalSha:     {CpuMs: cfg.RedisCallCpuMs + cfg.LuaScriptCpuMs, RedisCalls: 1},
+	}
+}
+
+// Stands in for the external queue (admitting MaxCheckoutsAllowedPerWindow per window) until the returned func
+// is called.
+func (cfg *Config) startMockExternalThrottle() (func(), error) {
+	baseURL, err := url.Parse(cfg.ExternalThrottleBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external throttle base url: %s", err.Error())
+	}
+	admissionsPerSecond := float64(cfg.MaxCheckoutsAllowedPerWindow) / cfg.WindowDuration.Seconds()
+	inventories := make(map[int]int, len(cfg.Shops))
+	for i, shopConfig := range cfg.Shops {
+		inventories[i+1] = shopConfig.InventoryStockTotal
+	}
+	mockServer := network.MakeMockQueueServer(admissionsPerSecond, inventories, 5*time.Second)
+	listener, err := net.Listen("tcp", baseURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %s", baseURL.Host, err.Error())
+	}
+	server := &http.Server{Handler: mockServer}
+	go server.Serve(listener)
+	fmt.Printf("Serving mock external queue at: %s\n", cfg.ExternalThrottleBaseURL)
+	return func() { server.Close() }, nil
+}
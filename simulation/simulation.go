@@ -0,0 +1,315 @@
+// Package simulation runs checkout queue simulations programmatically.
+//
+// Each Simulation owns all of its state (clients, queues, trackers, network & metrics recorder), so that several
+// may run in parallel within one process. Parallel simulations must however use distinct HTTPListenAddr /
+// ExternalThrottleBaseURL when serving over HTTP & distinct RedisKeyPrefix when sharing a Redis.
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/goqueuesim/internal/checkpoint"
+	"github.com/Shopify/goqueuesim/internal/client"
+	"github.com/Shopify/goqueuesim/internal/common"
+	"github.com/Shopify/goqueuesim/internal/cost"
+	"github.com/Shopify/goqueuesim/internal/metrics"
+	"github.com/Shopify/goqueuesim/internal/simulator"
+	"github.com/Shopify/goqueuesim/internal/throttle"
+	queuefactory "github.com/Shopify/goqueuesim/internal/throttle/queue/impl"
+)
+
+// MetricsSink receives every metric of a simulation (e.g. a statsd client, see metrics.MakeStatsdSink).
+type MetricsSink = metrics.Sink
+
+// FairnessSummary counts the unfair admissions of one shop (relative to client arrival order).
+type FairnessSummary = simulator.FairnessSummary
+
+// ShopResult sums up how one shop's clients fared.
+type ShopResult struct {
+	ShopId      int
+	NumClients  int
+	NumAdmitted int
+
+	// Nil when the queue does not report fairness (lottery_queue).
+	Fairness *FairnessSummary
+
+	// Notional cost of the work done for the shop (zero when throttled externally).
+	Cost cost.Cost
+}
+
+// Result of a completed simulation (detailed reports are printed to stdout & sent to the metrics sink).
+type Result struct {
+	Shops    []ShopResult
+	Duration time.Duration
+
+	// Set when the simulation was stopped after Config.CheckpointAfter (results then cover the run so far).
+	Checkpoint *Checkpoint
+}
+
+// Simulation is a validated Config ready to Run (see Builder).
+type Simulation struct {
+	cfg Config
+
+	// Checkpoint every run resumes from (none if nil).
+	resume *Checkpoint
+}
+
+func (s *Simulation) Config() Config {
+	return s.cfg
+}
+
+// Simulates every shop from scratch (or from the checkpoint resumed) until all are sold out, their clients are
+// done, ctx is cancelled or it is time to checkpoint. A simulation may be run several times (each run starts
+// afresh & drains before returning).
+func (s *Simulation) Run(parentCtx context.Context) (*Result, error) {
+	cfg := &s.cfg
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+
+	resume := s.resume
+	if resume == nil && cfg.ResumeFromPath != "" {
+		var err error
+		if resume, err = LoadCheckpoint(cfg.ResumeFromPath); err != nil {
+			return nil, err
+		}
+	}
+	run, err := s.prepare(ctx, cancel, resume)
+	if err != nil {
+		return nil, err
+	}
+	defer run.close()
+	simDriver, clock := run.simDriver, run.clock
+
+	// Snapshotted as the simulation stops, before draining (simulations completing first are not checkpointed).
+	var cp *Checkpoint
+	var checkpointErr error
+	if cfg.CheckpointAfter > 0 {
+		simDriver.CheckpointListener = func() {
+			cp, checkpointErr = cfg.takeCheckpoint(simDriver, clock, time.Since(clock.Epoch))
+		}
+		checkpointTimer := time.AfterFunc(cfg.CheckpointAfter, func() { simDriver.RequestCheckpoint() })
+		defer checkpointTimer.Stop()
+	}
+	startTime := time.Now()
+	simDriver.StartSimulation()
+	result := makeResult(simDriver, time.Since(startTime))
+	if checkpointErr != nil {
+		return nil, checkpointErr
+	}
+	if cp != nil {
+		if cfg.CheckpointPath != "" {
+			if err := cp.Save(cfg.CheckpointPath); err != nil {
+				return nil, err
+			}
+			fmt.Printf("\ncheckpointed after %s to %s\n", cp.Elapsed, cfg.CheckpointPath)
+		}
+		result.Checkpoint = cp
+	}
+	return result, nil
+}
+
+// preparedRun is a simulation set up (& restored from its checkpoint, if resumed) yet to start.
+type preparedRun struct {
+	simDriver *simulator.SimulationDriver
+	clock     checkpoint.Clock
+
+	// Release what the run holds onto (e.g. its Redis connection), in reverse order.
+	closers []func()
+}
+
+func (run *preparedRun) close() {
+	for i := len(run.closers) - 1; i >= 0; i-- {
+		run.closers[i]()
+	}
+}
+
+// Sets up the clients, queues, trackers & network of every shop, restored from resume (if not nil).
+func (s *Simulation) prepare(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	resume *Checkpoint,
+) (_ *preparedRun, err error) {
+	cfg := &s.cfg
+	run := &preparedRun{}
+	defer func() {
+		if err != nil {
+			run.close()
+		}
+	}()
+
+	var startSignalWaitGroup sync.WaitGroup
+	startSignalWaitGroup.Add(1)
+
+	var recorder *metrics.Recorder
+	if cfg.MetricsSink != nil {
+		recorder = metrics.MakeRecorder(cfg.MetricsSink, cfg.ExperimentTags())
+	}
+	recordExperimentParams(cfg, recorder)
+
+	shopsClientsConfig := make([][]client.ClientConfig, len(cfg.Shops))
+	totalNumClients := 0
+	for i, shopConfig := range cfg.Shops {
+		clientsConfig, actualNumClients, err := loadClientDistributionConfig(
+			shopConfig.ClientDistributionJsonPath, shopConfig.NumClients,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.checkAdmissionEstimates(shopConfig, clientsConfig); err != nil {
+			return nil, err
+		}
+		if err := cfg.checkLossyLinks(shopConfig, clientsConfig); err != nil {
+			return nil, err
+		}
+		shopsClientsConfig[i] = withClientClock(clientsConfig, cfg.ClientClock)
+		totalNumClients += actualNumClients
+	}
+	networkParams := cfg.prepareNetworkParams(ctx, totalNumClients, recorder)
+	if resume != nil {
+		networkParams.ResumedProperties = resume.sampledProperties()
+	}
+	clientRepo := makeClientRepo(cfg.ClientRepoType)
+
+	// Redis (when required) is shared by every shop => shop-scoped keys keep their queues apart.
+	redisClient, redisErr := makeRedisClient(cfg.RedisAddr)
+	run.closers = append(run.closers, func() { redisClient.Close() })
+	if queueType, _ := queuefactory.LookupQueueType(cfg.QueueType); queueType.RequiresRedis && redisErr != nil {
+		return nil, fmt.Errorf("Redis is required for %s but failed to respond a ping request", cfg.QueueType)
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	// Prepare throttle simulation configured for target params (one queue & tracker per shop & server node).
+	shops := make([]*simulator.Shop, 0, len(cfg.Shops))
+	nextClientId := 1
+	for i, shopConfig := range cfg.Shops {
+		shopId := i + 1
+		shopCtx, shopCancel := context.WithCancel(ctx)
+		shopNetworkParams := withShop(networkParams, shopCtx, shopId)
+		checkoutClients := cfg.makeMockCheckoutClients(
+			shopsClientsConfig[i],
+			shopNetworkParams,
+			shopConfig.RandomizeClientOrder,
+			seed+int64(shopId),
+			shopConfig.NumClients,
+			nextClientId,
+		)
+		nextClientId += len(checkoutClients)
+
+		// Externally throttled shops are queued by the server at ExternalThrottleBaseURL instead.
+		var nodeThrottleDrivers []*throttle.CheckoutThrottleDriver
+		var costLedger *cost.Ledger
+		if !cfg.throttledExternally() {
+			inventoryCounter := &common.AtomicCounter{Count: int32(shopConfig.InventoryStockTotal)}
+			costLedger = cost.MakeLedger(cfg.makeCostModel())
+			deps := queueDeps{
+				ctx:                    shopCtx,
+				startSignalWaitGroup:   &startSignalWaitGroup,
+				redisClient:            redisClient,
+				globalInventoryCounter: inventoryCounter,
+				costLedger:             costLedger,
+				recorder:               recorder,
+			}
+			for nodeId := 0; nodeId < cfg.NumServerNodes; nodeId++ {
+				userQueue := cfg.makeShopQueue(deps, shopId, shopsClientsConfig[i])
+				rateTracker := cfg.makeRateTracker(
+					shopCtx, &startSignalWaitGroup, cfg.nodeMaxCheckoutsAllowedPerWindow(),
+				)
+				nodeThrottleDrivers = append(nodeThrottleDrivers, makeCheckoutThrottleDriver(
+					shopId, nodeId, shopCtx, shopCancel, &startSignalWaitGroup, userQueue, rateTracker, inventoryCounter,
+					costLedger, recorder,
+				))
+			}
+		}
+
+		shops = append(shops, &simulator.Shop{
+			Id:                  shopId,
+			Ctx:                 shopCtx,
+			CtxCancelFunc:       shopCancel,
+			NodeThrottleDrivers: nodeThrottleDrivers,
+			Costs:               costLedger,
+			Clients:             checkoutClients,
+			InventoryStockTotal: shopConfig.InventoryStockTotal,
+		})
+	}
+
+	simDriver := &simulator.SimulationDriver{
+		Ctx:                                ctx,
+		CtxCancelFunc:                      cancel,
+		Shops:                              shops,
+		NumServerNodes:                     cfg.NumServerNodes,
+		NumServerWorkers:                   cfg.NumServerWorkers,
+		LoadBalancer:                       makeLoadBalancer(cfg.LoadBalancerPolicy),
+		RequestTargetChannel:               networkParams.RequestTargetChannel,
+		ResponseChannelsMap:                networkParams.ResponseChannelsMap,
+		Network:                            networkParams.Network,
+		HTTPListenAddr:                     cfg.throttleHTTPListenAddr(),
+		MaxInFlightHTTPRequests:            cfg.MaxNetworkIOBacklogSize,
+		CheckoutServiceTimeMs:              cfg.serviceTimeDistribution(cfg.CheckoutServiceTimeMedianMs),
+		PollServiceTimeMs:                  cfg.serviceTimeDistribution(cfg.PollServiceTimeMedianMs),
+		MaxQueueingDelay:                   cfg.MaxQueueingDelay,
+		ThrottledExternally:                cfg.throttledExternally(),
+		Metrics:                            recorder,
+		DrainTimeout:                       cfg.DrainTimeout,
+		SimulationCompletedListenerChannel: make(chan struct{}),
+		StartSignalWaitGroup:               &startSignalWaitGroup,
+		ClientsFinishedWaitGroup:           networkParams.ClientsFinishedWaitGroup,
+		ClientRepo:                         clientRepo,
+		MaxUnfairnessToleranceSeconds:      cfg.MaxUnfairnessToleranceSeconds,
+		FairnessBaselinePath:               cfg.FairnessBaselinePath,
+		RecordsFairnessBaseline:            cfg.QueueType == "strict_fifo_queue",
+		Seed:                               seed,
+		Scenario:                           cfg.scenarioFingerprint(),
+		ReportsLotteryAdvantage:            cfg.QueueType == "lottery_queue",
+	}
+
+	if cfg.throttledExternally() && cfg.StartsMockExternalThrottle {
+		stopMockExternalThrottle, err := cfg.startMockExternalThrottle()
+		if err != nil {
+			return nil, err
+		}
+		run.closers = append(run.closers, stopMockExternalThrottle)
+	}
+	clock := checkpoint.Clock{Epoch: time.Now()}
+	if resume != nil {
+		clock.Epoch = clock.Epoch.Add(-resume.Elapsed)
+		if err := cfg.restoreCheckpoint(resume, simDriver, clock); err != nil {
+			return nil, err
+		}
+	}
+	run.simDriver, run.clock = simDriver, clock
+	return run, nil
+}
+
+func recordExperimentParams(cfg *Config, recorder *metrics.Recorder) {
+	recorder.Gauge("window_duration_seconds", cfg.WindowDuration.Seconds(), nil)
+	recorder.Gauge("max_checkouts_per_window", float64(cfg.MaxCheckoutsAllowedPerWindow), nil)
+	recorder.Gauge("num_clients", float64(cfg.totalTargetNumClients()), nil)
+	recorder.Gauge("num_shops", float64(len(cfg.Shops)), nil)
+	recorder.Gauge("num_server_nodes", float64(cfg.NumServerNodes), nil)
+	recorder.Gauge("num_server_workers", float64(cfg.NumServerWorkers), nil)
+	recorder.Gauge("unfairness_tolerance_seconds", cfg.MaxUnfairnessToleranceSeconds, nil)
+}
+
+func makeResult(simDriver *simulator.SimulationDriver, duration time.Duration) *Result {
+	result := &Result{Shops: make([]ShopResult, 0, len(simDriver.Shops)), Duration: duration}
+	for _, shop := range simDriver.Shops {
+		shopResult := ShopResult{ShopId: shop.Id, NumClients: len(shop.Clients), Cost: shop.Costs.Total()}
+		for _, c := range shop.Clients {
+			if c.ReachedCheckout() {
+				shopResult.NumAdmitted++
+			}
+		}
+		if summary, found := simDriver.FairnessSummaries[shop.Id]; found {
+			shopResult.Fairness = &summary
+		}
+		result.Shops = append(result.Shops, shopResult)
+	}
+	return result
+}